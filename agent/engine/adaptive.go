@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+)
+
+// Adaptive defines adaptive sampling: each pixel gets at least MinSamples samples, then more samples until the
+// relative error of its luminance drops below Threshold (or MaxSamples is reached)
+//
+//	the relative error is the standard error of the mean divided by the mean (at least minLuminance so that dark
+//	pixels do not get all the samples)
+type Adaptive struct {
+	Threshold  float64 `json:"threshold"`
+	MinSamples int     `json:"minSamples"`
+	MaxSamples int     `json:"maxSamples"`
+}
+
+// minLuminance is the lowest mean luminance the standard error is compared to
+const minLuminance = 0.05
+
+// adaptiveBatch is the number of samples cast between two checks of the error
+const adaptiveBatch = 4
+
+// UnmarshalJSON unmarshals the settings, missing values keeping their default
+func (a *Adaptive) UnmarshalJSON(data []byte) error {
+	type Alias Adaptive
+	aux := Alias{Threshold: 0.02, MinSamples: 16, MaxSamples: 1024}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*a = Adaptive(aux)
+	return nil
+}
+
+// Validate checks the settings
+func (a *Adaptive) Validate() error {
+	if a.Threshold <= 0 {
+		return fmt.Errorf("adaptive threshold must be positive: %v", a.Threshold)
+	}
+	if a.MinSamples < 1 || a.MaxSamples < a.MinSamples {
+		return fmt.Errorf("adaptive samples must verify 1 <= minSamples <= maxSamples: %v, %v", a.MinSamples, a.MaxSamples)
+	}
+	return nil
+}
+
+// WithAdaptive samples each pixel until it converges instead of casting raysPerPixel rays (nil means no
+// adaptive sampling)
+func WithAdaptive(adaptive *Adaptive) SceneOption {
+	return func(scene *Scene) {
+		scene.adaptive = adaptive
+	}
+}
+
+// converged returns whether the pixel has enough samples
+func (a *Adaptive) converged(pixel *pixel) bool {
+	if pixel.raysPerPixel >= a.MaxSamples {
+		return true
+	}
+	if pixel.raysPerPixel < a.MinSamples || pixel.raysPerPixel < 2 {
+		return false
+	}
+
+	n := float64(pixel.raysPerPixel)
+	mean := luminance(pixel.color) / n
+	variance := math.Max(0, (pixel.luminanceSq-mean*mean*n)/(n-1))
+	return math.Sqrt(variance/n)/math.Max(mean, minLuminance) < a.Threshold
+}
+
+// luminance returns the relative luminance of a linear color
+func luminance(c clr.Color) float64 {
+	return 0.2126*c.R + 0.7152*c.G + 0.0722*c.B
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"testing"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+)
+
+// samplesUntilConverged adds the samples returned by next to a pixel (one at a time) until the pixel converges,
+// returning the number of samples
+func samplesUntilConverged(a *Adaptive, next func(s int) float64) int {
+	pixel := &pixel{}
+	for !a.converged(pixel) {
+		v := next(pixel.raysPerPixel)
+		c := clr.Color{R: v, G: v, B: v}
+		pixel.color = pixel.color.Add(c)
+		pixel.luminanceSq += luminance(c) * luminance(c)
+		pixel.raysPerPixel++
+	}
+	return pixel.raysPerPixel
+}
+
+func TestAdaptiveConverged(t *testing.T) {
+	flat := func(v float64) func(int) float64 {
+		return func(int) float64 { return v }
+	}
+	// alternates between 0 and 2*v: a mean of v and a standard deviation of v
+	noisy := func(v float64) func(int) float64 {
+		return func(s int) float64 { return float64(s%2) * 2 * v }
+	}
+
+	cases := []struct {
+		name       string
+		adaptive   Adaptive
+		next       func(int) float64
+		minSamples int
+		maxSamples int
+	}{
+		{"constant", Adaptive{Threshold: 0.02, MinSamples: 16, MaxSamples: 1024}, flat(0.5), 16, 16},
+		{"black", Adaptive{Threshold: 0.02, MinSamples: 16, MaxSamples: 1024}, flat(0), 16, 16},
+		{"single sample floor", Adaptive{Threshold: 0.02, MinSamples: 1, MaxSamples: 1024}, flat(0.5), 2, 2},
+		{"min equals max", Adaptive{Threshold: 0.02, MinSamples: 8, MaxSamples: 8}, noisy(0.5), 8, 8},
+		// the relative error 1/sqrt(n) never drops below the threshold before the cap
+		{"noisy capped", Adaptive{Threshold: 0.02, MinSamples: 16, MaxSamples: 1024}, noisy(0.5), 1024, 1024},
+		// the relative error drops below 0.1 after about 100 samples
+		{"noisy", Adaptive{Threshold: 0.1, MinSamples: 16, MaxSamples: 1024}, noisy(0.5), 95, 105},
+		// the error of a dark pixel is relative to minLuminance: it converges at the floor
+		{"dark noisy", Adaptive{Threshold: 0.02, MinSamples: 16, MaxSamples: 1024}, noisy(0.001), 16, 16},
+	}
+
+	for _, tc := range cases {
+		n := samplesUntilConverged(&tc.adaptive, tc.next)
+		if n < tc.minSamples || n > tc.maxSamples {
+			t.Errorf("Expected %v to converge after %v to %v samples, but got %v", tc.name, tc.minSamples, tc.maxSamples, n)
+		}
+	}
+}
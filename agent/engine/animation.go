@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/camera"
+)
+
+// Animation renders a sequence of frames, the camera moving along keyframes
+//
+//	Cameras are the keyframes, evenly spaced from the first to the last frame (the camera of the world when
+//	there is none). Order defines the order of the frames in the output.
+type Animation struct {
+	Frames  int             `json:"frames"`
+	FPS     float64         `json:"fps"`
+	Order   Order           `json:"order"`
+	Cameras []camera.Camera `json:"-"`
+}
+
+// Order defines the order of the frames of an animation
+type Order string
+
+const (
+	Forward  Order = "forward"  // first to last frame
+	Reverse  Order = "reverse"  // last to first frame
+	PingPong Order = "pingpong" // first to last frame then back (the loop does not repeat the first and last frames)
+)
+
+// maxFrames is the highest number of frames of an animation
+const maxFrames = 1000
+
+// NewAnimation returns a still animation (a single frame)
+func NewAnimation() *Animation {
+	return &Animation{Frames: 1, FPS: 12, Order: Forward}
+}
+
+// UnmarshalJSON unmarshals the animation, missing values keeping their default (12 frames per second, forward)
+func (a *Animation) UnmarshalJSON(data []byte) error {
+	type Alias Animation
+	aux := struct {
+		Alias
+		Cameras []json.RawMessage `json:"cameras"`
+	}{Alias: Alias{Frames: 1, FPS: 12, Order: Forward}}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	*a = Animation(aux.Alias)
+	for i, data := range aux.Cameras {
+		c, err := camera.UnmarshalCamera(data)
+		if err != nil {
+			return fmt.Errorf("animation camera %v: %w", i, err)
+		}
+		a.Cameras = append(a.Cameras, c)
+	}
+	return nil
+}
+
+// MarshalJSON marshals the animation with its keyframes
+func (a Animation) MarshalJSON() ([]byte, error) {
+	type Alias Animation
+	return json.Marshal(struct {
+		Alias
+		Cameras []camera.Camera `json:"cameras,omitempty"`
+	}{Alias: Alias(a), Cameras: a.Cameras})
+}
+
+// Validate checks the animation
+func (a *Animation) Validate() error {
+	if a.Frames < 1 || a.Frames > maxFrames {
+		return fmt.Errorf("animation frames must be in [1,%v]: %v", maxFrames, a.Frames)
+	}
+	if a.FPS <= 0 || a.FPS > 120 {
+		return fmt.Errorf("animation fps must be in (0,120]: %v", a.FPS)
+	}
+	switch a.Order {
+	case Forward, Reverse, PingPong:
+	default:
+		return fmt.Errorf("unknown animation order: %s", a.Order)
+	}
+	return nil
+}
+
+// Sequence returns the indices of the frames in the order of the output
+func (a *Animation) Sequence() []int {
+	sequence := make([]int, 0, 2*a.Frames)
+	for f := 0; f < a.Frames; f++ {
+		sequence = append(sequence, f)
+	}
+	switch a.Order {
+	case Reverse:
+		slices.Reverse(sequence)
+	case PingPong:
+		for f := a.Frames - 2; f > 0; f-- {
+			sequence = append(sequence, f)
+		}
+	}
+	return sequence
+}
+
+// Camera returns the camera of the frame (fallback when the animation has no keyframe)
+func (a *Animation) Camera(frame int, fallback camera.Camera) camera.Camera {
+	if len(a.Cameras) == 0 {
+		return fallback
+	}
+	t := 0.0
+	if a.Frames > 1 {
+		t = float64(frame) / float64(a.Frames-1)
+	}
+	return camera.Interpolate(a.Cameras, t)
+}
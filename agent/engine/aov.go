@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+)
+
+// AOV identifies an arbitrary output variable: a per pixel buffer rendered along the beauty pass (for
+// compositing and denoising)
+type AOV string
+
+const (
+	Depth         AOV = "depth"    // distance from the camera to the first hit
+	Normal        AOV = "normal"   // world shading normal at the first hit
+	Albedo        AOV = "albedo"   // albedo of the material at the first hit (sky color when nothing was hit)
+	Position      AOV = "position" // world position of the first hit
+	ObjectIndex   AOV = "object"   // index of the first object hit in the world (-1 for the sky)
+	MaterialIndex AOV = "material" // index of the material of the first object hit (-1 for the sky)
+	SampleCount   AOV = "samples"  // number of samples cast through the pixel (varies with adaptive sampling)
+)
+
+// AOVs lists all the supported output variables
+var AOVs = []AOV{Depth, Normal, Albedo, Position, ObjectIndex, MaterialIndex, SampleCount}
+
+// Channels returns the names of the channels of the variable
+func (a AOV) Channels() []string {
+	switch a {
+	case Normal, Position:
+		return []string{"X", "Y", "Z"}
+	case Albedo:
+		return []string{"R", "G", "B"}
+	case Depth:
+		return []string{"Z"}
+	case ObjectIndex, MaterialIndex:
+		return []string{"id"}
+	default:
+		return []string{"count"}
+	}
+}
+
+// integral reports whether the variable holds integers (indices and counts), which must be stored exactly
+func (a AOV) integral() bool {
+	return a == ObjectIndex || a == MaterialIndex || a == SampleCount
+}
+
+// ValidateAOVs checks that every output variable is supported
+func ValidateAOVs(aovs []AOV) error {
+	for _, a := range aovs {
+		known := false
+		for _, k := range AOVs {
+			known = known || a == k
+		}
+		if !known {
+			return fmt.Errorf("unknown AOV: %s", a)
+		}
+	}
+	return nil
+}
+
+// WithAOVs renders the output variables along the beauty pass
+func WithAOVs(aovs ...AOV) SceneOption {
+	return func(scene *Scene) {
+		scene.aovs = aovs
+		scene.firstHits = slices.ContainsFunc(aovs, func(a AOV) bool { return a != SampleCount })
+	}
+}
+
+// WithMaterialIndices defines the material index of each object of the world (see World.MaterialIndices)
+func WithMaterialIndices(indices []int) SceneOption {
+	return func(scene *Scene) {
+		scene.materialIndices = indices
+	}
+}
+
+// features accumulates the first hit of the samples cast through a pixel
+type features struct {
+	samples          int // which reached the scene
+	hits             int
+	depth            float64
+	normal, position geometry.Vec3
+	albedo           clr.Color
+	object, material int // of the first sample
+}
+
+// firstHit accumulates the features of the first hit of the camera ray (hr is nil when the ray reaches the sky),
+// as found by its path
+func (scene *Scene) firstHit(r *geometry.Ray, hr *HitRecord, f *features) {
+	if f.samples == 0 {
+		f.object, f.material = -1, -1
+	}
+	f.samples++
+
+	if hr == nil {
+		f.albedo = f.albedo.Add(sky(r))
+		return
+	}
+
+	if np, ok := hr.Material.(normalPerturber); ok {
+		np.perturbNormal(r, hr)
+	}
+	f.hits++
+	f.depth += hr.T * r.Direction.Length()
+	f.normal = f.normal.Add(hr.Normal.Unit())
+	f.position = f.position.Add(hr.P.Vec3())
+	f.albedo = f.albedo.Add(materialAlbedo(hr.Material))
+	if f.samples == 1 {
+		f.object = hr.Object
+		f.material = hr.Object
+		if hr.Object < len(scene.materialIndices) {
+			f.material = scene.materialIndices[hr.Object]
+		}
+	}
+}
+
+// values returns the values of the output variable (the average of the samples)
+func (f *features) values(a AOV) []float32 {
+	n, hits := math.Max(float64(f.samples), 1), math.Max(float64(f.hits), 1)
+	switch a {
+	case Depth:
+		if f.hits == 0 {
+			return []float32{float32(math.Inf(1))}
+		}
+		return []float32{float32(f.depth / hits)}
+	case Normal:
+		v := f.normal.Scale(1.0 / n)
+		return []float32{float32(v.X), float32(v.Y), float32(v.Z)}
+	case Albedo:
+		c := f.albedo.Scale(1.0 / n)
+		return []float32{float32(c.R), float32(c.G), float32(c.B)}
+	case Position:
+		v := f.position.Scale(1.0 / hits)
+		return []float32{float32(v.X), float32(v.Y), float32(v.Z)}
+	case ObjectIndex:
+		return []float32{float32(f.object)}
+	case MaterialIndex:
+		return []float32{float32(f.material)}
+	}
+	// the sample count is a property of the pixel, not of its first hits
+	return nil
+}
+
+// materialAlbedo returns the color of the material regardless of the lighting (white for transparent materials)
+func materialAlbedo(mat Material) clr.Color {
+	if d, ok := mat.(detailedMaterial); ok {
+		mat = d.Material
+	}
+	switch m := mat.(type) {
+	case Lambertian:
+		return m.albedo
+	case Metal:
+		return m.albedo
+	case Isotropic:
+		return m.albedo
+	case HenyeyGreenstein:
+		return m.albedo
+	case gridPhase:
+		return m.albedo
+	default:
+		return clr.White
+	}
+}
@@ -0,0 +1,89 @@
+// Package assets resolves the files referenced by world definitions (textures, voxel grids...).
+//
+// World definitions come from the requests of the server: their files are relative paths resolved inside the
+// asset directory, so that a request cannot read any other file of the server (absolute paths, .. elements and
+// symbolic links leading outside the directory are rejected).
+package assets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	lock sync.RWMutex
+	dir  = "assets"
+)
+
+// SetDir sets the asset directory (assets by default, relative to the working directory)
+func SetDir(d string) {
+	lock.Lock()
+	defer lock.Unlock()
+	dir = d
+}
+
+// Dir returns the asset directory
+func Dir() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return dir
+}
+
+// Resolve returns the path of a file of the asset directory
+func Resolve(file string) (string, error) {
+	if file == "" {
+		return "", fmt.Errorf("file is missing")
+	}
+	if filepath.IsAbs(file) || strings.HasPrefix(file, "/") || strings.HasPrefix(file, `\`) {
+		return "", fmt.Errorf("%s: absolute paths are not allowed, files are relative to the asset directory", file)
+	}
+	for _, element := range strings.FieldsFunc(file, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if element == ".." {
+			return "", fmt.Errorf("%s: .. is not allowed, files are relative to the asset directory", file)
+		}
+	}
+	if !filepath.IsLocal(file) {
+		return "", fmt.Errorf("%s: invalid file name", file)
+	}
+
+	root := Dir()
+	path := filepath.Join(root, file)
+	// symbolic links must not lead outside of the directory either
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(realRoot, realPath); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%s: outside of the asset directory", file)
+	}
+	return path, nil
+}
+
+// Open opens a regular file of the asset directory
+func Open(file string) (*os.File, os.FileInfo, error) {
+	path, err := Resolve(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: not a regular file", file)
+	}
+	return f, info, nil
+}
@@ -0,0 +1,76 @@
+package assets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withDir sets the asset directory for the test (restored at the end)
+func withDir(t *testing.T, d string) {
+	previous := Dir()
+	SetDir(d)
+	t.Cleanup(func() { SetDir(previous) })
+}
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	withDir(t, filepath.Join(root, "assets"))
+	os.MkdirAll(filepath.Join(root, "assets", "textures"), 0o755)
+	for _, file := range []string{"secret.txt", "assets/grid.raw", "assets/textures/wood.png"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(root, "assets", "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		file  string
+		valid bool
+	}{
+		{"grid.raw", true},
+		{"textures/wood.png", true},
+		{"./textures/wood.png", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{filepath.Join(root, "secret.txt"), false},
+		{"../secret.txt", false},
+		{"textures/../grid.raw", false},
+		{"textures/../../secret.txt", false},
+		{`..\secret.txt`, false},
+		{"link.txt", false}, // symbolic link leading outside
+		{"missing.raw", false},
+	}
+
+	for _, tc := range cases {
+		path, err := Resolve(tc.file)
+		if tc.valid && err != nil {
+			t.Errorf("Expected %v to resolve, but got %v", tc.file, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("Expected an error for %v, but got %v", tc.file, path)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	root := t.TempDir()
+	withDir(t, root)
+	os.Mkdir(filepath.Join(root, "dir"), 0o755)
+	os.WriteFile(filepath.Join(root, "grid.raw"), []byte("data"), 0o644)
+
+	f, info, err := Open("grid.raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if info.Size() != 4 {
+		t.Errorf("Expected 4, but got %v", info.Size())
+	}
+
+	if _, _, err := Open("dir"); err == nil {
+		t.Errorf("Expected an error for a directory")
+	}
+}
@@ -2,12 +2,16 @@ package camera
 
 import (
 	"encoding/json"
+	"fmt"
 	"math"
 
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
 	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
 	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
 )
 
+// Camera casts the rays through the image, u and v in [0,1] being the coordinates in the image (from the bottom
+// left corner). It returns nil when there is no ray for u,v (outside of the image circle of a fisheye).
 type Camera interface {
 	Ray(rnd utils.Rnd, u, v float64) *geometry.Ray
 }
@@ -56,9 +60,70 @@ func (c camera) Ray(rnd utils.Rnd, u, v float64) *geometry.Ray {
 	return &geometry.Ray{Origin: origin, Direction: d, Rnd: rnd}
 }
 
-// UnmarshalJSON unmarshals JSON data into a Camera object
-func UnmarshalCamera(data json.RawMessage) Camera {
-	var c camera
-	json.Unmarshal(data, &c)
+// Weighted is implemented by cameras whose rays carry a different weight per channel
+//
+//	like Ray, WeightedRay returns nil outside of the image circle (white weight: the background is seen there),
+//	but also when the lens blocks the ray (black weight: the sample is black even over a transparent background)
+type Weighted interface {
+	WeightedRay(rnd utils.Rnd, u, v float64) (*geometry.Ray, clr.Color)
+}
+
+// WeightedRay returns the ray through u,v and its weight (white unless the camera is Weighted)
+func WeightedRay(c Camera, rnd utils.Rnd, u, v float64) (*geometry.Ray, clr.Color) {
+	if w, ok := c.(Weighted); ok {
+		return w.WeightedRay(rnd, u, v)
+	}
+	return c.Ray(rnd, u, v), clr.White
+}
+
+// Adjustable is implemented by cameras which depend on the aspect ratio (width / height) of the image
+type Adjustable interface {
+	WithAspect(aspect float64) Camera
+}
+
+// ForImage adjusts the camera to the aspect ratio of the image (when the camera depends on it)
+func ForImage(c Camera, width, height int) Camera {
+	if a, ok := c.(Adjustable); ok && width > 0 && height > 0 {
+		return a.WithAspect(float64(width) / float64(height))
+	}
 	return c
 }
+
+// UnmarshalCamera unmarshals JSON data into a Camera object
+//
+//	The camera is described either by its type and lookFrom/lookAt (see LookAt) or by the basis vectors of a
+//	perspective camera (origin, lowerLeftCorner, horizontal, vertical, u, v and lensRadius) as computed by
+//	NewCamera.
+func UnmarshalCamera(data json.RawMessage) (Camera, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("camera is missing")
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("camera: %w", err)
+	}
+
+	_, lookFrom := fields["lookFrom"]
+	_, typed := fields["type"]
+	if lookFrom || typed {
+		la := defaultLookAt()
+		if err := json.Unmarshal(data, &la); err != nil {
+			return nil, fmt.Errorf("camera: %w", err)
+		}
+		if err := la.Validate(); err != nil {
+			return nil, err
+		}
+		// the aspect ratio is adjusted to the image once known (see ForImage)
+		return newLookAtCamera(la, 2.0), nil
+	}
+
+	var c camera
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("camera: %w", err)
+	}
+	if c.Horizontal.NearZero() || c.Vertical.NearZero() {
+		return nil, fmt.Errorf("camera requires either lookFrom/lookAt or origin/lowerLeftCorner/horizontal/vertical")
+	}
+	return c, nil
+}
@@ -53,9 +53,57 @@ func TestUnmarshalCamera(t *testing.T) {
 		V:               geometry.Vec3{X: 0.0, Y: 1.0, Z: 0.0},
 	}
 
-	result := UnmarshalCamera(data)
+	result, err := UnmarshalCamera(data)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !reflect.DeepEqual(result, expectedCamera) {
 		t.Errorf("Expected camera %v, but got %v", expectedCamera, result)
 	}
 }
+
+func TestUnmarshalLookAtCamera(t *testing.T) {
+	data := []byte(`{"lookFrom":{"X":0,"Y":0,"Z":0},"lookAt":{"X":0,"Y":0,"Z":-1},"vfov":90}`)
+
+	result, err := UnmarshalCamera(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a square image: the corners of the image are at 45 degrees
+	c := ForImage(result, 100, 100)
+	ray := c.Ray(mockRnd{}, 1.0, 1.0)
+	expectedDirection := geometry.Vec3{X: 1.0, Y: 1.0, Z: -1.0}
+	if ray.Direction.Sub(expectedDirection).Length() > 1e-9 {
+		t.Errorf("Expected direction %v, but got %v", expectedDirection, ray.Direction)
+	}
+
+	// twice as wide: the horizontal field of view doubles
+	c = ForImage(result, 200, 100)
+	ray = c.Ray(mockRnd{}, 1.0, 1.0)
+	expectedDirection = geometry.Vec3{X: 2.0, Y: 1.0, Z: -1.0}
+	if ray.Direction.Sub(expectedDirection).Length() > 1e-9 {
+		t.Errorf("Expected direction %v, but got %v", expectedDirection, ray.Direction)
+	}
+}
+
+func TestUnmarshalCameraErrors(t *testing.T) {
+	cases := []string{
+		``,
+		`[1,2,3]`,
+		`{"lookFrom":{"X":0,"Y":0,"Z":0},"lookAt":{"X":0,"Y":0,"Z":0}}`,
+		`{"lookFrom":{"X":0,"Y":0,"Z":0},"lookAt":{"X":0,"Y":1,"Z":0}}`,
+		`{"lookFrom":{"X":0,"Y":0,"Z":0},"lookAt":{"X":0,"Y":0,"Z":-1},"vfov":180}`,
+		`{"lookFrom":{"X":"a"}}`,
+		`{"origin":{"X":0,"Y":0,"Z":0}}`,
+		`{"type":"pinhole","lookFrom":{"X":0,"Y":0,"Z":0},"lookAt":{"X":0,"Y":0,"Z":-1}}`,
+		`{"type":"orthographic","lookFrom":{"X":0,"Y":0,"Z":0},"lookAt":{"X":0,"Y":0,"Z":-1},"height":-1}`,
+	}
+
+	for _, tc := range cases {
+		if _, err := UnmarshalCamera([]byte(tc)); err == nil {
+			t.Errorf("Expected an error for %s", tc)
+		}
+	}
+}
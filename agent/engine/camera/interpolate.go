@@ -0,0 +1,50 @@
+package camera
+
+import (
+	"math"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+)
+
+// Interpolate returns the camera at t in [0,1] along the keyframes (evenly spaced from 0 to 1)
+//
+//	The descriptions of consecutive cameras (lookFrom, lookAt, vup, field of view, aperture...) are interpolated
+//	linearly, the type and lens being those of the previous keyframe. Cameras which are not described by
+//	lookFrom/lookAt cannot be interpolated: the closest keyframe is returned.
+func Interpolate(keyframes []Camera, t float64) Camera {
+	if len(keyframes) == 1 {
+		return keyframes[0]
+	}
+
+	t = math.Min(math.Max(t, 0), 1) * float64(len(keyframes)-1)
+	i := min(int(t), len(keyframes)-2)
+	s := t - float64(i)
+
+	a, okA := keyframes[i].(lookAtCamera)
+	b, okB := keyframes[i+1].(lookAtCamera)
+	if !okA || !okB {
+		if s < 0.5 {
+			return keyframes[i]
+		}
+		return keyframes[i+1]
+	}
+
+	lerp := func(x, y float64) float64 { return x + (y-x)*s }
+	da, db := a.description, b.description
+	d := da
+	d.LookFrom = da.LookFrom.Translate(db.LookFrom.Sub(da.LookFrom).Scale(s))
+	d.LookAt = da.LookAt.Translate(db.LookAt.Sub(da.LookAt).Scale(s))
+	d.Vup = lerpVec(da.Vup, db.Vup, s)
+	d.Vfov = lerp(da.Vfov, db.Vfov)
+	d.Aperture = lerp(da.Aperture, db.Aperture)
+	d.FocusDist = lerp(da.focusDist(), db.focusDist())
+	d.Height = lerp(da.Height, db.Height)
+	d.Fov = lerp(da.Fov, db.Fov)
+	d.Ipd = lerp(da.Ipd, db.Ipd)
+
+	return newLookAtCamera(d, 2.0)
+}
+
+func lerpVec(a, b geometry.Vec3, s float64) geometry.Vec3 {
+	return a.Add(b.Sub(a).Scale(s))
+}
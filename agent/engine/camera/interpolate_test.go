@@ -0,0 +1,50 @@
+package camera
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+)
+
+func TestInterpolate(t *testing.T) {
+	keyframe := func(x, vfov float64) Camera {
+		la := defaultLookAt()
+		la.LookFrom = geometry.Point3{X: x, Y: 0, Z: 10}
+		la.Vfov = vfov
+		return newLookAtCamera(la, 2.0)
+	}
+	keyframes := []Camera{keyframe(0, 20), keyframe(4, 40), keyframe(8, 40)}
+
+	cases := []struct {
+		t       float64
+		x, vfov float64
+	}{
+		{0, 0, 20},
+		{0.25, 2, 30},
+		{0.5, 4, 40},
+		{0.75, 6, 40},
+		{1, 8, 40},
+		{2, 8, 40},
+	}
+
+	for _, tc := range cases {
+		c := Interpolate(keyframes, tc.t).(lookAtCamera)
+		if math.Abs(c.description.LookFrom.X-tc.x) > 1e-9 || math.Abs(c.description.Vfov-tc.vfov) > 1e-9 {
+			t.Errorf("Expected x=%v vfov=%v at %v, but got %v", tc.x, tc.vfov, tc.t, c.description)
+		}
+	}
+}
+
+func TestInterpolateBasis(t *testing.T) {
+	a := NewCamera(geometry.Point3{}, geometry.Point3{Z: -1}, geometry.Vec3{Y: 1}, 20, 2, 0, 1)
+	b := NewCamera(geometry.Point3{X: 1}, geometry.Point3{Z: -1}, geometry.Vec3{Y: 1}, 20, 2, 0, 1)
+
+	// cameras described by their basis vectors are not interpolated
+	if c := Interpolate([]Camera{a, b}, 0.4); c != a {
+		t.Errorf("Expected the first keyframe, but got %v", c)
+	}
+	if c := Interpolate([]Camera{a, b}, 0.6); c != b {
+		t.Errorf("Expected the second keyframe, but got %v", c)
+	}
+}
@@ -0,0 +1,167 @@
+package camera
+
+import (
+	"fmt"
+	"math"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
+)
+
+// Distortion defines the Brown-Conrady lens distortion: k1, k2, k3 are the radial coefficients, p1, p2 the
+// tangential ones (applied to image coordinates normalized by half the height of the image)
+type Distortion struct {
+	K1 float64 `json:"k1,omitempty"`
+	K2 float64 `json:"k2,omitempty"`
+	K3 float64 `json:"k3,omitempty"`
+	P1 float64 `json:"p1,omitempty"`
+	P2 float64 `json:"p2,omitempty"`
+}
+
+// apply returns where the point x,y of the image looks at in the undistorted image (positive k1 gives a barrel
+// distortion, negative k1 a pincushion one)
+func (d Distortion) apply(x, y float64) (float64, float64) {
+	r2 := x*x + y*y
+	radial := 1 + r2*(d.K1+r2*(d.K2+r2*d.K3))
+	return x*radial + 2*d.P1*x*y + d.P2*(r2+2*x*x), y*radial + d.P1*(r2+2*y*y) + 2*d.P2*x*y
+}
+
+// Lens defines the imperfections of a perspective camera
+type Lens struct {
+	Blades              int         `json:"blades,omitempty"`              // number of aperture blades (0 for a round aperture)
+	BladeRotation       float64     `json:"bladeRotation,omitempty"`       // rotation of the aperture in degrees
+	Tilt                float64     `json:"tilt,omitempty"`                // rotation of the plane in focus around the horizontal axis in degrees (> 0: the top further away)
+	Swing               float64     `json:"swing,omitempty"`               // rotation of the plane in focus around the vertical axis in degrees (> 0: the right further away)
+	ShiftX              float64     `json:"shiftX,omitempty"`              // shift of the image (fraction of its width)
+	ShiftY              float64     `json:"shiftY,omitempty"`              // shift of the image (fraction of its height)
+	Distortion          *Distortion `json:"distortion,omitempty"`          // Brown-Conrady distortion
+	Vignetting          float64     `json:"vignetting,omitempty"`          // optical vignetting (clipping of the lens toward the edges)
+	ChromaticAberration float64     `json:"chromaticAberration,omitempty"` // difference of magnification between red and blue
+}
+
+// Validate checks the lens parameters
+func (l *Lens) Validate() error {
+	if l.Blades != 0 && l.Blades < 3 {
+		return fmt.Errorf("camera blades must be 0 (round aperture) or at least 3: %v", l.Blades)
+	}
+	if math.Abs(l.Tilt) >= 90 || math.Abs(l.Swing) >= 90 {
+		return fmt.Errorf("camera tilt and swing must be in (-90,90)")
+	}
+	if l.Vignetting < 0 {
+		return fmt.Errorf("camera vignetting must be positive: %v", l.Vignetting)
+	}
+	if math.Abs(l.ChromaticAberration) >= 1 {
+		return fmt.Errorf("camera chromaticAberration must be in (-1,1): %v", l.ChromaticAberration)
+	}
+	return nil
+}
+
+// thinLens is a perspective camera with a thin lens and its imperfections
+type thinLens struct {
+	basis
+	lens        Lens
+	aspect      float64
+	tanHalfFov  float64
+	lensRadius  float64
+	focusPoint  geometry.Point3 // a point of the plane in focus
+	focusNormal geometry.Vec3   // the normal of the plane in focus
+}
+
+func newThinLens(b basis, vfov float64, aspect float64, aperture float64, focusDist float64, lens Lens) thinLens {
+	forward := b.w.Negate()
+
+	// tilt rotates the plane in focus around u, swing around v
+	tilt := lens.Tilt * math.Pi / 180.0
+	swing := lens.Swing * math.Pi / 180.0
+	normal := forward.Scale(math.Cos(tilt)).Add(b.v.Scale(-math.Sin(tilt)))
+	normal = normal.Scale(math.Cos(swing)).Add(b.u.Scale(-math.Sin(swing)))
+
+	return thinLens{
+		basis:       b,
+		lens:        lens,
+		aspect:      aspect,
+		tanHalfFov:  math.Tan(vfov * math.Pi / 360.0),
+		lensRadius:  aperture / 2.0,
+		focusPoint:  b.origin.Translate(forward.Scale(focusDist)),
+		focusNormal: normal.Unit(),
+	}
+}
+
+// sampleAperture returns a random point on the aperture (unit disk or regular polygon inscribed in it)
+func (c thinLens) sampleAperture(rnd utils.Rnd) (float64, float64) {
+	n := c.lens.Blades
+	if n == 0 {
+		p := geometry.RandomInUnitDisk(rnd)
+		return p.X, p.Y
+	}
+
+	// pick one of the n triangles (center, corner i, corner i+1) then a point uniformly in that triangle
+	i := math.Min(math.Floor(rnd.Float64()*float64(n)), float64(n-1))
+	rotation := c.lens.BladeRotation * math.Pi / 180.0
+	a0 := rotation + 2*math.Pi*i/float64(n)
+	a1 := rotation + 2*math.Pi*(i+1)/float64(n)
+
+	s, t := utils.Get2D(rnd)
+	if s+t > 1 {
+		s, t = 1-s, 1-t
+	}
+	return s*math.Cos(a0) + t*math.Cos(a1), s*math.Sin(a0) + t*math.Sin(a1)
+}
+
+func (c thinLens) Ray(rnd utils.Rnd, u, v float64) *geometry.Ray {
+	r, _ := c.WeightedRay(rnd, u, v)
+	return r
+}
+
+// WeightedRay returns the ray through u,v and the weight of each channel (chromatic aberration makes each ray
+// carry a single channel). It returns nil and a black weight when the ray is blocked by the lens (vignetting).
+func (c thinLens) WeightedRay(rnd utils.Rnd, u, v float64) (*geometry.Ray, clr.Color) {
+	weight := clr.White
+
+	// image coordinates normalized by half the height of the image
+	x, y := (2*u-1)*c.aspect, 2*v-1
+
+	if c.lens.ChromaticAberration != 0 {
+		// each channel has its own magnification: red is magnified by 1+ca, green by 1 and blue by 1-ca
+		channel := math.Min(math.Floor(rnd.Float64()*3), 2)
+		scale := 1 + c.lens.ChromaticAberration*(1-channel)
+		x, y = x*scale, y*scale
+		weight = [3]clr.Color{{R: 3}, {G: 3}, {B: 3}}[int(channel)]
+	}
+
+	if c.lens.Distortion != nil {
+		x, y = c.lens.Distortion.apply(x, y)
+	}
+
+	x += 2 * c.lens.ShiftX * c.aspect
+	y += 2 * c.lens.ShiftY
+
+	// the ray through the center of the lens
+	dir := c.direction(x*c.tanHalfFov, y*c.tanHalfFov, 1)
+	if c.lensRadius <= 0 {
+		return &geometry.Ray{Origin: c.origin, Direction: dir, Rnd: rnd}, weight
+	}
+
+	// the point in focus is where the ray through the center of the lens crosses the plane in focus
+	denominator := geometry.Dot(dir, c.focusNormal)
+	if denominator <= 1e-8 {
+		return &geometry.Ray{Origin: c.origin, Direction: dir, Rnd: rnd}, weight
+	}
+	t := geometry.Dot(c.focusPoint.Sub(c.origin), c.focusNormal) / denominator
+	focus := c.origin.Translate(dir.Scale(t))
+
+	lx, ly := c.sampleAperture(rnd)
+
+	// optical vignetting: toward the edges of the image, the lens is seen through the barrel of the lens (a disk
+	// shifted proportionally to the position in the image) which clips it
+	if c.lens.Vignetting > 0 {
+		bx, by := lx+c.lens.Vignetting*x, ly+c.lens.Vignetting*y
+		if bx*bx+by*by > 1 {
+			return nil, clr.Black
+		}
+	}
+
+	origin := c.origin.Translate(c.u.Scale(lx * c.lensRadius)).Translate(c.v.Scale(ly * c.lensRadius))
+	return &geometry.Ray{Origin: origin, Direction: focus.Sub(origin), Rnd: rnd}, weight
+}
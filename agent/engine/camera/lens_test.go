@@ -0,0 +1,157 @@
+package camera
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+)
+
+func TestThinLensMatchesCamera(t *testing.T) {
+	lookFrom := geometry.Point3{X: 13, Y: 2, Z: 3}
+	lookAt := geometry.Point3{X: 0, Y: 0, Z: 0}
+	vup := geometry.Vec3{X: 0, Y: 1, Z: 0}
+
+	expected := NewCamera(lookFrom, lookAt, vup, 20, 2.0, 0, 10)
+	c := newThinLens(newBasis(lookFrom, lookAt, vup), 20, 2.0, 0, 10, Lens{})
+
+	for _, uv := range [][2]float64{{0, 0}, {0.5, 0.5}, {1, 1}, {0.2, 0.7}} {
+		r1 := expected.Ray(mockRnd{}, uv[0], uv[1])
+		r2 := c.Ray(mockRnd{}, uv[0], uv[1])
+		if r1.Origin != r2.Origin || !equalVec(r1.Direction.Unit(), r2.Direction.Unit()) {
+			t.Errorf("Expected ray %v at %v, but got %v", r1, uv, r2)
+		}
+	}
+}
+
+func TestThinLensFocus(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2024))
+
+	cases := []struct {
+		lens  Lens
+		u, v  float64
+		focus geometry.Point3
+	}{
+		// without tilt, the plane in focus is z = -5
+		{Lens{}, 0.5, 0.5, geometry.Point3{X: 0, Y: 0, Z: -5}},
+		{Lens{Blades: 6, BladeRotation: 15}, 0.5, 0.5, geometry.Point3{X: 0, Y: 0, Z: -5}},
+		// tilted by 45 degrees, the plane in focus goes through (0,0,-5) and (0,5,-10)
+		{Lens{Tilt: 45}, 0.5, 0.75, geometry.Point3{X: 0, Y: 5, Z: -10}},
+		{Lens{Tilt: 45}, 0.5, 0.25, geometry.Point3{X: 0, Y: -5.0 / 3.0, Z: -10.0 / 3.0}},
+	}
+
+	for _, tc := range cases {
+		c := newThinLens(testBasis, 90, 1.0, 1.0, 5, tc.lens)
+		for i := 0; i < 100; i++ {
+			r := c.Ray(rnd, tc.u, tc.v)
+			// every ray goes through the point in focus
+			toFocus := tc.focus.Sub(r.Origin)
+			if geometry.Cross(toFocus, r.Direction).Length() > 1e-9*toFocus.Length()*r.Direction.Length() {
+				t.Fatalf("Expected ray %v to go through %v", r, tc.focus)
+			}
+			// and comes from the aperture
+			if offset := r.Origin.Sub(testBasis.origin); offset.Length() > 0.5+1e-9 || math.Abs(offset.Z) > 1e-9 {
+				t.Fatalf("Expected ray %v to come from the lens", r)
+			}
+		}
+	}
+}
+
+func TestThinLensAperturePolygon(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2024))
+	c := thinLens{lens: Lens{Blades: 4}}
+
+	// a square with its corners on the axes: |x| + |y| <= 1
+	for i := 0; i < 1000; i++ {
+		x, y := c.sampleAperture(rnd)
+		if math.Abs(x)+math.Abs(y) > 1+1e-9 {
+			t.Fatalf("Expected sample (%v,%v) in the aperture", x, y)
+		}
+	}
+}
+
+func TestThinLensVignetting(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2024))
+	c := newThinLens(testBasis, 90, 1.0, 1.0, 5, Lens{Vignetting: 0.5})
+
+	blocked := func(u, v float64) int {
+		count := 0
+		for i := 0; i < 1000; i++ {
+			if r := c.Ray(rnd, u, v); r == nil {
+				count++
+			}
+		}
+		return count
+	}
+
+	if n := blocked(0.5, 0.5); n != 0 {
+		t.Errorf("Expected no vignetting at the center, but %v rays were blocked", n)
+	}
+	if n := blocked(1.0, 1.0); n == 0 {
+		t.Errorf("Expected vignetting in the corner")
+	}
+}
+
+func TestThinLensChromaticAberration(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2024))
+	c := newThinLens(testBasis, 90, 1.0, 0, 5, Lens{ChromaticAberration: 0.1})
+
+	var sum clr.Color
+	for i := 0; i < 3000; i++ {
+		r, weight := c.WeightedRay(rnd, 1.0, 0.5)
+		sum = sum.Add(weight)
+
+		// red is magnified: it looks further to the right (x = 1.1), blue closer to the center (x = 0.9)
+		expected := map[clr.Color]float64{{R: 3}: 1.1, {G: 3}: 1.0, {B: 3}: 0.9}[weight]
+		if x := r.Direction.X / -r.Direction.Z; math.Abs(x-expected) > 1e-9 {
+			t.Fatalf("Expected x=%v for weight %v, but got %v", expected, weight, x)
+		}
+	}
+
+	// on average each channel gets the same weight
+	sum = sum.Scale(1.0 / 3000)
+	if math.Abs(sum.R-1) > 0.1 || math.Abs(sum.G-1) > 0.1 || math.Abs(sum.B-1) > 0.1 {
+		t.Errorf("Expected an average weight of 1 per channel, but got %v", sum)
+	}
+}
+
+func TestDistortion(t *testing.T) {
+	cases := []struct {
+		d      Distortion
+		x, y   float64
+		ex, ey float64
+	}{
+		{Distortion{}, 0.5, 0.5, 0.5, 0.5},
+		{Distortion{K1: 0.1}, 1.0, 0.0, 1.1, 0.0},
+		{Distortion{K1: 0.1, K2: 0.01}, 0.0, 2.0, 0.0, 2.0 * (1 + 0.4 + 0.16)},
+		{Distortion{P1: 0.1}, 1.0, 0.0, 1.0, 0.1},
+		{Distortion{P2: 0.1}, 1.0, 0.0, 1.3, 0.0},
+	}
+
+	for _, tc := range cases {
+		x, y := tc.d.apply(tc.x, tc.y)
+		if math.Abs(x-tc.ex) > 1e-9 || math.Abs(y-tc.ey) > 1e-9 {
+			t.Errorf("Expected (%v,%v), but got (%v,%v)", tc.ex, tc.ey, x, y)
+		}
+	}
+}
+
+func TestThinLensVignettingWeight(t *testing.T) {
+	// a blocked ray is told apart from the background by its black weight
+	rnd := rand.New(rand.NewSource(2024))
+	c := newThinLens(testBasis, 90, 1.0, 1.0, 5, Lens{Vignetting: 0.5, ChromaticAberration: 0.1})
+	blocked := 0
+	for i := 0; i < 1000; i++ {
+		if r, weight := c.WeightedRay(rnd, 1.0, 1.0); r == nil {
+			blocked++
+			if weight != clr.Black {
+				t.Fatalf("Expected a black weight for a blocked ray, but got %v", weight)
+			}
+		}
+	}
+	if blocked == 0 {
+		t.Errorf("Expected vignetting in the corner")
+	}
+}
@@ -0,0 +1,154 @@
+package camera
+
+import (
+	"encoding/json"
+	"fmt"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
+)
+
+// types of cameras
+const (
+	Perspective     = "perspective"     // thin lens camera (default)
+	Orthographic    = "orthographic"    // parallel rays
+	Fisheye         = "fisheye"         // equidistant fisheye
+	Equirectangular = "equirectangular" // 360 degrees panorama
+	Stereo          = "stereo"          // omni-directional stereo 360 degrees panorama (left eye over right eye)
+)
+
+// LookAt describes a camera by its type, where it is, where it looks at and its lens (as opposed to the derived
+// basis vectors of the camera)
+type LookAt struct {
+	Type      string          `json:"type,omitempty"`
+	LookFrom  geometry.Point3 `json:"lookFrom"`
+	LookAt    geometry.Point3 `json:"lookAt"`
+	Vup       geometry.Vec3   `json:"vup"`                 // which way is up
+	Vfov      float64         `json:"vfov,omitempty"`      // perspective: vertical field of view in degrees
+	Aperture  float64         `json:"aperture,omitempty"`  // perspective: diameter of the lens (0 for a pinhole)
+	FocusDist float64         `json:"focusDist,omitempty"` // perspective: distance to the plane in focus
+	Height    float64         `json:"height,omitempty"`    // orthographic: height of the view in world units
+	Fov       float64         `json:"fov,omitempty"`       // fisheye: field of view (of the image circle) in degrees
+	Ipd       float64         `json:"ipd,omitempty"`       // stereo: distance between the eyes in world units
+	Lens                      // perspective: imperfections of the lens
+}
+
+// defaultLookAt returns the description with the default values of the optional fields
+func defaultLookAt() LookAt {
+	return LookAt{
+		Type:   Perspective,
+		Vup:    geometry.Vec3{X: 0, Y: 1, Z: 0},
+		Vfov:   20,
+		Height: 2,
+		Fov:    180,
+		Ipd:    0.064,
+	}
+}
+
+// Validate checks that the description defines a proper camera
+func (la *LookAt) Validate() error {
+	w := la.LookFrom.Sub(la.LookAt)
+	if w.NearZero() {
+		return fmt.Errorf("camera lookFrom and lookAt must be different")
+	}
+	if geometry.Cross(la.Vup, w).NearZero() {
+		return fmt.Errorf("camera vup must not be parallel to the view direction")
+	}
+
+	switch la.Type {
+	case Perspective:
+		if la.Vfov <= 0 || la.Vfov >= 180 {
+			return fmt.Errorf("camera vfov must be in (0,180): %v", la.Vfov)
+		}
+		if la.Aperture < 0 {
+			return fmt.Errorf("camera aperture must be positive: %v", la.Aperture)
+		}
+		if la.FocusDist < 0 {
+			return fmt.Errorf("camera focusDist must be positive: %v", la.FocusDist)
+		}
+		if err := la.Lens.Validate(); err != nil {
+			return err
+		}
+	case Orthographic:
+		if la.Height <= 0 {
+			return fmt.Errorf("camera height must be positive: %v", la.Height)
+		}
+	case Fisheye:
+		if la.Fov <= 0 || la.Fov > 360 {
+			return fmt.Errorf("camera fov must be in (0,360]: %v", la.Fov)
+		}
+	case Equirectangular:
+	case Stereo:
+		if la.Ipd < 0 {
+			return fmt.Errorf("camera ipd must be positive: %v", la.Ipd)
+		}
+	default:
+		return fmt.Errorf("unknown camera type: %s", la.Type)
+	}
+	return nil
+}
+
+// focusDist returns the focus distance (the distance to lookAt when not specified)
+func (la *LookAt) focusDist() float64 {
+	if la.FocusDist > 0 {
+		return la.FocusDist
+	}
+	return la.LookFrom.Sub(la.LookAt).Length()
+}
+
+// lookAtCamera is a camera defined by a LookAt description, built for a given aspect ratio
+type lookAtCamera struct {
+	description LookAt
+	camera      Camera
+}
+
+func newLookAtCamera(description LookAt, aspect float64) lookAtCamera {
+	d := description
+	b := newBasis(d.LookFrom, d.LookAt, d.Vup)
+
+	var c Camera
+	switch d.Type {
+	case Orthographic:
+		c = newOrthographic(b, d.Height, aspect)
+	case Fisheye:
+		c = newFisheye(b, d.Fov, aspect)
+	case Equirectangular:
+		c = equirectangular{basis: b}
+	case Stereo:
+		c = stereo{basis: b, ipd: d.Ipd}
+	default:
+		c = newThinLens(b, d.Vfov, aspect, d.Aperture, d.focusDist(), d.Lens)
+	}
+
+	return lookAtCamera{description: d, camera: c}
+}
+
+func (c lookAtCamera) MarshalJSON() ([]byte, error) {
+	// only keep the fields relevant to the type of camera
+	d := LookAt{Type: c.description.Type, LookFrom: c.description.LookFrom, LookAt: c.description.LookAt, Vup: c.description.Vup}
+	switch d.Type {
+	case Perspective:
+		d.Vfov, d.Aperture, d.FocusDist = c.description.Vfov, c.description.Aperture, c.description.FocusDist
+		d.Lens = c.description.Lens
+	case Orthographic:
+		d.Height = c.description.Height
+	case Fisheye:
+		d.Fov = c.description.Fov
+	case Stereo:
+		d.Ipd = c.description.Ipd
+	}
+	return json.Marshal(d)
+}
+
+func (c lookAtCamera) Ray(rnd utils.Rnd, u, v float64) *geometry.Ray {
+	return c.camera.Ray(rnd, u, v)
+}
+
+func (c lookAtCamera) WeightedRay(rnd utils.Rnd, u, v float64) (*geometry.Ray, clr.Color) {
+	return WeightedRay(c.camera, rnd, u, v)
+}
+
+func (c lookAtCamera) WithAspect(aspect float64) Camera {
+	return newLookAtCamera(c.description, aspect)
+}
@@ -0,0 +1,121 @@
+package camera
+
+import (
+	"math"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
+)
+
+// basis is the orthonormal basis of a camera: u points right, v points up and the camera looks along -w
+type basis struct {
+	origin  geometry.Point3
+	u, v, w geometry.Vec3
+}
+
+func newBasis(lookFrom geometry.Point3, lookAt geometry.Point3, vup geometry.Vec3) basis {
+	w := lookFrom.Sub(lookAt).Unit()
+	u := geometry.Cross(vup, w).Unit()
+	v := geometry.Cross(w, u)
+	return basis{origin: lookFrom, u: u, v: v, w: w}
+}
+
+// direction converts a direction expressed in the camera space (x right, y up, z forward) to world space
+func (b basis) direction(x, y, z float64) geometry.Vec3 {
+	return b.u.Scale(x).Add(b.v.Scale(y)).Add(b.w.Scale(-z))
+}
+
+// orthographic is a camera casting parallel rays from a rectangle (no perspective)
+type orthographic struct {
+	basis
+	halfWidth, halfHeight float64
+}
+
+func newOrthographic(b basis, height float64, aspect float64) orthographic {
+	return orthographic{basis: b, halfWidth: aspect * height / 2.0, halfHeight: height / 2.0}
+}
+
+func (c orthographic) Ray(rnd utils.Rnd, u, v float64) *geometry.Ray {
+	offset := c.u.Scale((2*u - 1) * c.halfWidth).Add(c.v.Scale((2*v - 1) * c.halfHeight))
+	return &geometry.Ray{Origin: c.origin.Translate(offset), Direction: c.w.Negate(), Rnd: rnd}
+}
+
+// fisheye is an equidistant fisheye camera: the distance to the center of the image is proportional to the angle
+// with the view direction. The image circle fits the smallest dimension of the image.
+type fisheye struct {
+	basis
+	halfFov float64 // in radians
+	aspect  float64
+}
+
+func newFisheye(b basis, fov float64, aspect float64) fisheye {
+	return fisheye{basis: b, halfFov: fov * math.Pi / 360.0, aspect: aspect}
+}
+
+// Ray returns nil outside of the image circle
+func (c fisheye) Ray(rnd utils.Rnd, u, v float64) *geometry.Ray {
+	x, y := 2*u-1, 2*v-1
+	if c.aspect > 1 {
+		x *= c.aspect
+	} else {
+		y /= c.aspect
+	}
+
+	radius := math.Sqrt(x*x + y*y)
+	if radius > 1 {
+		return nil
+	}
+
+	theta := radius * c.halfFov
+	phi := math.Atan2(y, x)
+	sinTheta := math.Sin(theta)
+	dir := c.direction(sinTheta*math.Cos(phi), sinTheta*math.Sin(phi), math.Cos(theta))
+	return &geometry.Ray{Origin: c.origin, Direction: dir, Rnd: rnd}
+}
+
+// equirectangular is a 360 degrees panoramic camera: u maps to the longitude, v to the latitude (the view
+// direction being at the center of the image)
+type equirectangular struct {
+	basis
+}
+
+// angles returns the longitude and latitude corresponding to u,v
+func angles(u, v float64) (float64, float64) {
+	return (u - 0.5) * 2 * math.Pi, (v - 0.5) * math.Pi
+}
+
+// panoramaDirection returns the direction in camera space for the longitude and latitude
+func panoramaDirection(longitude, latitude float64) (float64, float64, float64) {
+	cosLat := math.Cos(latitude)
+	return cosLat * math.Sin(longitude), math.Sin(latitude), cosLat * math.Cos(longitude)
+}
+
+func (c equirectangular) Ray(rnd utils.Rnd, u, v float64) *geometry.Ray {
+	dir := c.direction(panoramaDirection(angles(u, v)))
+	return &geometry.Ray{Origin: c.origin, Direction: dir, Rnd: rnd}
+}
+
+// stereo is an omni-directional stereo (ODS) panoramic camera producing an over/under image: the left eye at
+// the top, the right eye at the bottom, each one being an equirectangular panorama seen from eyes rotating
+// around the origin (ipd being the distance between the eyes)
+type stereo struct {
+	basis
+	ipd float64
+}
+
+func (c stereo) Ray(rnd utils.Rnd, u, v float64) *geometry.Ray {
+	side := 1.0 // right eye
+	if v >= 0.5 {
+		side = -1.0 // left eye
+		v = (v - 0.5) * 2
+	} else {
+		v = v * 2
+	}
+
+	longitude, latitude := angles(u, v)
+	right := c.direction(math.Cos(longitude), 0, -math.Sin(longitude))
+	origin := c.origin.Translate(right.Scale(side * c.ipd / 2))
+
+	dir := c.direction(panoramaDirection(longitude, latitude))
+	return &geometry.Ray{Origin: origin, Direction: dir, Rnd: rnd}
+}
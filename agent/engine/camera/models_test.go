@@ -0,0 +1,97 @@
+package camera
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+)
+
+func equalVec(v1, v2 geometry.Vec3) bool {
+	return v1.Sub(v2).Length() < 1e-9
+}
+
+// testBasis looks from the origin along -Z with Y up (u = X, v = Y)
+var testBasis = newBasis(geometry.Point3{X: 0, Y: 0, Z: 0}, geometry.Point3{X: 0, Y: 0, Z: -1}, geometry.Vec3{X: 0, Y: 1, Z: 0})
+
+func TestOrthographicRay(t *testing.T) {
+	c := newOrthographic(testBasis, 2.0, 2.0)
+
+	ray := c.Ray(mockRnd{}, 1.0, 0.5)
+	if expected := (geometry.Point3{X: 2, Y: 0, Z: 0}); ray.Origin != expected {
+		t.Errorf("Expected origin %v, but got %v", expected, ray.Origin)
+	}
+	if expected := (geometry.Vec3{X: 0, Y: 0, Z: -1}); !equalVec(ray.Direction, expected) {
+		t.Errorf("Expected direction %v, but got %v", expected, ray.Direction)
+	}
+}
+
+func TestFisheyeRay(t *testing.T) {
+	c := newFisheye(testBasis, 180, 2.0)
+
+	cases := []struct {
+		u, v     float64
+		expected geometry.Vec3
+	}{
+		{0.5, 0.5, geometry.Vec3{X: 0, Y: 0, Z: -1}},  // center
+		{0.5, 1.0, geometry.Vec3{X: 0, Y: 1, Z: 0}},   // top of the image circle: 90 degrees up
+		{0.25, 0.5, geometry.Vec3{X: -1, Y: 0, Z: 0}}, // left of the image circle (image twice as wide)
+	}
+
+	for _, tc := range cases {
+		ray := c.Ray(mockRnd{}, tc.u, tc.v)
+		if ray == nil || !equalVec(ray.Direction, tc.expected) {
+			t.Errorf("Expected direction %v at (%v,%v), but got %v", tc.expected, tc.u, tc.v, ray)
+		}
+	}
+
+	if ray := c.Ray(mockRnd{}, 0.0, 0.5); ray != nil {
+		t.Errorf("Expected no ray outside of the image circle, but got %v", ray)
+	}
+}
+
+func TestEquirectangularRay(t *testing.T) {
+	c := equirectangular{basis: testBasis}
+
+	cases := []struct {
+		u, v     float64
+		expected geometry.Vec3
+	}{
+		{0.5, 0.5, geometry.Vec3{X: 0, Y: 0, Z: -1}},  // view direction at the center
+		{0.75, 0.5, geometry.Vec3{X: 1, Y: 0, Z: 0}},  // right
+		{0.25, 0.5, geometry.Vec3{X: -1, Y: 0, Z: 0}}, // left
+		{0.0, 0.5, geometry.Vec3{X: 0, Y: 0, Z: 1}},   // behind
+		{0.5, 1.0, geometry.Vec3{X: 0, Y: 1, Z: 0}},   // up
+	}
+
+	for _, tc := range cases {
+		ray := c.Ray(mockRnd{}, tc.u, tc.v)
+		if !equalVec(ray.Direction, tc.expected) {
+			t.Errorf("Expected direction %v at (%v,%v), but got %v", tc.expected, tc.u, tc.v, ray.Direction)
+		}
+	}
+}
+
+func TestStereoRay(t *testing.T) {
+	c := stereo{basis: testBasis, ipd: 0.1}
+
+	// looking forward: the left eye (top half) is on the left, the right eye (bottom half) on the right
+	left := c.Ray(mockRnd{}, 0.5, 0.75)
+	right := c.Ray(mockRnd{}, 0.5, 0.25)
+
+	if expected := (geometry.Point3{X: -0.05, Y: 0, Z: 0}); !equalVec(left.Origin.Vec3(), expected.Vec3()) {
+		t.Errorf("Expected left eye at %v, but got %v", expected, left.Origin)
+	}
+	if expected := (geometry.Point3{X: 0.05, Y: 0, Z: 0}); !equalVec(right.Origin.Vec3(), expected.Vec3()) {
+		t.Errorf("Expected right eye at %v, but got %v", expected, right.Origin)
+	}
+	if !equalVec(left.Direction, right.Direction) || !equalVec(left.Direction, geometry.Vec3{X: 0, Y: 0, Z: -1}) {
+		t.Errorf("Expected both eyes to look forward, but got %v and %v", left.Direction, right.Direction)
+	}
+
+	// looking right: the eyes are in front and behind
+	left = c.Ray(mockRnd{}, 0.75, 0.75)
+	if expected := (geometry.Point3{X: 0, Y: 0, Z: -0.05}); !equalVec(left.Origin.Vec3(), expected.Vec3()) || math.Abs(left.Direction.X-1) > 1e-9 {
+		t.Errorf("Expected left eye at %v looking right, but got %v", expected, left)
+	}
+}
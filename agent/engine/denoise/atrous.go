@@ -0,0 +1,146 @@
+package denoise
+
+import (
+	"fmt"
+	"math"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+)
+
+// Settings defines the edge-avoiding à-trous wavelet filter
+//
+//	Iterations is the number of passes (the footprint of the filter doubles at each pass: 5 passes cover 125
+//	pixels). The sigmas define how fast the weight of a neighbour drops with its difference of color, normal
+//	and albedo (lower values preserve more edges but remove less noise).
+type Settings struct {
+	Iterations  int     `json:"iterations"`
+	SigmaColor  float64 `json:"sigmaColor"`
+	SigmaNormal float64 `json:"sigmaNormal"`
+	SigmaAlbedo float64 `json:"sigmaAlbedo"`
+}
+
+// DefaultSettings returns settings suited to renders with a few dozen samples per pixel
+func DefaultSettings() Settings {
+	return Settings{Iterations: 5, SigmaColor: 0.5, SigmaNormal: 0.3, SigmaAlbedo: 0.1}
+}
+
+// Validate checks the settings
+func (s *Settings) Validate() error {
+	if s.Iterations < 1 || s.Iterations > 10 {
+		return fmt.Errorf("denoise iterations must be in [1,10]: %v", s.Iterations)
+	}
+	if s.SigmaColor <= 0 || s.SigmaNormal <= 0 || s.SigmaAlbedo <= 0 {
+		return fmt.Errorf("denoise sigmas must be positive")
+	}
+	return nil
+}
+
+// kernel is the B3 spline used by the à-trous wavelet transform
+var kernel = [5]float64{1.0 / 16, 1.0 / 4, 3.0 / 8, 1.0 / 4, 1.0 / 16}
+
+// albedoEpsilon avoids dividing by a black albedo when demodulating
+const albedoEpsilon = 0.01
+
+// ATrous denoises the image (width x height colors, line by line) guided by the albedo and the normal of the
+// first hit of each pixel (Dammertz et al. 2010, "Edge-Avoiding À-Trous Wavelet Transform for fast Global
+// Illumination Filtering").
+//
+//	The color is divided by the albedo before filtering so that textures are not blurred, then multiplied back.
+//	Color differences are computed on compressed values (c / (1 + c)) so that bright pixels (fireflies) do not
+//	dominate.
+func ATrous(width, height int, color, albedo []clr.Color, normal []geometry.Vec3, s Settings) []clr.Color {
+	n := width * height
+	irradiance := make([]clr.Color, n)
+	for k := range irradiance {
+		irradiance[k] = demodulate(color[k], albedo[k])
+	}
+
+	next := make([]clr.Color, n)
+	sigmaColor := s.SigmaColor
+	for i := 0; i < s.Iterations; i++ {
+		step := 1 << i
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				k := y*width + x
+				cp, np, ap := compress(irradiance[k]), normal[k], albedo[k]
+
+				sum, weights := clr.Black, 0.0
+				for dy := -2; dy <= 2; dy++ {
+					qy := y + dy*step
+					if qy < 0 || qy >= height {
+						continue
+					}
+					for dx := -2; dx <= 2; dx++ {
+						qx := x + dx*step
+						if qx < 0 || qx >= width {
+							continue
+						}
+						q := qy*width + qx
+
+						w := kernel[dx+2] * kernel[dy+2]
+						w *= math.Exp(-distance(cp, compress(irradiance[q])) / (sigmaColor * sigmaColor))
+						w *= math.Exp(-np.Sub(normal[q]).LengthSq() / (s.SigmaNormal * s.SigmaNormal))
+						w *= math.Exp(-distance(ap, albedo[q]) / (s.SigmaAlbedo * s.SigmaAlbedo))
+
+						sum = sum.Add(irradiance[q].Scale(w))
+						weights += w
+					}
+				}
+				// the pixel itself always has a positive weight
+				next[k] = sum.Scale(1.0 / weights)
+			}
+		}
+		irradiance, next = next, irradiance
+		// finer details remain at coarser scales: be more selective
+		sigmaColor /= 2
+	}
+
+	result := make([]clr.Color, n)
+	for k := range result {
+		result[k] = modulate(irradiance[k], albedo[k])
+	}
+	return result
+}
+
+func demodulate(c, albedo clr.Color) clr.Color {
+	return clr.Color{
+		R: c.R / (albedo.R + albedoEpsilon),
+		G: c.G / (albedo.G + albedoEpsilon),
+		B: c.B / (albedo.B + albedoEpsilon),
+	}
+}
+
+func modulate(c, albedo clr.Color) clr.Color {
+	return clr.Color{
+		R: c.R * (albedo.R + albedoEpsilon),
+		G: c.G * (albedo.G + albedoEpsilon),
+		B: c.B * (albedo.B + albedoEpsilon),
+	}
+}
+
+func compress(c clr.Color) clr.Color {
+	return clr.Color{R: c.R / (1 + c.R), G: c.G / (1 + c.G), B: c.B / (1 + c.B)}
+}
+
+// distance returns the squared distance between two colors
+func distance(c1, c2 clr.Color) float64 {
+	return (c1.R-c2.R)*(c1.R-c2.R) + (c1.G-c2.G)*(c1.G-c2.G) + (c1.B-c2.B)*(c1.B-c2.B)
+}
+
+// RelativeMSE returns the relative mean squared error of an image compared to a reference (the squared error of
+// each component divided by the squared reference value, plus epsilon for dark values)
+func RelativeMSE(image, reference []clr.Color) float64 {
+	const epsilon = 0.01
+	sum := 0.0
+	for k := range image {
+		sum += relativeError(image[k].R, reference[k].R, epsilon)
+		sum += relativeError(image[k].G, reference[k].G, epsilon)
+		sum += relativeError(image[k].B, reference[k].B, epsilon)
+	}
+	return sum / float64(3*len(image))
+}
+
+func relativeError(v, reference, epsilon float64) float64 {
+	return (v - reference) * (v - reference) / (reference*reference + epsilon)
+}
@@ -0,0 +1,107 @@
+package denoise
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+)
+
+const size = 64
+
+// scene is a synthetic render: two walls (different normals) with a checker texture, lit by a smooth gradient
+// on the left wall and a constant light on the right wall
+func scene() (albedo []clr.Color, normal []geometry.Vec3, irradiance []float64) {
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if (x/8+y/8)%2 == 0 {
+				albedo = append(albedo, clr.Color{R: 0.8, G: 0.2, B: 0.2})
+			} else {
+				albedo = append(albedo, clr.Color{R: 0.2, G: 0.8, B: 0.2})
+			}
+			if x < size/2 {
+				normal = append(normal, geometry.Vec3{Y: 1})
+				irradiance = append(irradiance, 1.0+float64(x)/size)
+			} else {
+				normal = append(normal, geometry.Vec3{X: 1})
+				irradiance = append(irradiance, 0.3)
+			}
+		}
+	}
+	return
+}
+
+// render estimates each pixel with samples: every sample is the expected color times an exponentially
+// distributed factor (mean 1), which is as noisy as diffuse path tracing
+func render(rnd *rand.Rand, albedo []clr.Color, irradiance []float64, samples int) []clr.Color {
+	c := make([]clr.Color, len(albedo))
+	for k := range c {
+		sum := clr.Black
+		for s := 0; s < samples; s++ {
+			sum = sum.Add(albedo[k].Scale(irradiance[k] * rnd.ExpFloat64()))
+		}
+		c[k] = sum.Scale(1.0 / float64(samples))
+	}
+	return c
+}
+
+func TestATrous(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2024))
+	albedo, normal, irradiance := scene()
+
+	reference := render(rnd, albedo, irradiance, 4096)
+	noisy := render(rnd, albedo, irradiance, 8)
+	denoised := ATrous(size, size, noisy, albedo, normal, DefaultSettings())
+
+	noisyError, denoisedError := RelativeMSE(noisy, reference), RelativeMSE(denoised, reference)
+	if denoisedError > noisyError/20 {
+		t.Errorf("Expected the error to drop below %v, but got %v", noisyError/20, denoisedError)
+	}
+
+	// the edge between the walls must not bleed: compare the columns on each side
+	for _, x := range []int{size/2 - 1, size / 2} {
+		column, expected := make([]clr.Color, 0, size), make([]clr.Color, 0, size)
+		for y := 0; y < size; y++ {
+			column = append(column, denoised[y*size+x])
+			expected = append(expected, reference[y*size+x])
+		}
+		if e := RelativeMSE(column, expected); e > noisyError/4 {
+			t.Errorf("Expected the edge to be preserved, but got an error of %v in column %v", e, x)
+		}
+	}
+}
+
+func TestATrousConstant(t *testing.T) {
+	albedo, normal, _ := scene()
+	c := make([]clr.Color, len(albedo))
+	for k := range c {
+		c[k] = albedo[k].Scale(0.5)
+	}
+
+	denoised := ATrous(size, size, c, albedo, normal, DefaultSettings())
+	for k := range c {
+		if math.Abs(denoised[k].R-c[k].R) > 1e-9 || math.Abs(denoised[k].G-c[k].G) > 1e-9 {
+			t.Fatalf("Expected %v, but got %v", c[k], denoised[k])
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		settings Settings
+		valid    bool
+	}{
+		{DefaultSettings(), true},
+		{Settings{Iterations: 0, SigmaColor: 1, SigmaNormal: 1, SigmaAlbedo: 1}, false},
+		{Settings{Iterations: 11, SigmaColor: 1, SigmaNormal: 1, SigmaAlbedo: 1}, false},
+		{Settings{Iterations: 3, SigmaColor: 0, SigmaNormal: 1, SigmaAlbedo: 1}, false},
+	}
+
+	for _, test := range tests {
+		if err := test.settings.Validate(); (err == nil) != test.valid {
+			t.Errorf("Expected valid=%v for %v, but got %v", test.valid, test.settings, err)
+		}
+	}
+}
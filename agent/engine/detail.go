@@ -0,0 +1,206 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/texture"
+)
+
+// surfaceDetail defines how a texture adds shading detail to a surface by perturbing its shading normal
+type surfaceDetail interface {
+	perturb(rec *HitRecord) geometry.Vec3
+}
+
+// NormalMap perturbs the normal using a tangent space normal map (RGB encoding of the normal, Z up / green up)
+type NormalMap struct {
+	file     string
+	strength float64
+	tex      *texture.Image
+}
+
+func (nm NormalMap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		File     string  `json:"file"`
+		Strength float64 `json:"strength"`
+	}{
+		File:     nm.file,
+		Strength: nm.strength,
+	})
+}
+
+func (nm NormalMap) perturb(rec *HitRecord) geometry.Vec3 {
+	c := nm.tex.Value(rec.U, rec.V)
+	tangent := geometry.Vec3{X: 2*c.R - 1, Y: 2*c.G - 1, Z: 2*c.B - 1}
+
+	// strength blends between the flat normal (0) and the mapped one (1)
+	tangent = geometry.Vec3{X: tangent.X * nm.strength, Y: tangent.Y * nm.strength, Z: tangent.Z}
+
+	n := rec.Normal
+	t, b := tangentFrame(rec)
+	return t.Scale(tangent.X).Add(b.Scale(tangent.Y)).Add(n.Scale(tangent.Z)).Unit()
+}
+
+// BumpMap perturbs the normal using the gradient of a height map, scale being the height (in world units) of a
+// white texel
+type BumpMap struct {
+	file  string
+	scale float64
+	tex   *texture.Image
+}
+
+func (bm BumpMap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		File  string  `json:"file"`
+		Scale float64 `json:"scale"`
+	}{
+		File:  bm.file,
+		Scale: bm.scale,
+	})
+}
+
+func (bm BumpMap) perturb(rec *HitRecord) geometry.Vec3 {
+	width, height := bm.tex.Size()
+	du := 0.5 / float64(width)
+	dv := 0.5 / float64(height)
+
+	h := bm.tex.Height(rec.U, rec.V)
+	dhdu := (bm.tex.Height(rec.U+du, rec.V) - h) / du * bm.scale
+	dhdv := (bm.tex.Height(rec.U, rec.V+dv) - h) / dv * bm.scale
+
+	// derivatives of the displaced surface p + h*n (ignoring the variation of n which is negligible)
+	dpdu := rec.Dpdu.Add(rec.Normal.Scale(dhdu))
+	dpdv := rec.Dpdv.Add(rec.Normal.Scale(dhdv))
+
+	n := geometry.Cross(dpdu, dpdv)
+	if n.NearZero() {
+		return rec.Normal
+	}
+	n = n.Unit()
+	if geometry.Dot(n, rec.Normal) < 0 {
+		n = n.Negate()
+	}
+	return n
+}
+
+// tangentFrame returns the orthonormal tangent and bitangent at the hit point, built from dpdu and the normal
+func tangentFrame(rec *HitRecord) (geometry.Vec3, geometry.Vec3) {
+	n := rec.Normal
+	t := rec.Dpdu.Sub(n.Scale(geometry.Dot(rec.Dpdu, n)))
+	if t.NearZero() {
+		// no usable parametrization: pick any vector orthogonal to the normal
+		t = geometry.Cross(geometry.Vec3{X: 0, Y: 1, Z: 0}, n)
+		if t.NearZero() {
+			t = geometry.Cross(geometry.Vec3{X: 1, Y: 0, Z: 0}, n)
+		}
+	}
+	t = t.Unit()
+	return t, geometry.Cross(n, t)
+}
+
+// detailedMaterial decorates a material with a surface detail (normal or bump map)
+type detailedMaterial struct {
+	Material
+	detail surfaceDetail
+}
+
+func (mat detailedMaterial) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(mat.Material)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	detail, err := json.Marshal(mat.detail)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mat.detail.(type) {
+	case NormalMap:
+		fields["normalMap"] = detail
+	case BumpMap:
+		fields["bumpMap"] = detail
+	}
+
+	return json.Marshal(fields)
+}
+
+// perturbNormal replaces the shading normal of the record with the one computed by the surface detail.
+//
+//	To avoid the shadow terminator artifacts (black facets where the perturbed normal faces away from the ray
+//	while the actual surface does not), the shading normal is bent back so that it always sees the incoming ray
+//	on the same side as the geometric normal does. Materials are then responsible for keeping the scattered rays
+//	on the correct side of the geometric surface.
+func (mat detailedMaterial) perturbNormal(r *geometry.Ray, rec *HitRecord) {
+	ng := rec.GeometricNormal
+	ns := mat.detail.perturb(rec)
+
+	wi := r.Direction.Unit().Negate()
+	side := 1.0
+	if geometry.Dot(wi, ng) < 0 {
+		side = -1.0
+	}
+
+	const minCosine = 0.01
+	if cosine := side * geometry.Dot(wi, ns); cosine < minCosine {
+		ns = ns.Add(wi.Scale(side * (minCosine - cosine))).Unit()
+	}
+
+	rec.Normal = ns
+}
+
+// normalPerturber is implemented by materials changing the shading normal before scattering
+type normalPerturber interface {
+	perturbNormal(r *geometry.Ray, rec *HitRecord)
+}
+
+// unmarshalDetail decorates the material with the normal map or bump map defined in data (if any)
+func unmarshalDetail(mat Material, data json.RawMessage) (Material, error) {
+	var d struct {
+		NormalMap *struct {
+			File     string   `json:"file"`
+			Strength *float64 `json:"strength"`
+		} `json:"normalMap"`
+		BumpMap *struct {
+			File  string  `json:"file"`
+			Scale float64 `json:"scale"`
+		} `json:"bumpMap"`
+	}
+
+	err := json.Unmarshal(data, &d)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case d.NormalMap != nil && d.BumpMap != nil:
+		return nil, fmt.Errorf("material cannot have both a normalMap and a bumpMap")
+
+	case d.NormalMap != nil:
+		tex, err := texture.Load(d.NormalMap.File)
+		if err != nil {
+			return nil, fmt.Errorf("normalMap: %w", err)
+		}
+		strength := 1.0
+		if d.NormalMap.Strength != nil {
+			strength = *d.NormalMap.Strength
+		}
+		return detailedMaterial{Material: mat, detail: NormalMap{file: d.NormalMap.File, strength: strength, tex: tex}}, nil
+
+	case d.BumpMap != nil:
+		tex, err := texture.Load(d.BumpMap.File)
+		if err != nil {
+			return nil, fmt.Errorf("bumpMap: %w", err)
+		}
+		return detailedMaterial{Material: mat, detail: BumpMap{file: d.BumpMap.File, scale: d.BumpMap.Scale, tex: tex}}, nil
+
+	default:
+		return mat, nil
+	}
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/texture"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
+)
+
+// fixedDetail is a surface detail always returning the same shading normal
+type fixedDetail geometry.Vec3
+
+func (fd fixedDetail) perturb(*HitRecord) geometry.Vec3 { return geometry.Vec3(fd) }
+
+// uniform returns a texture of a single gray level
+func uniform(gray uint8) *texture.Image {
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = gray
+	}
+	return texture.FromImage(img)
+}
+
+// sphereHit returns the record of a ray hitting the sphere toward its center from direction
+func sphereHit(s Sphere, direction geometry.Vec3) *HitRecord {
+	origin := s.Center.Translate(direction.Unit().Scale(2 * s.Radius))
+	r := &geometry.Ray{Origin: origin, Direction: s.Center.Sub(origin)}
+	_, rec := s.Hit(r, &utils.Interval{Min: 0.001, Max: math.MaxFloat64})
+	return rec
+}
+
+func TestTangentFrame(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2024))
+	records := []*HitRecord{
+		{Normal: geometry.Vec3{Z: 1}, Dpdu: geometry.Vec3{X: 2, Z: 1}},
+		{Normal: geometry.Vec3{Z: 1}},                            // no parametrization
+		{Normal: geometry.Vec3{Y: 1}, Dpdu: geometry.Vec3{Y: 3}}, // dpdu along the normal
+		{Normal: geometry.Vec3{Y: -1}},                           // the fallback along Y
+	}
+	for i := 0; i < 20; i++ {
+		records = append(records, &HitRecord{Normal: geometry.RandomUnitSphere(rnd), Dpdu: geometry.RandomInUnitSphere(rnd)})
+	}
+
+	for _, rec := range records {
+		tg, b := tangentFrame(rec)
+		n := rec.Normal
+		for _, v := range []geometry.Vec3{tg, b} {
+			if math.Abs(v.Length()-1) > 1e-9 {
+				t.Errorf("Expected a unit vector, but got %v (%v)", v, rec)
+			}
+		}
+		if math.Abs(geometry.Dot(tg, b)) > 1e-9 || math.Abs(geometry.Dot(tg, n)) > 1e-9 || math.Abs(geometry.Dot(b, n)) > 1e-9 {
+			t.Errorf("Expected an orthogonal frame, but got %v, %v, %v", tg, b, n)
+		}
+		if geometry.Cross(tg, b).Sub(n).Length() > 1e-9 {
+			t.Errorf("Expected a right-handed frame (t x b = n), but got %v, %v, %v", tg, b, n)
+		}
+		// the tangent follows dpdu when it is usable
+		if dpdu := rec.Dpdu.Sub(n.Scale(geometry.Dot(rec.Dpdu, n))); !dpdu.NearZero() && geometry.Dot(tg, dpdu.Unit()) < 1-1e-9 {
+			t.Errorf("Expected the tangent along %v, but got %v", dpdu, tg)
+		}
+	}
+}
+
+func TestBumpMapFlat(t *testing.T) {
+	s := Sphere{Center: geometry.Point3{X: 1}, Radius: 2}
+	rnd := rand.New(rand.NewSource(2024))
+
+	for _, gray := range []uint8{0, 128, 255} {
+		bm := BumpMap{scale: 0.5, tex: uniform(gray)}
+		for i := 0; i < 20; i++ {
+			rec := sphereHit(s, geometry.RandomUnitSphere(rnd))
+			if n := bm.perturb(rec); n.Sub(rec.Normal).Length() > 1e-9 {
+				t.Errorf("Expected a flat bump map to keep the normal %v, but got %v", rec.Normal, n)
+			}
+		}
+	}
+
+	// a height growing with u tilts the normal against dpdu
+	img := image.NewGray(image.Rect(0, 0, 64, 1))
+	for x := range img.Pix {
+		img.Pix[x] = uint8(x * 4)
+	}
+	bm := BumpMap{scale: 0.1, tex: texture.FromImage(img)}
+	rec := sphereHit(s, geometry.Vec3{X: 1, Z: 0.1})
+	n := bm.perturb(rec)
+	if geometry.Dot(n, rec.Dpdu) >= 0 || geometry.Dot(n, rec.Normal) <= 0 {
+		t.Errorf("Expected the normal to tilt against dpdu %v, but got %v", rec.Dpdu, n)
+	}
+}
+
+func TestNormalMapFlat(t *testing.T) {
+	s := Sphere{Radius: 1}
+	nm := NormalMap{strength: 0, tex: uniform(200)}
+	rec := sphereHit(s, geometry.Vec3{X: 1, Y: 1, Z: 1})
+	if n := nm.perturb(rec); n.Sub(rec.Normal).Length() > 1e-9 {
+		t.Errorf("Expected a zero strength normal map to keep the normal %v, but got %v", rec.Normal, n)
+	}
+}
+
+func TestPerturbNormalTerminator(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2024))
+	ng := geometry.Vec3{Z: 1}
+
+	for i := 0; i < 200; i++ {
+		// rays from both sides of the surface, shading normals in any direction
+		direction := geometry.RandomUnitSphere(rnd)
+		ns := geometry.RandomUnitSphere(rnd)
+		if math.Abs(direction.Z) < 1e-3 {
+			continue
+		}
+		r := &geometry.Ray{Direction: direction}
+		rec := &HitRecord{Normal: ng, GeometricNormal: ng}
+		detailedMaterial{Material: Lambertian{}, detail: fixedDetail(ns)}.perturbNormal(r, rec)
+
+		wi := direction.Negate()
+		if math.Abs(rec.Normal.Length()-1) > 1e-9 {
+			t.Errorf("Expected a unit shading normal, but got %v", rec.Normal)
+		}
+		if geometry.Dot(wi, rec.Normal)*geometry.Dot(wi, ng) <= 0 {
+			t.Errorf("Expected the shading normal %v to see %v on the side of the geometric normal", rec.Normal, wi)
+		}
+		// a shading normal already on the correct side (above the minimum cosine) is kept
+		if geometry.Dot(wi, ns)*math.Copysign(1, geometry.Dot(wi, ng)) > 0.01 && rec.Normal.Sub(ns).Length() > 1e-9 {
+			t.Errorf("Expected the shading normal %v to be kept, but got %v", ns, rec.Normal)
+		}
+	}
+}
+
+func TestKeepAbove(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2024))
+	for i := 0; i < 200; i++ {
+		ng := geometry.RandomUnitSphere(rnd)
+		dir := geometry.RandomInUnitSphere(rnd)
+		above := keepAbove(dir, &HitRecord{Normal: geometry.RandomUnitSphere(rnd), GeometricNormal: ng})
+
+		if geometry.Dot(above, ng) < -1e-12 {
+			t.Errorf("Expected a direction above the surface %v, but got %v", ng, above)
+		}
+		if math.Abs(above.Length()-dir.Length()) > 1e-9 {
+			t.Errorf("Expected the length %v, but got %v", dir.Length(), above.Length())
+		}
+		// the tangent component is kept
+		if tangent := dir.Sub(ng.Scale(geometry.Dot(dir, ng))); above.Sub(ng.Scale(geometry.Dot(above, ng))).Sub(tangent).Length() > 1e-9 {
+			t.Errorf("Expected the tangent component %v to be kept, but got %v", tangent, above)
+		}
+		if geometry.Dot(dir, ng) >= 0 && above != dir {
+			t.Errorf("Expected %v to be kept, but got %v", dir, above)
+		}
+	}
+}
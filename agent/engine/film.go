@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"math"
+	"sync"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/filter"
+)
+
+// WithFilter defines the reconstruction filter used to splat the samples in the pixels (nil means the box filter
+// of a pixel)
+func WithFilter(f filter.Filter) SceneOption {
+	return func(scene *Scene) {
+		scene.filter = f
+	}
+}
+
+// film accumulates the samples splatted by the reconstruction filter: the weighted sum of the colors and coverages
+// and the sum of the weights of every pixel (indexed like the framebuffer)
+//
+//	The lines are rendered concurrently and a sample contributes to the pixels of the neighbouring lines (up to
+//	the radius of the filter) so each line is first splatted in its own strip which is then merged in the film.
+//	The strips are merged in the order of their lines (from the top), whichever goroutine completes them first,
+//	so that the sums, hence the image, are exactly the same from one render to the next.
+type film struct {
+	width, height int
+	filter        filter.Filter
+	colors        []clr.Color
+	alphas        []float64
+	weights       []float64
+
+	lock    sync.Mutex
+	next    int            // line of the next strip to merge
+	pending map[int]*strip // completed strips waiting for the previous lines
+}
+
+func newFilm(width, height int, f filter.Filter) *film {
+	return &film{
+		width:   width,
+		height:  height,
+		filter:  f,
+		colors:  make([]clr.Color, width*height),
+		alphas:  make([]float64, width*height),
+		weights: make([]float64, width*height),
+		next:    height - 1,
+		pending: map[int]*strip{},
+	}
+}
+
+// strip accumulates the samples of a single line of the scene (y), covering the lines [y0,y1] it can reach
+type strip struct {
+	film    *film
+	y       int
+	y0, y1  int
+	colors  []clr.Color
+	alphas  []float64
+	weights []float64
+}
+
+// newStrip creates the (empty) strip of the line y
+func (f *film) newStrip(y int) *strip {
+	r := int(math.Ceil(f.filter.Radius()))
+	s := &strip{film: f, y: y, y0: max(y-r, 0), y1: min(y+r, f.height-1)}
+	s.colors = make([]clr.Color, (s.y1-s.y0+1)*f.width)
+	s.alphas = make([]float64, len(s.colors))
+	s.weights = make([]float64, len(s.colors))
+	return s
+}
+
+// splat adds the color c and coverage alpha of the sample at (x,y) (scene coordinates, in pixels) to every pixel
+// within the radius of the filter (the center of the pixel (i,j) being (i+0.5,j+0.5))
+func (s *strip) splat(x, y float64, c clr.Color, alpha float64) {
+	f := s.film.filter
+	r := f.Radius()
+	i0, i1 := max(int(math.Ceil(x-r-0.5)), 0), min(int(math.Floor(x+r-0.5)), s.film.width-1)
+	j0, j1 := max(int(math.Ceil(y-r-0.5)), s.y0), min(int(math.Floor(y+r-0.5)), s.y1)
+
+	for j := j0; j <= j1; j++ {
+		for i := i0; i <= i1; i++ {
+			w := f.Evaluate(float64(i)+0.5-x, float64(j)+0.5-y)
+			if w == 0 {
+				continue
+			}
+			k := (s.y1-j)*s.film.width + i
+			s.colors[k] = s.colors[k].Add(c.Scale(w))
+			s.alphas[k] += alpha * w
+			s.weights[k] += w
+		}
+	}
+}
+
+// merge adds the strip to the film once the strips of the lines above are merged, or keeps it until then (safe to
+// call concurrently)
+func (f *film) merge(s *strip) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.pending[s.y] = s
+	for {
+		s, ok := f.pending[f.next]
+		if !ok {
+			return
+		}
+		delete(f.pending, f.next)
+		f.add(s)
+		f.next--
+	}
+}
+
+// add sums the strip in the film
+func (f *film) add(s *strip) {
+	for j := s.y1; j >= s.y0; j-- {
+		row := (s.y1 - j) * f.width
+		k0 := (f.height - 1 - j) * f.width
+		for i := 0; i < f.width; i++ {
+			f.colors[k0+i] = f.colors[k0+i].Add(s.colors[row+i])
+			f.alphas[k0+i] += s.alphas[row+i]
+			f.weights[k0+i] += s.weights[row+i]
+		}
+	}
+}
+
+// resolve returns the color (premultiplied by the coverage) and coverage of the pixel k: the weighted averages of
+// the samples around it (negative lobes of the filter may produce values out of range which are clamped)
+func (f *film) resolve(k int) (clr.Color, float64) {
+	if f.weights[k] <= 0 {
+		return clr.Black, 0
+	}
+	c := f.colors[k].Scale(1.0 / f.weights[k])
+	alpha := math.Min(math.Max(f.alphas[k]/f.weights[k], 0), 1)
+	return clr.Color{R: math.Max(c.R, 0), G: math.Max(c.G, 0), B: math.Max(c.B, 0)}, alpha
+}
@@ -0,0 +1,192 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Filter defines how much a sample contributes to a pixel depending on its offset (x,y) from the center of the
+// pixel (in pixels)
+type Filter interface {
+	// Radius returns the distance (along x and y) beyond which samples do not contribute
+	Radius() float64
+	// Evaluate returns the weight of a sample (may be negative for filters with negative lobes)
+	Evaluate(x, y float64) float64
+}
+
+const (
+	Box      = "box"
+	Tent     = "tent"
+	Gaussian = "gaussian"
+	Mitchell = "mitchell"
+	Lanczos  = "lanczos"
+)
+
+// Settings defines the reconstruction filter
+//
+//	Radius is in pixels (0 means the default radius of the type). Sigma is the standard deviation of the
+//	gaussian filter, B and C are the parameters of the Mitchell-Netravali filter.
+type Settings struct {
+	Type   string  `json:"type"`
+	Radius float64 `json:"radius"`
+	Sigma  float64 `json:"sigma"`
+	B      float64 `json:"b"`
+	C      float64 `json:"c"`
+}
+
+// DefaultSettings returns the box filter of a pixel: each sample only contributes to its own pixel
+func DefaultSettings() Settings {
+	return Settings{Type: Box, Radius: 0.5, Sigma: 0.5, B: 1.0 / 3, C: 1.0 / 3}
+}
+
+// UnmarshalJSON unmarshals the settings, missing values keeping their default
+func (s *Settings) UnmarshalJSON(data []byte) error {
+	type Alias Settings
+	aux := Alias{Type: Box, Sigma: 0.5, B: 1.0 / 3, C: 1.0 / 3}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*s = Settings(aux)
+	return nil
+}
+
+// Validate checks the settings
+func (s *Settings) Validate() error {
+	switch s.Type {
+	case Box, Tent, Gaussian, Mitchell, Lanczos:
+	default:
+		return fmt.Errorf("unknown filter: %s", s.Type)
+	}
+	if s.Radius < 0 || s.Radius > 8 {
+		return fmt.Errorf("filter radius must be in [0,8]: %v", s.Radius)
+	}
+	if s.Type == Gaussian && s.Sigma <= 0 {
+		return fmt.Errorf("gaussian filter sigma must be positive: %v", s.Sigma)
+	}
+	return nil
+}
+
+// New creates the filter (settings must be valid)
+func New(s Settings) Filter {
+	radius := func(defaultRadius float64) float64 {
+		if s.Radius == 0 {
+			return defaultRadius
+		}
+		return s.Radius
+	}
+
+	switch s.Type {
+	case Tent:
+		return tent{radius: radius(1)}
+	case Gaussian:
+		r := radius(1.5)
+		return gaussian{radius: r, sigma: s.Sigma, offset: gaussian1D(r, s.Sigma)}
+	case Mitchell:
+		return mitchell{radius: radius(2), b: s.B, c: s.C}
+	case Lanczos:
+		return lanczos{radius: radius(3)}
+	default:
+		return box{radius: radius(0.5)}
+	}
+}
+
+type box struct {
+	radius float64
+}
+
+func (f box) Radius() float64 {
+	return f.radius
+}
+
+// Evaluate is half-open so that a sample on the border of two pixels only contributes to one of them
+func (f box) Evaluate(x, y float64) float64 {
+	if x <= -f.radius || x > f.radius || y <= -f.radius || y > f.radius {
+		return 0
+	}
+	return 1
+}
+
+type tent struct {
+	radius float64
+}
+
+func (f tent) Radius() float64 {
+	return f.radius
+}
+
+func (f tent) Evaluate(x, y float64) float64 {
+	return math.Max(0, 1-math.Abs(x)/f.radius) * math.Max(0, 1-math.Abs(y)/f.radius)
+}
+
+// gaussian is shifted down so that it reaches 0 at the radius
+type gaussian struct {
+	radius, sigma float64
+	offset        float64
+}
+
+func (f gaussian) Radius() float64 {
+	return f.radius
+}
+
+func (f gaussian) Evaluate(x, y float64) float64 {
+	return math.Max(0, gaussian1D(x, f.sigma)-f.offset) * math.Max(0, gaussian1D(y, f.sigma)-f.offset)
+}
+
+func gaussian1D(x, sigma float64) float64 {
+	return math.Exp(-x * x / (2 * sigma * sigma))
+}
+
+// mitchell is the Mitchell-Netravali cubic filter (B = C = 1/3 is the recommended tradeoff between blurring and
+// ringing)
+type mitchell struct {
+	radius, b, c float64
+}
+
+func (f mitchell) Radius() float64 {
+	return f.radius
+}
+
+func (f mitchell) Evaluate(x, y float64) float64 {
+	return f.mitchell1D(2*x/f.radius) * f.mitchell1D(2*y/f.radius)
+}
+
+func (f mitchell) mitchell1D(x float64) float64 {
+	b, c := f.b, f.c
+	x = math.Abs(x)
+	switch {
+	case x <= 1:
+		return ((12-9*b-6*c)*x*x*x + (-18+12*b+6*c)*x*x + (6 - 2*b)) / 6
+	case x <= 2:
+		return ((-b-6*c)*x*x*x + (6*b+30*c)*x*x + (-12*b-48*c)*x + (8*b + 24*c)) / 6
+	default:
+		return 0
+	}
+}
+
+// lanczos is the windowed sinc filter: sinc(x) sinc(x / radius)
+type lanczos struct {
+	radius float64
+}
+
+func (f lanczos) Radius() float64 {
+	return f.radius
+}
+
+func (f lanczos) Evaluate(x, y float64) float64 {
+	return f.lanczos1D(x) * f.lanczos1D(y)
+}
+
+func (f lanczos) lanczos1D(x float64) float64 {
+	if math.Abs(x) >= f.radius {
+		return 0
+	}
+	return sinc(x) * sinc(x/f.radius)
+}
+
+func sinc(x float64) float64 {
+	if math.Abs(x) < 1e-5 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
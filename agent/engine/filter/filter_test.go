@@ -0,0 +1,107 @@
+package filter
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		settings Settings
+		x, y     float64
+		expected float64
+	}{
+		{DefaultSettings(), 0.0, 0.0, 1.0},
+		{DefaultSettings(), 0.4, -0.4, 1.0},
+		{DefaultSettings(), 0.6, 0.0, 0.0},
+		{Settings{Type: Tent}, 0.5, 0.0, 0.5},
+		{Settings{Type: Tent, Radius: 2}, 1.0, 1.0, 0.25},
+		{Settings{Type: Gaussian, Sigma: 0.5}, 1.5, 0.0, 0.0},
+		{Settings{Type: Mitchell, B: 1.0 / 3, C: 1.0 / 3}, 0.0, 0.0, 8.0 / 9 * 8.0 / 9},
+		{Settings{Type: Mitchell, B: 1.0 / 3, C: 1.0 / 3}, 2.0, 0.0, 0.0},
+		{Settings{Type: Mitchell, B: 0, C: 0.5}, 1.0, 0.0, 0.0}, // Catmull-Rom interpolates
+		{Settings{Type: Lanczos}, 0.0, 0.0, 1.0},
+		{Settings{Type: Lanczos}, 1.0, 0.0, 0.0},
+		{Settings{Type: Lanczos}, 3.5, 0.0, 0.0},
+	}
+
+	for _, tc := range cases {
+		f := New(tc.settings)
+		if v := f.Evaluate(tc.x, tc.y); math.Abs(v-tc.expected) > 1e-9 {
+			t.Errorf("Expected %v, but got %v (%v at %v,%v)", tc.expected, v, tc.settings.Type, tc.x, tc.y)
+		}
+	}
+}
+
+func TestRadius(t *testing.T) {
+	cases := []struct {
+		settings Settings
+		expected float64
+	}{
+		{DefaultSettings(), 0.5},
+		{Settings{Type: Tent}, 1.0},
+		{Settings{Type: Gaussian, Sigma: 0.5}, 1.5},
+		{Settings{Type: Mitchell}, 2.0},
+		{Settings{Type: Lanczos}, 3.0},
+		{Settings{Type: Lanczos, Radius: 2}, 2.0},
+	}
+
+	for _, tc := range cases {
+		f := New(tc.settings)
+		if r := f.Radius(); r != tc.expected {
+			t.Errorf("Expected %v, but got %v (%v)", tc.expected, r, tc.settings.Type)
+		}
+		// nothing beyond the radius
+		if v := f.Evaluate(f.Radius()+0.01, 0); v != 0 {
+			t.Errorf("Expected 0 beyond the radius, but got %v (%v)", v, tc.settings.Type)
+		}
+	}
+}
+
+// TestSymmetric checks that filters are symmetric and have most of their weight around the center
+func TestSymmetric(t *testing.T) {
+	for _, typ := range []string{Box, Tent, Gaussian, Mitchell, Lanczos} {
+		s := DefaultSettings()
+		s.Type, s.Radius = typ, 0
+		f := New(s)
+		for _, x := range []float64{0.1, 0.3, 0.7, 1.2} {
+			if f.Evaluate(x, 0.2) != f.Evaluate(-x, -0.2) {
+				t.Errorf("Expected a symmetric filter (%v at %v)", typ, x)
+			}
+			if f.Evaluate(x, 0) > f.Evaluate(0, 0) {
+				t.Errorf("Expected the highest weight at the center (%v at %v)", typ, x)
+			}
+		}
+	}
+}
+
+func TestUnmarshalSettings(t *testing.T) {
+	var s Settings
+	if err := json.Unmarshal([]byte(`{"type":"mitchell","radius":1.5}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	expected := Settings{Type: Mitchell, Radius: 1.5, Sigma: 0.5, B: 1.0 / 3, C: 1.0 / 3}
+	if s != expected {
+		t.Errorf("Expected %v, but got %v", expected, s)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		settings Settings
+		valid    bool
+	}{
+		{DefaultSettings(), true},
+		{Settings{Type: "sinc"}, false},
+		{Settings{Type: Tent, Radius: -1}, false},
+		{Settings{Type: Tent, Radius: 10}, false},
+		{Settings{Type: Gaussian, Sigma: 0}, false},
+	}
+
+	for _, tc := range cases {
+		if err := tc.settings.Validate(); (err == nil) != tc.valid {
+			t.Errorf("Expected valid=%v for %v, but got %v", tc.valid, tc.settings, err)
+		}
+	}
+}
@@ -8,6 +8,15 @@ type Ray struct {
 	Origin    Point3
 	Direction Vec3
 	Rnd       utils.Rnd
+	Lambda    float64 // wavelength (in nm) of the hero wavelength carried by the ray in spectral mode (0 otherwise)
+	Tests     *int    // optional counter of the intersection tests of the ray (see CountTest)
+}
+
+// CountTest counts an intersection test of the ray against a primitive (when the ray has a counter)
+func (r *Ray) CountTest() {
+	if r.Tests != nil {
+		*r.Tests++
+	}
 }
 
 // PointAt returns a new point along the ray (0 will return the origin)
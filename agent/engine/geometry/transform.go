@@ -0,0 +1,82 @@
+package geometry
+
+import "math"
+
+// Transform defines an affine transformation (scale, then rotation, then translation) and its inverse
+type Transform struct {
+	m, inv [3][4]float64
+}
+
+// NewTransform creates the transform which scales, rotates (angles in degrees around X, then Y, then Z) and
+// finally translates
+func NewTransform(translate Vec3, rotate Vec3, scale Vec3) Transform {
+	sx, cx := math.Sincos(rotate.X * math.Pi / 180.0)
+	sy, cy := math.Sincos(rotate.Y * math.Pi / 180.0)
+	sz, cz := math.Sincos(rotate.Z * math.Pi / 180.0)
+
+	// r = rz * ry * rx
+	r := [3][3]float64{
+		{cz * cy, cz*sy*sx - sz*cx, cz*sy*cx + sz*sx},
+		{sz * cy, sz*sy*sx + cz*cx, sz*sy*cx - cz*sx},
+		{-sy, cy * sx, cy * cx},
+	}
+	s := [3]float64{scale.X, scale.Y, scale.Z}
+	t := [3]float64{translate.X, translate.Y, translate.Z}
+
+	var tr Transform
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			// m = t * r * s
+			tr.m[i][j] = r[i][j] * s[j]
+			// inv = s^-1 * r^T * t^-1
+			tr.inv[i][j] = r[j][i] / s[i]
+		}
+		tr.m[i][3] = t[i]
+	}
+	for i := 0; i < 3; i++ {
+		tr.inv[i][3] = -(tr.inv[i][0]*t[0] + tr.inv[i][1]*t[1] + tr.inv[i][2]*t[2])
+	}
+
+	return tr
+}
+
+// Identity returns the transform which leaves everything unchanged
+func Identity() Transform {
+	return NewTransform(Vec3{}, Vec3{}, Vec3{X: 1, Y: 1, Z: 1})
+}
+
+func apply(m *[3][4]float64, x, y, z, w float64) (float64, float64, float64) {
+	return m[0][0]*x + m[0][1]*y + m[0][2]*z + m[0][3]*w,
+		m[1][0]*x + m[1][1]*y + m[1][2]*z + m[1][3]*w,
+		m[2][0]*x + m[2][1]*y + m[2][2]*z + m[2][3]*w
+}
+
+// Point transforms a point
+func (tr Transform) Point(p Point3) Point3 {
+	x, y, z := apply(&tr.m, p.X, p.Y, p.Z, 1)
+	return Point3{x, y, z}
+}
+
+// Vector transforms a vector (translation does not apply)
+func (tr Transform) Vector(v Vec3) Vec3 {
+	x, y, z := apply(&tr.m, v.X, v.Y, v.Z, 0)
+	return Vec3{x, y, z}
+}
+
+// InversePoint applies the inverse transform to a point
+func (tr Transform) InversePoint(p Point3) Point3 {
+	x, y, z := apply(&tr.inv, p.X, p.Y, p.Z, 1)
+	return Point3{x, y, z}
+}
+
+// InverseVector applies the inverse transform to a vector
+func (tr Transform) InverseVector(v Vec3) Vec3 {
+	x, y, z := apply(&tr.inv, v.X, v.Y, v.Z, 0)
+	return Vec3{x, y, z}
+}
+
+// InverseRay transforms a ray from world space into the local space of the transform (the ray parameter t
+// identifies the same points in both spaces)
+func (tr Transform) InverseRay(r *Ray) *Ray {
+	return &Ray{Origin: tr.InversePoint(r.Origin), Direction: tr.InverseVector(r.Direction), Rnd: r.Rnd}
+}
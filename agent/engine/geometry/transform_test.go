@@ -0,0 +1,48 @@
+package geometry
+
+import (
+	"math"
+	"testing"
+)
+
+func equalPoint(p1, p2 Point3) bool {
+	const epsilon = 1e-9
+	return math.Abs(p1.X-p2.X) < epsilon && math.Abs(p1.Y-p2.Y) < epsilon && math.Abs(p1.Z-p2.Z) < epsilon
+}
+
+func TestTransformPoint(t *testing.T) {
+	cases := []struct {
+		tr       Transform
+		p        Point3
+		expected Point3
+	}{
+		{Identity(), Point3{1, 2, 3}, Point3{1, 2, 3}},
+		{NewTransform(Vec3{1, 2, 3}, Vec3{}, Vec3{1, 1, 1}), Point3{1, 1, 1}, Point3{2, 3, 4}},
+		{NewTransform(Vec3{}, Vec3{}, Vec3{2, 3, 4}), Point3{1, 1, 1}, Point3{2, 3, 4}},
+		{NewTransform(Vec3{}, Vec3{0, 0, 90}, Vec3{1, 1, 1}), Point3{1, 0, 0}, Point3{0, 1, 0}},
+		{NewTransform(Vec3{}, Vec3{0, 90, 0}, Vec3{1, 1, 1}), Point3{0, 0, 1}, Point3{1, 0, 0}},
+		{NewTransform(Vec3{}, Vec3{90, 0, 0}, Vec3{1, 1, 1}), Point3{0, 1, 0}, Point3{0, 0, 1}},
+		{NewTransform(Vec3{5, 0, 0}, Vec3{0, 0, 90}, Vec3{2, 2, 2}), Point3{1, 0, 0}, Point3{5, 2, 0}},
+	}
+
+	for _, tc := range cases {
+		result := tc.tr.Point(tc.p)
+		if !equalPoint(result, tc.expected) {
+			t.Errorf("Expected %v, but got %v", tc.expected, result)
+		}
+	}
+}
+
+func TestTransformInverse(t *testing.T) {
+	tr := NewTransform(Vec3{1, -2, 3}, Vec3{30, 45, 60}, Vec3{2, 0.5, 3})
+	p := Point3{0.3, -1.2, 4.5}
+
+	if result := tr.InversePoint(tr.Point(p)); !equalPoint(result, p) {
+		t.Errorf("Expected %v, but got %v", p, result)
+	}
+
+	v := Vec3{1, 2, 3}
+	if result := tr.InverseVector(tr.Vector(v)); !equalPoint(Point3(result), Point3(v)) {
+		t.Errorf("Expected %v, but got %v", v, result)
+	}
+}
@@ -107,15 +107,29 @@ func RandomInUnitSphere(rnd utils.Rnd) Vec3 {
 	}
 }
 
+// RandomUnitSphere returns a random direction (uniform on the unit sphere) from a single pair of random values
 func RandomUnitSphere(rnd utils.Rnd) Vec3 {
-	return RandomInUnitSphere(rnd).Unit()
+	u, v := utils.Get2D(rnd)
+	z := 1 - 2*u
+	r := math.Sqrt(math.Max(0, 1-z*z))
+	phi := 2 * math.Pi * v
+	return Vec3{r * math.Cos(phi), r * math.Sin(phi), z}
 }
 
+// RandomInUnitDisk returns a random point (uniform in the unit disk) from a single pair of random values
+// (concentric mapping of the square to the disk, which keeps the stratification of the values)
 func RandomInUnitDisk(rnd utils.Rnd) Vec3 {
-	for {
-		p := Vec3{2.0*rnd.Float64() - 1.0, 2.0*rnd.Float64() - 1.0, 0}
-		if Dot(p, p) < 1.0 {
-			return p
-		}
+	u, v := utils.Get2D(rnd)
+	a, b := 2*u-1, 2*v-1
+	if a == 0 && b == 0 {
+		return Vec3{}
+	}
+
+	var r, theta float64
+	if math.Abs(a) > math.Abs(b) {
+		r, theta = a, math.Pi/4*(b/a)
+	} else {
+		r, theta = b, math.Pi/2-math.Pi/4*(a/b)
 	}
+	return Vec3{r * math.Cos(theta), r * math.Sin(theta), 0}
 }
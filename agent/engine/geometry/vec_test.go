@@ -219,3 +219,53 @@ func TestVec3Refract(t *testing.T) {
 		}
 	}
 }
+
+// fixedRnd returns its values in turn
+type fixedRnd struct {
+	values []float64
+}
+
+func (rnd *fixedRnd) Float64() float64 {
+	v := rnd.values[0]
+	rnd.values = rnd.values[1:]
+	return v
+}
+
+func TestRandomUnitSphere(t *testing.T) {
+	cases := []struct {
+		u, v     float64
+		expected Vec3
+	}{
+		{0.0, 0.0, Vec3{0.0, 0.0, 1.0}},
+		{0.5, 0.0, Vec3{1.0, 0.0, 0.0}},
+		{0.5, 0.25, Vec3{0.0, 1.0, 0.0}},
+		{1.0, 0.0, Vec3{0.0, 0.0, -1.0}},
+	}
+
+	for _, tc := range cases {
+		result := RandomUnitSphere(&fixedRnd{values: []float64{tc.u, tc.v}})
+		if !equalVec3(result, tc.expected) {
+			t.Errorf("Expected %v, but got %v", tc.expected, result)
+		}
+	}
+}
+
+func TestRandomInUnitDisk(t *testing.T) {
+	cases := []struct {
+		u, v     float64
+		expected Vec3
+	}{
+		{0.5, 0.5, Vec3{0.0, 0.0, 0.0}},
+		{1.0, 0.5, Vec3{1.0, 0.0, 0.0}},
+		{0.5, 1.0, Vec3{0.0, 1.0, 0.0}},
+		{1.0, 1.0, Vec3{math.Sqrt2 / 2, math.Sqrt2 / 2, 0.0}},
+		{0.0, 0.0, Vec3{-math.Sqrt2 / 2, -math.Sqrt2 / 2, 0.0}},
+	}
+
+	for _, tc := range cases {
+		result := RandomInUnitDisk(&fixedRnd{values: []float64{tc.u, tc.v}})
+		if !equalVec3(result, tc.expected) {
+			t.Errorf("Expected %v, but got %v", tc.expected, result)
+		}
+	}
+}
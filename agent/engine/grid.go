@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/volume"
+)
+
+// GridTransform defines how a grid medium is placed in the world (see geometry.NewTransform)
+type GridTransform struct {
+	Translate geometry.Vec3  `json:"translate"`
+	Rotate    geometry.Vec3  `json:"rotate"` // in degrees around X, then Y, then Z
+	Scale     *geometry.Vec3 `json:"scale,omitempty"`
+}
+
+// GridMediumConfig defines a heterogeneous medium as described in the world definition
+type GridMediumConfig struct {
+	File         string          `json:"file"`                   // raw float32 density grid (see package volume)
+	EmissionFile string          `json:"emissionFile,omitempty"` // optional raw float32 emission grid (fire)
+	Resolution   [3]int          `json:"resolution"`             // number of voxels along X, Y and Z
+	Min          geometry.Point3 `json:"min"`                    // local bounds of the grid
+	Max          geometry.Point3 `json:"max"`
+	Transform    GridTransform   `json:"transform"`
+	DensityScale float64         `json:"densityScale"` // multiplies the density (per world unit)
+	Albedo       clr.Color       `json:"albedo"`       // scattering albedo at each collision
+	G            float64         `json:"g"`            // anisotropy of the Henyey-Greenstein phase function
+	Emission     clr.Color       `json:"emission"`     // multiplies the emission grid
+}
+
+// GridMedium defines a heterogeneous volume (smoke, clouds, fire...) whose density is defined by a voxel grid.
+//
+//	The collisions are sampled with delta tracking (Woodcock tracking) against the maximum density of the grid
+//	which gives unbiased transmittance for any density distribution.
+type GridMedium struct {
+	config    GridMediumConfig
+	density   *volume.Grid
+	emission  *volume.Grid
+	transform geometry.Transform
+	majorant  float64
+}
+
+// NewGridMedium loads the grids of the medium
+func NewGridMedium(config GridMediumConfig) (*GridMedium, error) {
+	nx, ny, nz := config.Resolution[0], config.Resolution[1], config.Resolution[2]
+
+	density, err := volume.LoadRaw(config.File, nx, ny, nz)
+	if err != nil {
+		return nil, err
+	}
+
+	var emission *volume.Grid
+	if config.EmissionFile != "" {
+		emission, err = volume.LoadRaw(config.EmissionFile, nx, ny, nz)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Min.X >= config.Max.X || config.Min.Y >= config.Max.Y || config.Min.Z >= config.Max.Z {
+		return nil, fmt.Errorf("GridMedium bounds are empty: min %v, max %v", config.Min, config.Max)
+	}
+	if config.DensityScale < 0 {
+		return nil, fmt.Errorf("GridMedium densityScale must be positive: %v", config.DensityScale)
+	}
+	if config.G <= -1 || config.G >= 1 {
+		return nil, fmt.Errorf("GridMedium g must be in (-1,1): %v", config.G)
+	}
+
+	scale := geometry.Vec3{X: 1, Y: 1, Z: 1}
+	if config.Transform.Scale != nil {
+		scale = *config.Transform.Scale
+		if scale.X == 0 || scale.Y == 0 || scale.Z == 0 {
+			return nil, fmt.Errorf("GridMedium scale cannot be 0: %v", scale)
+		}
+	}
+
+	return &GridMedium{
+		config:    config,
+		density:   density,
+		emission:  emission,
+		transform: geometry.NewTransform(config.Transform.Translate, config.Transform.Rotate, scale),
+		majorant:  density.Max() * config.DensityScale,
+	}, nil
+}
+
+func (gm *GridMedium) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		GridMediumConfig
+	}{
+		Type:             "GridMedium",
+		GridMediumConfig: gm.config,
+	})
+}
+
+// lookup returns the grid coordinates (in [0,1]^3) of a local point
+func (gm *GridMedium) lookup(p geometry.Point3) (float64, float64, float64) {
+	min, max := gm.config.Min, gm.config.Max
+	return (p.X - min.X) / (max.X - min.X), (p.Y - min.Y) / (max.Y - min.Y), (p.Z - min.Z) / (max.Z - min.Z)
+}
+
+// bounds intersects the local ray with the bounds of the grid (slab method)
+func (gm *GridMedium) bounds(r *geometry.Ray) (bool, float64, float64) {
+	tMin, tMax := math.Inf(-1), math.Inf(1)
+
+	origin := [3]float64{r.Origin.X, r.Origin.Y, r.Origin.Z}
+	dir := [3]float64{r.Direction.X, r.Direction.Y, r.Direction.Z}
+	lo := [3]float64{gm.config.Min.X, gm.config.Min.Y, gm.config.Min.Z}
+	hi := [3]float64{gm.config.Max.X, gm.config.Max.Y, gm.config.Max.Z}
+
+	for i := 0; i < 3; i++ {
+		if dir[i] == 0 {
+			if origin[i] < lo[i] || origin[i] > hi[i] {
+				return false, 0, 0
+			}
+			continue
+		}
+		t0 := (lo[i] - origin[i]) / dir[i]
+		t1 := (hi[i] - origin[i]) / dir[i]
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		tMin = math.Max(tMin, t0)
+		tMax = math.Min(tMax, t1)
+	}
+
+	return tMin < tMax, tMin, tMax
+}
+
+// Hit implements the Hit interface for a GridMedium: the ray hits the medium at the first real collision
+func (gm *GridMedium) Hit(r *geometry.Ray, interval *utils.Interval) (bool, *HitRecord) {
+	if gm.majorant <= 0 {
+		return false, nil
+	}
+
+	r.CountTest() // against the bounds of the grid
+	local := gm.transform.InverseRay(r)
+	inside, t0, t1 := gm.bounds(local)
+	if !inside {
+		return false, nil
+	}
+	t0 = math.Max(t0, interval.Min)
+	t1 = math.Min(t1, interval.Max)
+	if t0 >= t1 {
+		return false, nil
+	}
+
+	// distances are measured in world units while the grid is looked up in local space (t is the same in both)
+	rayLength := r.Direction.Length()
+	t := t0
+	for {
+		t += -math.Log(1-r.Rnd.Float64()) / (gm.majorant * rayLength)
+		if t >= t1 {
+			return false, nil
+		}
+
+		u, v, w := gm.lookup(local.PointAt(t))
+		density := gm.density.Lookup(u, v, w) * gm.config.DensityScale
+
+		// real collision with probability density / majorant, null collision otherwise
+		if r.Rnd.Float64()*gm.majorant < density {
+			var emission clr.Color
+			if gm.emission != nil {
+				emission = gm.config.Emission.Scale(gm.emission.Lookup(u, v, w))
+			}
+
+			normal := geometry.Vec3{X: 1, Y: 0, Z: 0} // arbitrary (unused by phase functions)
+			return true, &HitRecord{
+				T:               t,
+				P:               r.PointAt(t),
+				Normal:          normal,
+				GeometricNormal: normal,
+				Material:        gridPhase{albedo: gm.config.Albedo, g: gm.config.G, emission: emission},
+			}
+		}
+	}
+}
+
+// gridPhase is the material at a collision inside a grid medium: it scatters light according to the
+// Henyey-Greenstein phase function and emits the radiance of the emission grid at that point
+type gridPhase struct {
+	albedo   clr.Color
+	g        float64
+	emission clr.Color
+}
+
+func (mat gridPhase) scatter(r *geometry.Ray, rec *HitRecord) (bool, *clr.Color, *geometry.Ray) {
+	return HenyeyGreenstein{albedo: mat.albedo, g: mat.g}.scatter(r, rec)
+}
+
+func (mat gridPhase) emitted(rec *HitRecord) clr.Color {
+	return mat.emission
+}
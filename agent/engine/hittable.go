@@ -1,15 +1,23 @@
 package engine
 
 import (
+	"encoding/json"
+	"fmt"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
 	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
 	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
 )
 
 type HitRecord struct {
-	T        float64         // which t generated the hit
-	P        geometry.Point3 // which point when hit
-	Normal   geometry.Vec3   // normal at that point
-	Material Material        // the material associated to this record
+	T               float64         // which t generated the hit
+	P               geometry.Point3 // which point when hit
+	Normal          geometry.Vec3   // shading normal at that point (may be perturbed by the material)
+	GeometricNormal geometry.Vec3   // true normal of the surface at that point
+	U, V            float64         // surface (texture) coordinates at that point
+	Dpdu, Dpdv      geometry.Vec3   // partial derivatives of the point along u and v (tangent frame)
+	Material        Material        // the material associated to this record
+	Object          int             // index of the object of the world which was hit
 }
 
 // Hittable defines the interface of objects that can be hit by a ray
@@ -27,13 +35,77 @@ func (hl HittableList) Hit(r *geometry.Ray, interval *utils.Interval) (bool, *Hi
 
 	closestSoFar := interval.Max
 
-	for _, h := range hl {
+	for i, h := range hl {
 		if hit, hr := h.Hit(r, &utils.Interval{Min: interval.Min, Max: closestSoFar}); hit {
 			hitAnything = true
 			res = hr
+			res.Object = i
 			closestSoFar = hr.T
 		}
 	}
 
 	return hitAnything, res
 }
+
+// UnmarshalHittable unmarshals JSON data into a Hittable depending on its type (Sphere when not specified)
+func UnmarshalHittable(data json.RawMessage) (Hittable, error) {
+	var h struct {
+		Type string `json:"type"`
+	}
+
+	err := json.Unmarshal(data, &h)
+	if err != nil {
+		return nil, err
+	}
+
+	switch h.Type {
+	case "", "Sphere":
+		var s Sphere
+		err := json.Unmarshal(data, &s)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+
+	case "ConstantMedium":
+		var cm struct {
+			Boundary json.RawMessage `json:"boundary"`
+			Density  float64         `json:"density"`
+			Phase    json.RawMessage `json:"phase"`
+		}
+		err := json.Unmarshal(data, &cm)
+		if err != nil {
+			return nil, err
+		}
+		if cm.Boundary == nil || cm.Phase == nil {
+			return nil, fmt.Errorf("ConstantMedium requires a boundary and a phase")
+		}
+		if cm.Density <= 0 {
+			return nil, fmt.Errorf("ConstantMedium density must be positive: %v", cm.Density)
+		}
+		boundary, err := UnmarshalHittable(cm.Boundary)
+		if err != nil {
+			return nil, err
+		}
+		phase, err := UnmarshalMaterial(cm.Phase)
+		if err != nil {
+			return nil, err
+		}
+		return NewConstantMedium(boundary, cm.Density, phase), nil
+
+	case "GridMedium":
+		config := GridMediumConfig{
+			Max:          geometry.Point3{X: 1, Y: 1, Z: 1},
+			DensityScale: 1,
+			Albedo:       clr.White,
+		}
+		err := json.Unmarshal(data, &config)
+		if err != nil {
+			return nil, err
+		}
+		return NewGridMedium(config)
+
+	default:
+		return nil, fmt.Errorf("unknown object type: %s", h.Type)
+	}
+}
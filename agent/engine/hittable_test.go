@@ -0,0 +1,40 @@
+package engine
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
+)
+
+func TestIntersectionTests(t *testing.T) {
+	sphere := func(x float64) Sphere {
+		return Sphere{Center: geometry.Point3{X: x, Z: -5}, Radius: 0.5, Material: Lambertian{}}
+	}
+	world := HittableList{
+		sphere(0),
+		HittableList{sphere(2), sphere(4)},
+		NewConstantMedium(sphere(6), 1, Isotropic{}),
+	}
+
+	cases := []struct {
+		direction geometry.Vec3
+		expected  int
+	}{
+		{geometry.Vec3{Y: 1}, 4},               // misses everything: 3 spheres and the boundary of the medium
+		{geometry.Vec3{Z: -1}, 4},              // hits the first sphere, still tested against the others
+		{geometry.Vec3{X: 6, Z: -5}.Unit(), 5}, // enters the medium: its boundary is tested twice
+		{geometry.Vec3{X: 2, Z: -5}.Unit(), 4}, // hits a sphere of the nested list
+	}
+
+	for _, tc := range cases {
+		tests := 0
+		r := &geometry.Ray{Direction: tc.direction, Rnd: rand.New(rand.NewSource(2024)), Tests: &tests}
+		world.Hit(r, &utils.Interval{Min: 0.001, Max: math.MaxFloat64})
+		if tests != tc.expected {
+			t.Errorf("Expected %v, but got %v (direction %v)", tc.expected, tests, tc.direction)
+		}
+	}
+}
@@ -1,26 +1,315 @@
 package engine
 
 import (
+	"fmt"
 	"image"
 	clr "image/color"
+	"math"
+	"slices"
+	"time"
+
+	color "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/denoise"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/imageio"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/tonemap"
 )
 
-func CreateImage(pixels []uint32, width int, height int) *image.NRGBA {
-	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+// Framebuffer holds the linear radiance of every pixel (RGBA, 4 float32 values per pixel), line by line, the
+// first line being the top of the image
+//
+//	the radiance is premultiplied by the alpha of the pixel: its coverage, which is 1 unless the background is
+//	transparent (see WithTransparentBackground)
+//	AOVs holds the output variables rendered along (as many values per pixel as the variable has channels)
+//	Pipeline converts the radiance to pixel values (the one of the scene, see WithPipeline)
+//	Statistics describes how the framebuffer was rendered (once complete)
+type Framebuffer struct {
+	Width, Height int
+	Pix           []float32
+	AOVs          map[AOV][]float32
+	Pipeline      *tonemap.Pipeline
+	Statistics    Statistics
+}
+
+// Statistics describes a render: how long it took and how many rays were cast through the pixels
+//
+//	TracedRays counts all the rays traced through the world: the rays cast through the pixels and the rays they
+//	scatter into
+//	IntersectionTests counts the tests of the traced rays against the primitives of the world (spheres,
+//	boundaries of the media, bounds of the grids...)
+type Statistics struct {
+	Duration          time.Duration `json:"duration"`
+	Rays              int           `json:"rays"`
+	MinRaysPerPixel   int           `json:"minRaysPerPixel"`
+	MaxRaysPerPixel   int           `json:"maxRaysPerPixel"`
+	TracedRays        int           `json:"tracedRays"`
+	IntersectionTests int           `json:"intersectionTests"`
+}
+
+// RaysPerPixel returns the average number of rays per pixel (of an image of the given number of pixels)
+func (s Statistics) RaysPerPixel(pixels int) float64 {
+	return float64(s.Rays) / float64(pixels)
+}
+
+func NewFramebuffer(width, height int, aovs ...AOV) *Framebuffer {
+	fb := &Framebuffer{
+		Width:    width,
+		Height:   height,
+		Pix:      make([]float32, 4*width*height),
+		AOVs:     map[AOV][]float32{},
+		Pipeline: tonemap.NewPipeline(tonemap.DefaultSettings()),
+	}
+	for _, a := range aovs {
+		fb.AOVs[a] = make([]float32, len(a.Channels())*width*height)
+	}
+	return fb
+}
+
+// At returns the radiance of the pixel (x,y) premultiplied by its alpha
+func (fb *Framebuffer) At(x, y int) color.Color {
+	p := fb.Pix[4*(y*fb.Width+x):]
+	return color.Color{R: float64(p[0]), G: float64(p[1]), B: float64(p[2])}
+}
+
+// Alpha returns the coverage of the pixel (x,y)
+func (fb *Framebuffer) Alpha(x, y int) float64 {
+	return float64(fb.Pix[4*(y*fb.Width+x)+3])
+}
+
+// set stores the radiance (premultiplied by alpha) and coverage of the pixel k
+func (fb *Framebuffer) set(k int, c color.Color, alpha float64) {
+	p := fb.Pix[4*k : 4*k+4]
+	p[0], p[1], p[2], p[3] = float32(c.R), float32(c.G), float32(c.B), float32(alpha)
+}
+
+// straight returns the radiance of the pixel (x,y) divided by its coverage (black when fully transparent)
+func (fb *Framebuffer) straight(x, y int) color.Color {
+	alpha := fb.Alpha(x, y)
+	if alpha <= 0 {
+		return color.Black
+	}
+	return fb.At(x, y).Scale(1 / alpha)
+}
+
+// setFeatures stores the output variables of the pixel k
+func (fb *Framebuffer) setFeatures(k int, p *pixel) {
+	for a, values := range fb.AOVs {
+		if a == SampleCount {
+			values[k] = float32(p.raysPerPixel)
+			continue
+		}
+		n := len(a.Channels())
+		copy(values[n*k:n*k+n], p.features.values(a))
+	}
+}
+
+// Image converts the framebuffer to a floating point image (R, G, B, A channels, premultiplied alpha), applying
+// the exposure and white balance of its pipeline but no tone mapping (high dynamic range output)
+//
+//	each output variable is a layer of the image (channels named depth.Z, normal.X...), the indices and sample
+//	counts being stored as 32 bits floats whatever the pixel type of an OpenEXR file
+func (fb *Framebuffer) Image() *imageio.Image {
+	img := imageio.NewImage(fb.Width, fb.Height, "R", "G", "B", "A")
+	r, g, b, a := img.Channels[0].Values, img.Channels[1].Values, img.Channels[2].Values, img.Channels[3].Values
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			k := y*fb.Width + x
+			c := fb.Pipeline.Linear(fb.At(x, y))
+			r[k], g[k], b[k], a[k] = float32(c.R), float32(c.G), float32(c.B), float32(fb.Alpha(x, y))
+		}
+	}
 
-	k := 0
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			p := pixels[k]
+	for _, aov := range AOVs {
+		values, ok := fb.AOVs[aov]
+		if !ok {
+			continue
+		}
+		channels := aov.Channels()
+		var pixelType imageio.PixelType
+		if aov.integral() {
+			pixelType = imageio.Float
+		}
+		for i, name := range channels {
+			layer := make([]float32, fb.Width*fb.Height)
+			for k := range layer {
+				layer[k] = values[k*len(channels)+i]
+			}
+			img.Channels = append(img.Channels, imageio.Channel{Name: string(aov) + "." + name, Values: layer, PixelType: pixelType})
+		}
+	}
+	return img
+}
+
+// CreateImage converts the framebuffer to an 8 bits image with its pipeline (exposure, tone mapping...) and
+// straight alpha
+func CreateImage(fb *Framebuffer) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, fb.Width, fb.Height))
+
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			p := fb.Pipeline.Apply(fb.straight(x, y)).PixelValue()
 			img.Set(x, y, clr.NRGBA{
 				R: uint8(p >> 16 & 0xFF),
 				G: uint8(p >> 8 & 0xFF),
 				B: uint8(p & 0xFF),
+				A: uint8(math.Round(fb.Alpha(x, y) * 0xFF)),
+			})
+		}
+	}
+
+	return img
+}
+
+// CreateImage16 converts the framebuffer to a 16 bits image with its pipeline (exposure, tone mapping...) and
+// straight alpha
+func CreateImage16(fb *Framebuffer) *image.NRGBA64 {
+	img := image.NewNRGBA64(image.Rect(0, 0, fb.Width, fb.Height))
+
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			c := fb.Pipeline.Apply(fb.straight(x, y))
+			img.SetNRGBA64(x, y, clr.NRGBA64{
+				R: uint16(math.Round(c.R * 0xFFFF)),
+				G: uint16(math.Round(c.G * 0xFFFF)),
+				B: uint16(math.Round(c.B * 0xFFFF)),
+				A: uint16(math.Round(fb.Alpha(x, y) * 0xFFFF)),
+			})
+		}
+	}
+
+	return img
+}
+
+// CreateAOVImage converts an output variable to an 8 bits image for previewing
+//
+//	depth is white near the camera (black for the sky), normals are mapped from [-1,1] to [0,1], positions are
+//	normalized by their range, indices get a color of their own (black for the sky) and sample counts are shown
+//	as a heatmap (blue for the fewest samples, red for the most)
+func CreateAOVImage(fb *Framebuffer, aov AOV) *image.NRGBA {
+	values := fb.AOVs[aov]
+	n := len(aov.Channels())
+
+	// range of the finite values of each channel
+	low, high := make([]float64, n), make([]float64, n)
+	for i := range low {
+		low[i], high[i] = math.Inf(1), math.Inf(-1)
+	}
+	for k, v := range values {
+		if f := float64(v); !math.IsInf(f, 0) {
+			low[k%n], high[k%n] = math.Min(low[k%n], f), math.Max(high[k%n], f)
+		}
+	}
+	normalize := func(v float64, i int) float64 {
+		if high[i] <= low[i] {
+			return 1.0
+		}
+		return (v - low[i]) / (high[i] - low[i])
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, fb.Width, fb.Height))
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			p := values[n*(y*fb.Width+x):]
+			var c color.Color
+			switch aov {
+			case Depth:
+				if !math.IsInf(float64(p[0]), 0) {
+					g := 1.0 - float64(p[0])/math.Max(high[0], 1e-9)
+					c = color.Color{R: g, G: g, B: g}
+				}
+			case Normal:
+				c = color.Color{R: (float64(p[0]) + 1) / 2, G: (float64(p[1]) + 1) / 2, B: (float64(p[2]) + 1) / 2}
+			case Albedo:
+				c = color.Color{R: tonemap.EncodeSRGB(float64(p[0])), G: tonemap.EncodeSRGB(float64(p[1])), B: tonemap.EncodeSRGB(float64(p[2]))}
+			case Position:
+				c = color.Color{R: normalize(float64(p[0]), 0), G: normalize(float64(p[1]), 1), B: normalize(float64(p[2]), 2)}
+			case ObjectIndex, MaterialIndex:
+				c = indexColor(int(p[0]))
+			default:
+				c = heat(normalize(float64(p[0]), 0))
+			}
+			v := c.PixelValue()
+			img.Set(x, y, clr.NRGBA{
+				R: uint8(v >> 16 & 0xFF),
+				G: uint8(v >> 8 & 0xFF),
+				B: uint8(v & 0xFF),
 				A: 255,
 			})
-			k++
 		}
 	}
 
 	return img
 }
+
+// heat returns the color of t in [0,1] on a blue, cyan, green, yellow, red scale
+func heat(t float64) color.Color {
+	t = math.Max(0, math.Min(1, t)) * 4
+	f := t - math.Floor(t)
+	switch int(t) {
+	case 0:
+		return color.Color{R: 0, G: f, B: 1}
+	case 1:
+		return color.Color{R: 0, G: 1, B: 1 - f}
+	case 2:
+		return color.Color{R: f, G: 1, B: 0}
+	case 3:
+		return color.Color{R: 1, G: 1 - f, B: 0}
+	default:
+		return color.Color{R: 1, G: 0, B: 0}
+	}
+}
+
+// indexColor returns a distinct color for each index (black for negative ones)
+func indexColor(index int) color.Color {
+	if index < 0 {
+		return color.Black
+	}
+	// spread the hues with the golden ratio
+	h := math.Mod(float64(index)*0.618033988749895, 1.0) * 6.0
+	f := h - math.Floor(h)
+	switch int(h) {
+	case 0:
+		return color.Color{R: 1, G: f, B: 0.2}
+	case 1:
+		return color.Color{R: 1 - f, G: 1, B: 0.2}
+	case 2:
+		return color.Color{R: 0.2, G: 1, B: f}
+	case 3:
+		return color.Color{R: 0.2, G: 1 - f, B: 1}
+	case 4:
+		return color.Color{R: f, G: 0.2, B: 1}
+	default:
+		return color.Color{R: 1, G: 0.2, B: 1 - f}
+	}
+}
+
+// Denoise filters the noise of the radiance, guided by the albedo and normal AOVs (which must have been rendered)
+func (fb *Framebuffer) Denoise(settings denoise.Settings) error {
+	albedos, normals := fb.AOVs[Albedo], fb.AOVs[Normal]
+	if albedos == nil || normals == nil {
+		return fmt.Errorf("denoising requires the albedo and normal AOVs")
+	}
+
+	n := fb.Width * fb.Height
+	c, albedo, normal := make([]color.Color, n), make([]color.Color, n), make([]geometry.Vec3, n)
+	for k := 0; k < n; k++ {
+		c[k] = fb.At(k%fb.Width, k/fb.Width)
+		albedo[k] = color.Color{R: float64(albedos[3*k]), G: float64(albedos[3*k+1]), B: float64(albedos[3*k+2])}
+		normal[k] = geometry.Vec3{X: float64(normals[3*k]), Y: float64(normals[3*k+1]), Z: float64(normals[3*k+2])}
+	}
+
+	for k, d := range denoise.ATrous(fb.Width, fb.Height, c, albedo, normal, settings) {
+		p := fb.Pix[4*k : 4*k+3]
+		p[0], p[1], p[2] = float32(d.R), float32(d.G), float32(d.B)
+	}
+	return nil
+}
+
+// Retain drops the AOVs which are not listed
+func (fb *Framebuffer) Retain(aovs ...AOV) {
+	for a := range fb.AOVs {
+		if !slices.Contains(aovs, a) {
+			delete(fb.AOVs, a)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"testing"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/denoise"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/imageio"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/sampler"
+)
+
+func TestFramebufferImageIntegralAOVs(t *testing.T) {
+	fb := NewFramebuffer(2, 1, Depth, ObjectIndex, MaterialIndex, SampleCount)
+	fb.AOVs[ObjectIndex][1] = 3001
+	fb.AOVs[SampleCount][1] = 4097
+
+	expected := map[string]imageio.PixelType{"depth.Z": "", "object.id": imageio.Float, "material.id": imageio.Float, "samples.count": imageio.Float}
+	img := fb.Image()
+	for _, c := range img.Channels[4:] {
+		if pixelType, ok := expected[c.Name]; !ok || c.PixelType != pixelType {
+			t.Errorf("Expected the pixel type %q for %v, but got %q", pixelType, c.Name, c.PixelType)
+		}
+		if c.Name == "object.id" && c.Values[1] != 3001 {
+			t.Errorf("Expected %v, but got %v", 3001, c.Values[1])
+		}
+	}
+	if len(img.Channels) != 4+len(expected) {
+		t.Errorf("Expected %v channels, but got %v", 4+len(expected), len(img.Channels))
+	}
+}
+
+func TestFramebufferDenoise(t *testing.T) {
+	// a diffuse sphere on a ground lit by the sky
+	world := HittableList{
+		Sphere{Center: geometry.Point3{Y: -100.5}, Radius: 100, Material: Lambertian{albedo: clr.Color{R: 0.5, G: 0.5, B: 0.5}}},
+		Sphere{Radius: 0.5, Material: Lambertian{albedo: clr.Color{R: 0.8, G: 0.3, B: 0.2}}},
+	}
+	render := func(samples int, seed int64) *Framebuffer {
+		scene := NewScene(32, 24, samples, testCamera(), world, WithSampler(sampler.Independent, seed), WithAOVs(Albedo, Normal))
+		fb, completed, err := scene.Render(4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-completed
+		return fb
+	}
+	colors := func(fb *Framebuffer) []clr.Color {
+		c := make([]clr.Color, fb.Width*fb.Height)
+		for k := range c {
+			c[k] = fb.At(k%fb.Width, k/fb.Width)
+		}
+		return c
+	}
+
+	reference := colors(render(1024, 1))
+	fb := render(4, 2024)
+	noisy := colors(fb)
+	if err := fb.Denoise(denoise.DefaultSettings()); err != nil {
+		t.Fatal(err)
+	}
+	noisyError, denoisedError := denoise.RelativeMSE(noisy, reference), denoise.RelativeMSE(colors(fb), reference)
+	if denoisedError >= noisyError/2 {
+		t.Errorf("Expected the denoiser to halve the error %v at least, but got %v", noisyError, denoisedError)
+	}
+
+	// the denoiser requires the guides
+	if err := NewFramebuffer(2, 2, Albedo).Denoise(denoise.DefaultSettings()); err == nil {
+		t.Errorf("Expected an error without the normal AOV")
+	}
+}
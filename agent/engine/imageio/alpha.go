@@ -0,0 +1,40 @@
+package imageio
+
+import "fmt"
+
+// Alpha defines how colors are stored along the alpha channel
+type Alpha string
+
+const (
+	Straight      Alpha = "straight"      // colors are independent of alpha (PNG convention)
+	Premultiplied Alpha = "premultiplied" // colors are multiplied by alpha (OpenEXR convention)
+)
+
+// Validate checks the alpha mode (empty meaning the convention of the format)
+func (a Alpha) Validate() error {
+	switch a {
+	case "", Straight, Premultiplied:
+		return nil
+	default:
+		return fmt.Errorf("unknown alpha: %s", a)
+	}
+}
+
+// Unpremultiply divides the R, G and B channels of the image by its A channel (when it has one, fully transparent
+// pixels becoming black)
+func (img *Image) Unpremultiply() {
+	alpha := img.Channel("A")
+	if alpha == nil {
+		return
+	}
+	for _, name := range []string{"R", "G", "B"} {
+		values := img.Channel(name)
+		for k := range values {
+			if alpha[k] > 0 {
+				values[k] /= alpha[k]
+			} else {
+				values[k] = 0
+			}
+		}
+	}
+}
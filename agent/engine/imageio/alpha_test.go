@@ -0,0 +1,32 @@
+package imageio
+
+import "testing"
+
+func TestUnpremultiply(t *testing.T) {
+	img := NewImage(3, 1, "R", "G", "B", "A")
+	copy(img.Channel("R"), []float32{2, 1, 0.5})
+	copy(img.Channel("G"), []float32{1, 0.5, 0})
+	copy(img.Channel("A"), []float32{1, 0.5, 0})
+
+	img.Unpremultiply()
+	cases := []struct {
+		channel  string
+		expected []float32
+	}{
+		{"R", []float32{2, 2, 0}},
+		{"G", []float32{1, 1, 0}},
+		{"A", []float32{1, 0.5, 0}},
+	}
+	for _, tc := range cases {
+		for k, v := range img.Channel(tc.channel) {
+			if v != tc.expected[k] {
+				t.Errorf("Expected %v, but got %v (%v)", tc.expected, img.Channel(tc.channel), tc.channel)
+				break
+			}
+		}
+	}
+
+	if err := Alpha("associated").Validate(); err == nil {
+		t.Errorf("Expected an error for an unknown alpha")
+	}
+}
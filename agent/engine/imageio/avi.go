@@ -0,0 +1,185 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+// aviWriter writes Motion JPEG streams in an AVI (RIFF) container
+//
+//	the chunks of the frames are written as soon as the frames are given and the index at the end. The sizes of
+//	the RIFF and movi lists are only known then: they are patched when the writer can seek, otherwise they are
+//	left to 0 (players then read the frames up to the end of the file).
+type aviWriter struct {
+	w             io.Writer
+	width, height int
+	frames        int
+	fps           float64
+	options       JPEGOptions
+
+	started bool
+	start   int64       // position of the RIFF header (when seeking)
+	movi    uint32      // size of the movi list so far
+	index   [][2]uint32 // offset (relative to the movi list) and size of the frames
+}
+
+// AVI flags
+const (
+	aviHasIndex = 0x10
+	aviKeyFrame = 0x10
+)
+
+// NewAVIWriter creates a writer of MJPEG AVI files of the given number of frames (as advertised in the header)
+func NewAVIWriter(w io.Writer, width, height, frames int, fps float64, options JPEGOptions) VideoWriter {
+	return &aviWriter{w: w, width: width, height: height, frames: frames, fps: fps, options: options}
+}
+
+func (a *aviWriter) WriteFrame(img *image.NRGBA) error {
+	b := img.Bounds()
+	if b.Dx() != a.width || b.Dy() != a.height {
+		return fmt.Errorf("frame is %vx%v, expected %vx%v", b.Dx(), b.Dy(), a.width, a.height)
+	}
+	if err := a.header(); err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("00dc")
+	binary.Write(buf, binary.LittleEndian, uint32(0))
+	if err := WriteJPEG(buf, img, a.options, nil); err != nil {
+		return err
+	}
+	size := buf.Len() - 8
+	if size%2 == 1 {
+		buf.WriteByte(0) // chunks are word aligned
+	}
+	data := buf.Bytes()
+	binary.LittleEndian.PutUint32(data[4:], uint32(size))
+
+	// offsets start at the movi type
+	a.index = append(a.index, [2]uint32{4 + a.movi, uint32(size)})
+	a.movi += uint32(len(data))
+	_, err := a.w.Write(data)
+	return err
+}
+
+func (a *aviWriter) Close() error {
+	if err := a.header(); err != nil {
+		return err
+	}
+
+	le := binary.LittleEndian
+	buf := &bytes.Buffer{}
+	buf.WriteString("idx1")
+	binary.Write(buf, le, uint32(16*len(a.index)))
+	for _, entry := range a.index {
+		buf.WriteString("00dc")
+		binary.Write(buf, le, [3]uint32{aviKeyFrame, entry[0], entry[1]})
+	}
+	if _, err := a.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	ws, ok := a.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+	end, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	sizes := map[int64]uint32{
+		4:                 uint32(end - a.start - 8), // RIFF
+		aviMoviSizeOffset: 4 + a.movi,
+	}
+	for offset, size := range sizes {
+		if _, err := ws.Seek(a.start+offset, io.SeekStart); err != nil {
+			return err
+		}
+		if err := binary.Write(ws, le, size); err != nil {
+			return err
+		}
+	}
+	_, err = ws.Seek(end, io.SeekStart)
+	return err
+}
+
+// sizes of the header lists (in bytes, without their own fourcc and size)
+const (
+	aviStrlSize = 4 + (8 + 56) + (8 + 40)
+	aviHdrlSize = 4 + (8 + 56) + (8 + aviStrlSize)
+)
+
+// aviMoviSizeOffset is the offset of the size of the movi list (after the RIFF header and the hdrl list)
+const aviMoviSizeOffset = 12 + 8 + aviHdrlSize + 4
+
+// header writes the RIFF header, the stream description and the start of the movi list (once)
+func (a *aviWriter) header() error {
+	if a.started {
+		return nil
+	}
+	a.started = true
+	if ws, ok := a.w.(io.WriteSeeker); ok {
+		start, err := ws.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		a.start = start
+	}
+
+	le := binary.LittleEndian
+	rate, scale := frameRate(a.fps)
+	frameTime := uint32(0)
+	if a.fps > 0 {
+		frameTime = uint32(math.Round(1e6 / a.fps))
+	}
+
+	buf := &bytes.Buffer{}
+	list := func(typ string, size uint32, name string) {
+		buf.WriteString(typ)
+		binary.Write(buf, le, size)
+		buf.WriteString(name)
+	}
+	chunk := func(name string, values ...any) {
+		data := &bytes.Buffer{}
+		for _, v := range values {
+			if s, ok := v.(string); ok {
+				data.WriteString(s)
+			} else {
+				binary.Write(data, le, v)
+			}
+		}
+		buf.WriteString(name)
+		binary.Write(buf, le, uint32(data.Len()))
+		buf.Write(data.Bytes())
+	}
+
+	list("RIFF", 0, "AVI ")
+	list("LIST", aviHdrlSize, "hdrl")
+	chunk("avih",
+		[10]uint32{frameTime, 0, 0, aviHasIndex, uint32(a.frames), 0, 1, 0, uint32(a.width), uint32(a.height)},
+		[4]uint32{}) // reserved
+	list("LIST", aviStrlSize, "strl")
+	chunk("strh", "vids", "MJPG",
+		uint32(0),   // flags
+		[2]uint16{}, // priority and language
+		uint32(0),   // initial frames
+		[2]uint32{uint32(scale), uint32(rate)},
+		[3]uint32{0, uint32(a.frames), 0}, // start, length and suggested buffer size
+		int32(-1),                         // default quality
+		uint32(0),                         // sample size
+		[4]int16{0, 0, int16(a.width), int16(a.height)})
+	chunk("strf",
+		[3]uint32{40, uint32(a.width), uint32(a.height)},
+		[2]uint16{1, 24}, // planes and bits per pixel
+		"MJPG",
+		[5]uint32{uint32(3 * a.width * a.height), 0, 0, 0, 0})
+	list("LIST", 0, "movi")
+
+	_, err := a.w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,137 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"os"
+	"testing"
+)
+
+// chunk is a RIFF chunk (or list) and its position in the file
+type chunk struct {
+	id       string
+	offset   int // position of the data
+	size     int
+	children []chunk
+}
+
+// readChunks parses the RIFF chunks of data (within [offset,end))
+func readChunks(data []byte, offset, end int) []chunk {
+	var chunks []chunk
+	for offset+8 <= end {
+		c := chunk{id: string(data[offset : offset+4]), offset: offset + 8, size: int(binary.LittleEndian.Uint32(data[offset+4:]))}
+		if c.id == "RIFF" || c.id == "LIST" {
+			c.id = string(data[offset+8 : offset+12])
+			c.children = readChunks(data, offset+12, offset+8+c.size)
+		}
+		chunks = append(chunks, c)
+		offset += 8 + c.size + c.size%2
+	}
+	return chunks
+}
+
+func find(chunks []chunk, id string) *chunk {
+	for i := range chunks {
+		if chunks[i].id == id {
+			return &chunks[i]
+		}
+	}
+	return nil
+}
+
+func frames(colors ...color.NRGBA) []*image.NRGBA {
+	result := make([]*image.NRGBA, len(colors))
+	for i, c := range colors {
+		result[i] = image.NewNRGBA(image.Rect(0, 0, 16, 8))
+		for k := 0; k < len(result[i].Pix); k += 4 {
+			copy(result[i].Pix[k:], []uint8{c.R, c.G, c.B, c.A})
+		}
+	}
+	return result
+}
+
+func writeAVI(t *testing.T, w io.Writer, images []*image.NRGBA) {
+	v := NewAVIWriter(w, 16, 8, len(images), 25, DefaultJPEGOptions())
+	for _, img := range images {
+		if err := v.WriteFrame(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := v.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAVIWriter(t *testing.T) {
+	images := frames(color.NRGBA{R: 255, A: 255}, color.NRGBA{G: 255, A: 255}, color.NRGBA{B: 255, A: 255})
+
+	// a file can seek so the sizes of the lists are patched
+	f, err := os.CreateTemp(t.TempDir(), "*.avi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeAVI(t, f, images)
+	f.Close()
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	riff := readChunks(data, 0, len(data))
+	if len(riff) != 1 || riff[0].id != "AVI " || riff[0].size != len(data)-8 {
+		t.Fatalf("Expected a single AVI RIFF of %v bytes, but got %v", len(data)-8, riff)
+	}
+	hdrl, movi, idx1 := find(riff[0].children, "hdrl"), find(riff[0].children, "movi"), find(riff[0].children, "idx1")
+	if hdrl == nil || movi == nil || idx1 == nil {
+		t.Fatalf("Expected hdrl, movi and idx1, but got %v", riff[0].children)
+	}
+
+	avih := find(hdrl.children, "avih")
+	if n := binary.LittleEndian.Uint32(data[avih.offset+16:]); n != 3 {
+		t.Errorf("Expected 3 frames, but got %v", n)
+	}
+	if us := binary.LittleEndian.Uint32(data[avih.offset:]); us != 40000 {
+		t.Errorf("Expected 40000us per frame, but got %v", us)
+	}
+
+	if len(movi.children) != 3 || idx1.size != 3*16 {
+		t.Fatalf("Expected 3 frames and index entries, but got %v and %v", len(movi.children), idx1.size/16)
+	}
+	for i, c := range movi.children {
+		img, err := jpeg.Decode(bytes.NewReader(data[c.offset : c.offset+c.size]))
+		if err != nil {
+			t.Fatalf("Expected a JPEG frame: %v", err)
+		}
+		r, g, b, _ := img.At(8, 4).RGBA()
+		expected := images[i].NRGBAAt(8, 4)
+		if abs(int(r>>8)-int(expected.R)) > 4 || abs(int(g>>8)-int(expected.G)) > 4 || abs(int(b>>8)-int(expected.B)) > 4 {
+			t.Errorf("Expected %v, but got %v %v %v (frame %v)", expected, r>>8, g>>8, b>>8, i)
+		}
+
+		// the index points at the chunk relative to the movi type
+		entry := data[idx1.offset+16*i:]
+		offset := movi.offset + int(binary.LittleEndian.Uint32(entry[8:]))
+		if offset != c.offset-8 || int(binary.LittleEndian.Uint32(entry[12:])) != c.size {
+			t.Errorf("Expected the index of frame %v at %v, but got %v", i, c.offset-8, offset)
+		}
+	}
+
+	// without seeking, the sizes of the lists are left to 0 but the frames are the same
+	buf := &bytes.Buffer{}
+	writeAVI(t, buf, images)
+	streamed := buf.Bytes()
+	if !bytes.Equal(streamed[8:aviMoviSizeOffset], data[8:aviMoviSizeOffset]) || !bytes.Equal(streamed[aviMoviSizeOffset+4:], data[aviMoviSizeOffset+4:]) {
+		t.Errorf("Expected the same stream but the sizes")
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,275 @@
+package imageio
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Compression defines how the scanlines of an OpenEXR file are compressed
+type Compression string
+
+const (
+	NoCompression  Compression = "none"
+	ZIPCompression Compression = "zip" // zlib, blocks of 16 scanlines
+)
+
+// PixelType defines how the values of an OpenEXR file are stored
+type PixelType string
+
+const (
+	Half  PixelType = "half"  // 16 bits floating point
+	Float PixelType = "float" // 32 bits floating point
+)
+
+// EXROptions defines the encoding of an OpenEXR file
+type EXROptions struct {
+	Compression Compression `json:"compression"`
+	PixelType   PixelType   `json:"pixelType"`
+}
+
+// DefaultEXROptions returns ZIP compressed half values (the most common flavor of OpenEXR)
+func DefaultEXROptions() EXROptions {
+	return EXROptions{Compression: ZIPCompression, PixelType: Half}
+}
+
+// Validate checks the options
+func (o *EXROptions) Validate() error {
+	switch o.Compression {
+	case NoCompression, ZIPCompression:
+	default:
+		return fmt.Errorf("unknown EXR compression: %s", o.Compression)
+	}
+	switch o.PixelType {
+	case Half, Float:
+	default:
+		return fmt.Errorf("unknown EXR pixel type: %s", o.PixelType)
+	}
+	return nil
+}
+
+// values used in the file for compressions and pixel types
+const (
+	exrNoCompression  = 0
+	exrZIPCompression = 3
+	exrHalf           = 1
+	exrFloat          = 2
+)
+
+// WriteEXR encodes the image as a single part scanline OpenEXR file (channels named layer.channel form a
+// multi-layer file, attributes are saved as string attributes)
+func WriteEXR(w io.Writer, img *Image, options EXROptions) error {
+	if err := img.validate(); err != nil {
+		return err
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	// channels must be stored in alphabetical order
+	channels := make([]Channel, len(img.Channels))
+	copy(channels, img.Channels)
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Name < channels[j].Name })
+
+	// pixel type of each channel, and size of a pixel (all the channels)
+	pixelTypes := make([]int32, len(channels))
+	size := 0
+	for i, c := range channels {
+		pixelTypes[i] = exrHalf
+		if c.PixelType == Float || (c.PixelType == "" && options.PixelType == Float) {
+			pixelTypes[i] = exrFloat
+		}
+		size += exrSize(pixelTypes[i])
+	}
+	compression, linesPerBlock := byte(exrNoCompression), 1
+	if options.Compression == ZIPCompression {
+		compression, linesPerBlock = exrZIPCompression, 16
+	}
+
+	header := &bytes.Buffer{}
+	le := binary.LittleEndian
+	binary.Write(header, le, uint32(20000630)) // magic number
+	binary.Write(header, le, uint32(2))        // version 2, single part scanline
+
+	chlist := &bytes.Buffer{}
+	for i, c := range channels {
+		chlist.WriteString(c.Name)
+		chlist.WriteByte(0)
+		binary.Write(chlist, le, pixelTypes[i])
+		chlist.Write([]byte{0, 0, 0, 0}) // pLinear and reserved
+		binary.Write(chlist, le, [2]int32{1, 1})
+	}
+	chlist.WriteByte(0)
+
+	window := [4]int32{0, 0, int32(img.Width - 1), int32(img.Height - 1)}
+	writeAttribute(header, "channels", "chlist", chlist.Bytes())
+	writeAttribute(header, "compression", "compression", []byte{compression})
+	writeAttribute(header, "dataWindow", "box2i", window)
+	writeAttribute(header, "displayWindow", "box2i", window)
+	writeAttribute(header, "lineOrder", "lineOrder", []byte{0}) // increasing y
+	writeAttribute(header, "pixelAspectRatio", "float", float32(1))
+	writeAttribute(header, "screenWindowCenter", "v2f", [2]float32{0, 0})
+	writeAttribute(header, "screenWindowWidth", "float", float32(1))
+	for _, name := range sortedKeys(img.Attributes) {
+		writeAttribute(header, name, "string", []byte(img.Attributes[name]))
+	}
+	header.WriteByte(0)
+
+	// encode the blocks of scanlines
+	blockCount := (img.Height + linesPerBlock - 1) / linesPerBlock
+	blocks := make([][]byte, blockCount)
+	for b := range blocks {
+		y0, y1 := b*linesPerBlock, min((b+1)*linesPerBlock, img.Height)
+		raw := make([]byte, 0, (y1-y0)*img.Width*size)
+		for y := y0; y < y1; y++ {
+			for i, c := range channels {
+				for _, v := range c.Values[y*img.Width : (y+1)*img.Width] {
+					if pixelTypes[i] == exrHalf {
+						raw = le.AppendUint16(raw, half(v))
+					} else {
+						raw = le.AppendUint32(raw, math.Float32bits(v))
+					}
+				}
+			}
+		}
+
+		data := raw
+		if compression == exrZIPCompression {
+			compressed, err := zipBlock(raw)
+			if err != nil {
+				return err
+			}
+			// readers detect uncompressed blocks by their size
+			if len(compressed) < len(raw) {
+				data = compressed
+			}
+		}
+
+		chunk := make([]byte, 0, 8+len(data))
+		chunk = le.AppendUint32(chunk, uint32(y0))
+		chunk = le.AppendUint32(chunk, uint32(len(data)))
+		blocks[b] = append(chunk, data...)
+	}
+
+	// the offset table follows the header
+	offsets := make([]uint64, blockCount)
+	offset := uint64(header.Len() + 8*blockCount)
+	for b, chunk := range blocks {
+		offsets[b] = offset
+		offset += uint64(len(chunk))
+	}
+
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	if err := binary.Write(w, le, offsets); err != nil {
+		return err
+	}
+	for _, chunk := range blocks {
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exrSize returns the size in bytes of a value of the pixel type
+func exrSize(pixelType int32) int {
+	if pixelType == exrFloat {
+		return 4
+	}
+	return 2
+}
+
+// writeAttribute writes a header attribute (name, type, size and value)
+func writeAttribute(w *bytes.Buffer, name, typ string, value any) {
+	data, ok := value.([]byte)
+	if !ok {
+		buf := &bytes.Buffer{}
+		binary.Write(buf, binary.LittleEndian, value)
+		data = buf.Bytes()
+	}
+	w.WriteString(name)
+	w.WriteByte(0)
+	w.WriteString(typ)
+	w.WriteByte(0)
+	binary.Write(w, binary.LittleEndian, int32(len(data)))
+	w.Write(data)
+}
+
+// zipBlock compresses a block the way OpenEXR does: bytes are split in two halves (even and odd indices),
+// delta encoded then deflated with zlib
+func zipBlock(raw []byte) ([]byte, error) {
+	tmp := make([]byte, len(raw))
+	middle := (len(raw) + 1) / 2
+	for i, v := range raw {
+		if i%2 == 0 {
+			tmp[i/2] = v
+		} else {
+			tmp[middle+i/2] = v
+		}
+	}
+
+	previous := tmp[0]
+	for i := 1; i < len(tmp); i++ {
+		current := tmp[i]
+		tmp[i] = current - previous + 128
+		previous = current
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	if _, err := zw.Write(tmp); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// half converts a 32 bits float to a 16 bits float (rounding to the nearest even value, overflowing to infinity)
+func half(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exponent := int(bits>>23) & 0xFF
+	mantissa := bits & 0x7FFFFF
+
+	if exponent == 0xFF {
+		if mantissa != 0 {
+			return sign | 0x7E00 // NaN
+		}
+		return sign | 0x7C00 // infinity
+	}
+
+	e := exponent - 127 + 15
+	switch {
+	case e >= 0x1F:
+		return sign | 0x7C00
+	case e <= 0:
+		// subnormal (or zero)
+		if e < -10 {
+			return sign
+		}
+		mantissa |= 0x800000
+		shift := uint(14 - e)
+		h := mantissa >> shift
+		remainder, halfway := mantissa&(1<<shift-1), uint32(1)<<(shift-1)
+		if remainder > halfway || (remainder == halfway && h&1 == 1) {
+			h++
+		}
+		return sign | uint16(h)
+	default:
+		h := uint32(e)<<10 | mantissa>>13
+		remainder := mantissa & 0x1FFF
+		if remainder > 0x1000 || (remainder == 0x1000 && h&1 == 1) {
+			// may carry into the exponent, up to infinity
+			h++
+		}
+		return sign | uint16(h)
+	}
+}
@@ -0,0 +1,252 @@
+package imageio
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestHalf(t *testing.T) {
+	tests := []struct {
+		f        float32
+		expected uint16
+	}{
+		{0, 0x0000},
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1, 0x3C00},
+		{-2, 0xC000},
+		{0.5, 0x3800},
+		{65504, 0x7BFF},
+		{100000, 0x7C00},
+		{float32(math.Inf(1)), 0x7C00},
+		{float32(math.Pow(2, -24)), 0x0001},
+		{float32(math.Pow(2, -14)), 0x0400},
+		{1 + 1.0/2048, 0x3C00}, // halfway, rounds to even
+		{1 + 3.0/2048, 0x3C02}, // halfway, rounds to even
+	}
+
+	for _, test := range tests {
+		if h := half(test.f); h != test.expected {
+			t.Errorf("Expected %#04x, but got %#04x (%v)", test.expected, h, test.f)
+		}
+	}
+}
+
+// exrFile is the content of an OpenEXR file decoded by readEXR
+type exrFile struct {
+	attributes map[string][]byte
+	channels   []string
+	pixelTypes map[string]int32
+	values     map[string][]float32
+}
+
+// readEXR decodes the subset of OpenEXR produced by WriteEXR
+func readEXR(t *testing.T, data []byte) *exrFile {
+	le := binary.LittleEndian
+	if le.Uint32(data) != 20000630 || le.Uint32(data[4:]) != 2 {
+		t.Fatalf("Invalid magic number or version")
+	}
+
+	f := &exrFile{attributes: map[string][]byte{}, pixelTypes: map[string]int32{}, values: map[string][]float32{}}
+	pos := 8
+	cstring := func() string {
+		end := bytes.IndexByte(data[pos:], 0)
+		s := string(data[pos : pos+end])
+		pos += end + 1
+		return s
+	}
+	for data[pos] != 0 {
+		name := cstring()
+		cstring()
+		size := int(le.Uint32(data[pos:]))
+		f.attributes[name] = data[pos+4 : pos+4+size]
+		pos += 4 + size
+	}
+	pos++
+
+	chlist := f.attributes["channels"]
+	for len(chlist) > 1 {
+		end := bytes.IndexByte(chlist, 0)
+		f.channels = append(f.channels, string(chlist[:end]))
+		f.pixelTypes[string(chlist[:end])] = int32(le.Uint32(chlist[end+1:]))
+		chlist = chlist[end+17:]
+	}
+
+	window := f.attributes["dataWindow"]
+	width, height := int(le.Uint32(window[8:]))+1, int(le.Uint32(window[12:]))+1
+	linesPerBlock, size := 1, 0
+	if f.attributes["compression"][0] == exrZIPCompression {
+		linesPerBlock = 16
+	}
+	for _, c := range f.channels {
+		size += exrSize(f.pixelTypes[c])
+	}
+
+	blockCount := (height + linesPerBlock - 1) / linesPerBlock
+	for b := 0; b < blockCount; b++ {
+		offset := int(le.Uint64(data[pos+8*b:]))
+		y0 := int(le.Uint32(data[offset:]))
+		length := int(le.Uint32(data[offset+4:]))
+		block := data[offset+8 : offset+8+length]
+		lines := min(linesPerBlock, height-y0)
+
+		expected := lines * width * size
+		if len(block) < expected {
+			block = unzipBlock(t, block, expected)
+		}
+		for y := 0; y < lines; y++ {
+			for _, c := range f.channels {
+				for x := 0; x < width; x++ {
+					var v float32
+					if f.pixelTypes[c] == exrHalf {
+						v = fromHalf(le.Uint16(block))
+					} else {
+						v = math.Float32frombits(le.Uint32(block))
+					}
+					f.values[c] = append(f.values[c], v)
+					block = block[exrSize(f.pixelTypes[c]):]
+				}
+			}
+		}
+	}
+
+	return f
+}
+
+func unzipBlock(t *testing.T, block []byte, size int) []byte {
+	zr, err := zlib.NewReader(bytes.NewReader(block))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp, err := io.ReadAll(zr)
+	if err != nil || len(tmp) != size {
+		t.Fatalf("Invalid compressed block (%v bytes): %v", len(tmp), err)
+	}
+	for i := 1; i < len(tmp); i++ {
+		tmp[i] = tmp[i-1] + tmp[i] - 128
+	}
+	raw := make([]byte, size)
+	middle := (size + 1) / 2
+	for i := range raw {
+		if i%2 == 0 {
+			raw[i] = tmp[i/2]
+		} else {
+			raw[i] = tmp[middle+i/2]
+		}
+	}
+	return raw
+}
+
+func fromHalf(h uint16) float32 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1.0
+	}
+	exponent, mantissa := int(h>>10&0x1F), float64(h&0x3FF)
+	switch exponent {
+	case 0:
+		return float32(sign * mantissa * math.Pow(2, -24))
+	case 0x1F:
+		return float32(math.Inf(int(sign)))
+	}
+	return float32(sign * (1 + mantissa/1024) * math.Pow(2, float64(exponent-15)))
+}
+
+func testImage(width, height int) *Image {
+	img := NewImage(width, height, "R", "G", "B", "A")
+	for k := 0; k < width*height; k++ {
+		img.Channels[0].Values[k] = float32(k) / 8.0
+		img.Channels[1].Values[k] = 0.25
+		img.Channels[2].Values[k] = float32(k%7) * 100.0
+		img.Channels[3].Values[k] = 1
+	}
+	return img
+}
+
+func TestWriteEXR(t *testing.T) {
+	img := testImage(37, 21)
+	img.Attributes = map[string]string{"owner": "raytracer"}
+
+	for _, options := range []EXROptions{
+		{Compression: NoCompression, PixelType: Half},
+		{Compression: NoCompression, PixelType: Float},
+		{Compression: ZIPCompression, PixelType: Half},
+		{Compression: ZIPCompression, PixelType: Float},
+	} {
+		buf := &bytes.Buffer{}
+		if err := WriteEXR(buf, img, options); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		f := readEXR(t, buf.Bytes())
+		if owner := string(f.attributes["owner"]); owner != "raytracer" {
+			t.Errorf("Expected raytracer, but got %v", owner)
+		}
+		if len(f.channels) != 4 || f.channels[0] != "A" || f.channels[3] != "R" {
+			t.Errorf("Expected channels sorted by name, but got %v", f.channels)
+		}
+		for _, c := range img.Channels {
+			values := f.values[c.Name]
+			if len(values) != len(c.Values) {
+				t.Fatalf("Expected %v values, but got %v (%v)", len(c.Values), len(values), options)
+			}
+			for k, v := range c.Values {
+				// halves keep 11 significant bits
+				if math.Abs(float64(values[k]-v)) > math.Abs(float64(v))/1024 {
+					t.Errorf("Expected %v, but got %v (%v %v[%v])", v, values[k], options, c.Name, k)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteEXRErrors(t *testing.T) {
+	tests := []struct {
+		img     *Image
+		options EXROptions
+	}{
+		{testImage(4, 4), EXROptions{Compression: "rle", PixelType: Half}},
+		{testImage(4, 4), EXROptions{Compression: NoCompression, PixelType: "uint"}},
+		{&Image{Width: 4, Height: 4, Channels: []Channel{{Name: "R", Values: make([]float32, 3)}}}, DefaultEXROptions()},
+		{&Image{Width: 1, Height: 1, Channels: []Channel{{Name: "R", Values: make([]float32, 1), PixelType: "uint"}}}, DefaultEXROptions()},
+	}
+
+	for _, test := range tests {
+		if err := WriteEXR(io.Discard, test.img, test.options); err == nil {
+			t.Errorf("Expected an error for %v", test.options)
+		}
+	}
+}
+
+func TestWriteEXRChannelPixelType(t *testing.T) {
+	// the integers above 2048 are kept exact by the float channel of a half file
+	img := NewImage(5, 3, "R", "object.id")
+	img.Channels[1].PixelType = Float
+	for k := range img.Channels[0].Values {
+		img.Channels[0].Values[k] = 2049
+		img.Channels[1].Values[k] = float32(2049 + k*1000)
+	}
+
+	for _, compression := range []Compression{NoCompression, ZIPCompression} {
+		buf := &bytes.Buffer{}
+		if err := WriteEXR(buf, img, EXROptions{Compression: compression, PixelType: Half}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		f := readEXR(t, buf.Bytes())
+		if f.pixelTypes["R"] != exrHalf || f.pixelTypes["object.id"] != exrFloat {
+			t.Errorf("Expected a half and a float channel, but got %v", f.pixelTypes)
+		}
+		for k, v := range img.Channels[1].Values {
+			if f.values["object.id"][k] != v {
+				t.Errorf("Expected %v, but got %v (%v)", v, f.values["object.id"][k], compression)
+			}
+		}
+		if r := f.values["R"][0]; r != 2048 {
+			t.Errorf("Expected the half value rounded to 2048, but got %v", r)
+		}
+	}
+}
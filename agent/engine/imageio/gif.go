@@ -0,0 +1,138 @@
+package imageio
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"sort"
+)
+
+// GIFOptions defines the encoding of (animated) GIF files
+type GIFOptions struct {
+	Colors int  `json:"colors"` // size of the palette, shared by all the frames
+	Dither bool `json:"dither"` // Floyd-Steinberg error diffusion (smoother gradients, noisier flat areas)
+}
+
+// DefaultGIFOptions returns a dithered 256 colors palette
+func DefaultGIFOptions() GIFOptions {
+	return GIFOptions{Colors: 256, Dither: true}
+}
+
+// Validate checks the options
+func (o *GIFOptions) Validate() error {
+	if o.Colors < 2 || o.Colors > 256 {
+		return fmt.Errorf("GIF colors must be in [2,256]: %v", o.Colors)
+	}
+	return nil
+}
+
+// WriteGIF encodes the frames as an endlessly looping GIF, each frame being shown for delay hundredths of a second
+//
+//	the palette is computed once for all the frames (median cut) so that colors do not flicker
+func WriteGIF(w io.Writer, frames []*image.NRGBA, delay int, options GIFOptions) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("GIF requires at least one frame")
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	palette := quantize(frames, options.Colors)
+	anim := &gif.GIF{Config: image.Config{ColorModel: palette, Width: frames[0].Bounds().Dx(), Height: frames[0].Bounds().Dy()}}
+	for _, frame := range frames {
+		b := frame.Bounds()
+		paletted := image.NewPaletted(b, palette)
+		if options.Dither {
+			draw.FloydSteinberg.Draw(paletted, b, frame, b.Min)
+		} else {
+			draw.Draw(paletted, b, frame, b.Min, draw.Src)
+		}
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// maxQuantizeSamples is the number of pixels used to compute a palette (large images are subsampled)
+const maxQuantizeSamples = 1 << 16
+
+// quantize computes a palette of at most n colors with the median cut algorithm: the box of colors with the
+// widest range is split at the median of its widest component (between two different values) until there are n boxes, each giving the average
+// of its colors
+func quantize(frames []*image.NRGBA, n int) color.Palette {
+	total := 0
+	for _, frame := range frames {
+		total += frame.Bounds().Dx() * frame.Bounds().Dy()
+	}
+	step := max(total/maxQuantizeSamples, 1)
+
+	var pixels [][3]uint8
+	i := 0
+	for _, frame := range frames {
+		b := frame.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if i%step == 0 {
+					o := frame.PixOffset(x, y)
+					pixels = append(pixels, [3]uint8{frame.Pix[o], frame.Pix[o+1], frame.Pix[o+2]})
+				}
+				i++
+			}
+		}
+	}
+
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < n {
+		// find the box with the widest range
+		best, bestComponent, bestRange := -1, 0, 0
+		for b, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for c := 0; c < 3; c++ {
+				lo, hi := 255, 0
+				for _, p := range box {
+					lo, hi = min(lo, int(p[c])), max(hi, int(p[c]))
+				}
+				if hi-lo > bestRange {
+					best, bestComponent, bestRange = b, c, hi-lo
+				}
+			}
+		}
+		if best < 0 {
+			break // every box holds a single color
+		}
+
+		box := boxes[best]
+		sort.Slice(box, func(i, j int) bool { return box[i][bestComponent] < box[j][bestComponent] })
+		// move the split to the closest change of value so that equal colors stay in the same box
+		c := bestComponent
+		lower, upper := len(box)/2, len(box)/2
+		for lower > 0 && box[lower][c] == box[lower-1][c] {
+			lower--
+		}
+		for upper < len(box) && box[upper][c] == box[upper-1][c] {
+			upper++
+		}
+		middle := upper
+		if lower > 0 && (upper == len(box) || len(box)/2-lower < upper-len(box)/2) {
+			middle = lower
+		}
+		boxes[best] = box[:middle]
+		boxes = append(boxes, box[middle:])
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var sum [3]int
+		for _, p := range box {
+			sum[0], sum[1], sum[2] = sum[0]+int(p[0]), sum[1]+int(p[1]), sum[2]+int(p[2])
+		}
+		l := max(len(box), 1)
+		palette = append(palette, color.RGBA{R: uint8(sum[0] / l), G: uint8(sum[1] / l), B: uint8(sum[2] / l), A: 255})
+	}
+	return palette
+}
@@ -0,0 +1,81 @@
+package imageio
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+// gradient returns an image going from black (left) to the given color (right)
+func gradient(c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(int(c.R) * x / 63), G: uint8(int(c.G) * x / 63), B: uint8(int(c.B) * x / 63), A: 255})
+		}
+	}
+	return img
+}
+
+func TestWriteGIF(t *testing.T) {
+	frames := []*image.NRGBA{gradient(color.NRGBA{R: 255}), gradient(color.NRGBA{G: 255}), gradient(color.NRGBA{B: 255})}
+
+	for _, dither := range []bool{false, true} {
+		buf := &bytes.Buffer{}
+		if err := WriteGIF(buf, frames, 10, GIFOptions{Colors: 16, Dither: dither}); err != nil {
+			t.Fatal(err)
+		}
+
+		anim, err := gif.DecodeAll(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(anim.Image) != 3 || anim.Delay[2] != 10 {
+			t.Fatalf("Expected 3 frames of 10/100s, but got %v %v", len(anim.Image), anim.Delay)
+		}
+		if len(anim.Image[0].Palette) > 16 {
+			t.Errorf("Expected at most 16 colors, but got %v", len(anim.Image[0].Palette))
+		}
+
+		// the average of the frames must be preserved
+		for f, frame := range frames {
+			var expected, got int
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 64; x++ {
+					r, g, b, _ := frame.At(x, y).RGBA()
+					expected += int(r>>8 + g>>8 + b>>8)
+					r, g, b, _ = anim.Image[f].At(x, y).RGBA()
+					got += int(r>>8 + g>>8 + b>>8)
+				}
+			}
+			if diff := (expected - got) / (64 * 8); diff > 8 || diff < -8 {
+				t.Errorf("Expected an average of %v, but got %v (frame %v, dither %v)", expected/(64*8), got/(64*8), f, dither)
+			}
+		}
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 1))
+	colors := []color.NRGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 0, 0, 255}}
+	for x, c := range colors {
+		img.Set(x, 0, c)
+	}
+
+	// exact when there are fewer colors than entries
+	palette := quantize([]*image.NRGBA{img}, 8)
+	if len(palette) != 3 {
+		t.Fatalf("Expected 3 colors, but got %v", palette)
+	}
+	for _, c := range colors {
+		if palette[palette.Index(c)] != (color.RGBA{c.R, c.G, c.B, 255}) {
+			t.Errorf("Expected %v in the palette, but got %v", c, palette)
+		}
+	}
+
+	if o := (GIFOptions{Colors: 1}); o.Validate() == nil {
+		t.Errorf("Expected an error for a single color")
+	}
+}
@@ -0,0 +1,118 @@
+package imageio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// WriteHDR encodes the R, G and B channels of the image in the Radiance RGBE format (.hdr), using the run
+// length encoding of each scanline when the width allows it
+//
+//	the attributes are saved as NAME=value variables of the header (on a single line)
+func WriteHDR(w io.Writer, img *Image) error {
+	if err := img.validate(); err != nil {
+		return err
+	}
+	r, g, b := img.Channel("R"), img.Channel("G"), img.Channel("B")
+	if r == nil || g == nil || b == nil {
+		return fmt.Errorf("Radiance HDR requires R, G and B channels")
+	}
+
+	for name, value := range img.Attributes {
+		if name == "" || strings.ContainsAny(name, "=\n") || strings.Contains(value, "\n") {
+			return fmt.Errorf("invalid Radiance HDR attribute: %q", name)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n")
+	for _, name := range sortedKeys(img.Attributes) {
+		fmt.Fprintf(bw, "%v=%v\n", name, img.Attributes[name])
+	}
+	fmt.Fprintf(bw, "\n-Y %v +X %v\n", img.Height, img.Width)
+
+	// run length encoding is only defined for widths in [8,32767]
+	rle := img.Width >= 8 && img.Width <= 0x7FFF
+	line := make([]byte, 4*img.Width)
+	for y := 0; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			k := y*img.Width + x
+			copy(line[4*x:], rgbe(r[k], g[k], b[k]))
+		}
+
+		if !rle {
+			bw.Write(line)
+			continue
+		}
+
+		bw.Write([]byte{2, 2, byte(img.Width >> 8), byte(img.Width & 0xFF)})
+		component := make([]byte, img.Width)
+		for c := 0; c < 4; c++ {
+			for x := range component {
+				component[x] = line[4*x+c]
+			}
+			writeRuns(bw, component)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// rgbe converts a color to its shared exponent representation
+func rgbe(r, g, b float32) []byte {
+	v := math.Max(float64(r), math.Max(float64(g), float64(b)))
+	if v < 1e-32 {
+		return []byte{0, 0, 0, 0}
+	}
+	m, e := math.Frexp(v)
+	scale := m * 256.0 / v
+	return []byte{
+		byte(math.Max(float64(r), 0) * scale),
+		byte(math.Max(float64(g), 0) * scale),
+		byte(math.Max(float64(b), 0) * scale),
+		byte(e + 128),
+	}
+}
+
+// writeRuns encodes a component of a scanline as runs (count > 128 followed by the value repeated
+// count - 128 times) and literals (count <= 128 followed by count values)
+func writeRuns(w io.ByteWriter, data []byte) {
+	const minRun = 3
+	for i := 0; i < len(data); {
+		// look for the next run long enough to be worth encoding
+		start := i
+		run := 0
+		for start < len(data) {
+			run = 1
+			for start+run < len(data) && run < 127 && data[start+run] == data[start] {
+				run++
+			}
+			if run >= minRun {
+				break
+			}
+			start += run
+		}
+		if run < minRun {
+			start = len(data)
+		}
+
+		// literals before the run
+		for i < start {
+			n := min(start-i, 128)
+			w.WriteByte(byte(n))
+			for _, v := range data[i : i+n] {
+				w.WriteByte(v)
+			}
+			i += n
+		}
+
+		if start < len(data) {
+			w.WriteByte(byte(128 + run))
+			w.WriteByte(data[start])
+			i = start + run
+		}
+	}
+}
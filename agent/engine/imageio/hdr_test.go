@@ -0,0 +1,126 @@
+package imageio
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// readHDR decodes a Radiance HDR file (flat or run length encoded scanlines)
+func readHDR(t *testing.T, data []byte) (int, int, [][3]float32) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Invalid header: %v", err)
+		}
+		if line == "\n" {
+			break
+		}
+	}
+
+	var width, height int
+	if _, err := fmt.Fscanf(r, "-Y %d +X %d\n", &height, &width); err != nil {
+		t.Fatalf("Invalid resolution: %v", err)
+	}
+
+	pixels := make([][3]float32, 0, width*height)
+	line := make([]byte, 4*width)
+	for y := 0; y < height; y++ {
+		start, _ := r.Peek(2)
+		if start[0] == 2 && start[1] == 2 {
+			r.Discard(4)
+			for c := 0; c < 4; c++ {
+				for x := 0; x < width; {
+					count, _ := r.ReadByte()
+					if count > 128 {
+						v, _ := r.ReadByte()
+						for i := 0; i < int(count)-128; i++ {
+							line[4*x+c] = v
+							x++
+						}
+					} else {
+						for i := 0; i < int(count); i++ {
+							line[4*x+c], _ = r.ReadByte()
+							x++
+						}
+					}
+				}
+			}
+		} else if _, err := r.Read(line); err != nil {
+			t.Fatal(err)
+		}
+
+		for x := 0; x < width; x++ {
+			p := line[4*x : 4*x+4]
+			if p[3] == 0 {
+				pixels = append(pixels, [3]float32{})
+				continue
+			}
+			scale := math.Ldexp(1, int(p[3])-136)
+			pixels = append(pixels, [3]float32{
+				float32((float64(p[0]) + 0.5) * scale),
+				float32((float64(p[1]) + 0.5) * scale),
+				float32((float64(p[2]) + 0.5) * scale),
+			})
+		}
+	}
+
+	if r.Buffered() > 0 {
+		t.Errorf("Expected the end of the file, but %v bytes remain", r.Buffered())
+	}
+	return width, height, pixels
+}
+
+func TestWriteHDR(t *testing.T) {
+	for _, size := range [][2]int{{37, 21}, {5, 3}} {
+		img := testImage(size[0], size[1])
+		buf := &bytes.Buffer{}
+		if err := WriteHDR(buf, img); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		width, height, pixels := readHDR(t, buf.Bytes())
+		if width != img.Width || height != img.Height {
+			t.Fatalf("Expected %vx%v, but got %vx%v", img.Width, img.Height, width, height)
+		}
+		for k, p := range pixels {
+			for c := 0; c < 3; c++ {
+				v := img.Channels[c].Values[k]
+				// the mantissa is 8 bits, shared by the 3 components
+				largest := math.Max(float64(p[0]), math.Max(float64(p[1]), float64(p[2])))
+				if math.Abs(float64(p[c]-v)) > largest/128 {
+					t.Errorf("Expected %v, but got %v (%v[%v])", v, p[c], img.Channels[c].Name, k)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteHDRMissingChannel(t *testing.T) {
+	img := NewImage(4, 4, "R", "G")
+	if err := WriteHDR(&bytes.Buffer{}, img); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestWriteRuns(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected []byte
+	}{
+		{[]byte{1, 2, 3}, []byte{3, 1, 2, 3}},
+		{[]byte{5, 5, 5, 5}, []byte{132, 5}},
+		{[]byte{1, 2, 2, 7, 7, 7, 7, 3}, []byte{3, 1, 2, 2, 132, 7, 1, 3}},
+	}
+
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		writeRuns(buf, test.data)
+		if !bytes.Equal(buf.Bytes(), test.expected) {
+			t.Errorf("Expected %v, but got %v", test.expected, buf.Bytes())
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package imageio
+
+import "fmt"
+
+// Image is a floating point image made of named channels (R, G, B, A...)
+//
+//	each channel holds width x height values, line by line, the first line being the top of the image
+//	Attributes are optional text attributes saved along the image (when the format supports it)
+type Image struct {
+	Width, Height int
+	Channels      []Channel
+	Attributes    map[string]string
+}
+
+// Channel is a named plane of values
+//
+//	PixelType overrides the pixel type of an OpenEXR file for the channel (Float keeps integers such as indices
+//	exact, halves only holding them up to 2048), empty for the one of the file
+type Channel struct {
+	Name      string
+	Values    []float32
+	PixelType PixelType
+}
+
+// NewImage creates a black image with the given channels
+func NewImage(width, height int, names ...string) *Image {
+	img := &Image{Width: width, Height: height, Channels: make([]Channel, len(names))}
+	for i, name := range names {
+		img.Channels[i] = Channel{Name: name, Values: make([]float32, width*height)}
+	}
+	return img
+}
+
+// Channel returns the values of the named channel (nil when the image has no such channel)
+func (img *Image) Channel(name string) []float32 {
+	for _, c := range img.Channels {
+		if c.Name == name {
+			return c.Values
+		}
+	}
+	return nil
+}
+
+// validate checks that every channel has exactly one value per pixel
+func (img *Image) validate() error {
+	if img.Width <= 0 || img.Height <= 0 {
+		return fmt.Errorf("invalid image size %vx%v", img.Width, img.Height)
+	}
+	for _, c := range img.Channels {
+		if len(c.Values) != img.Width*img.Height {
+			return fmt.Errorf("channel %v has %v values, expected %v", c.Name, len(c.Values), img.Width*img.Height)
+		}
+		switch c.PixelType {
+		case "", Half, Float:
+		default:
+			return fmt.Errorf("channel %v has an unknown pixel type: %s", c.Name, c.PixelType)
+		}
+	}
+	return nil
+}
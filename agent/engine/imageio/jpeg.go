@@ -0,0 +1,68 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+)
+
+// JPEGOptions defines the encoding of JPEG files
+type JPEGOptions struct {
+	Quality int `json:"quality"` // 1 (smallest) to 100 (best)
+}
+
+// DefaultJPEGOptions returns a quality of 90
+func DefaultJPEGOptions() JPEGOptions {
+	return JPEGOptions{Quality: 90}
+}
+
+// Validate checks the options
+func (o *JPEGOptions) Validate() error {
+	if o.Quality < 1 || o.Quality > 100 {
+		return fmt.Errorf("JPEG quality must be in [1,100]: %v", o.Quality)
+	}
+	return nil
+}
+
+// jpegMaxComment is the largest comment of a JPEG segment
+const jpegMaxComment = 0xFFFF - 2
+
+// WriteJPEG encodes an 8 bits image as a baseline JPEG (alpha is dropped) with the metadata as JSON in comment
+// segments
+func WriteJPEG(w io.Writer, img image.Image, options JPEGOptions, metadata map[string]string) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	if len(metadata) == 0 {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: options.Quality})
+	}
+
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: options.Quality}); err != nil {
+		return err
+	}
+	comment, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	// the comments follow the start of image marker
+	data := buf.Bytes()
+	segments := []byte{data[0], data[1]}
+	for len(comment) > 0 {
+		n := min(len(comment), jpegMaxComment)
+		segments = append(segments, 0xFF, 0xFE)
+		segments = binary.BigEndian.AppendUint16(segments, uint16(n+2))
+		segments = append(segments, comment[:n]...)
+		comment = comment[n:]
+	}
+	if _, err := w.Write(segments); err != nil {
+		return err
+	}
+	_, err = w.Write(data[2:])
+	return err
+}
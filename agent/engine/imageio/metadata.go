@@ -0,0 +1,260 @@
+package imageio
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MetadataFile is the name of the metadata in archives
+const MetadataFile = "metadata.json"
+
+// ReadMetadata returns the metadata of an image file: the text chunks of a PNG, the string attributes of an
+// OpenEXR file, the variables of the header of a Radiance HDR file, the comments of a JPEG, the description of a
+// TIFF or the metadata file of a ZIP archive
+func ReadMetadata(data []byte) (map[string]string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return readPNGMetadata(data)
+	case bytes.HasPrefix(data, []byte{0x76, 0x2F, 0x31, 0x01}):
+		return readEXRMetadata(data)
+	case bytes.HasPrefix(data, []byte("#?RADIANCE")), bytes.HasPrefix(data, []byte("#?RGBE")):
+		return readHDRMetadata(data)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		return readJPEGMetadata(data)
+	case bytes.HasPrefix(data, []byte("II*\x00")):
+		return readTIFFMetadata(data)
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return readZipMetadata(data)
+	default:
+		return nil, fmt.Errorf("unsupported image format")
+	}
+}
+
+func readPNGMetadata(data []byte) (map[string]string, error) {
+	metadata := map[string]string{}
+	for offset := 8; offset+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		typ := string(data[offset+4 : offset+8])
+		if offset+12+length > len(data) {
+			return nil, fmt.Errorf("truncated PNG %s chunk", typ)
+		}
+		chunk := data[offset+8 : offset+8+length]
+		offset += 12 + length
+
+		key, value, found := bytes.Cut(chunk, []byte{0})
+		if !found {
+			continue
+		}
+		switch typ {
+		case "tEXt":
+			metadata[string(key)] = string(value)
+		case "zTXt":
+			// compression method, then the compressed text
+			if len(value) < 1 {
+				return nil, fmt.Errorf("invalid PNG zTXt chunk")
+			}
+			text, err := inflate(value[1:])
+			if err != nil {
+				return nil, err
+			}
+			metadata[string(key)] = string(text)
+		case "iTXt":
+			// compression flag and method, language, translated keyword, then the text
+			if len(value) < 2 {
+				return nil, fmt.Errorf("invalid PNG iTXt chunk")
+			}
+			compressed := value[0] == 1
+			// skip the language and translated keyword
+			parts := bytes.SplitN(value[2:], []byte{0}, 3)
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("invalid PNG iTXt chunk")
+			}
+			text := parts[2]
+			if compressed {
+				var err error
+				if text, err = inflate(text); err != nil {
+					return nil, err
+				}
+			}
+			metadata[string(key)] = string(text)
+		case "IEND":
+			return metadata, nil
+		}
+	}
+	return metadata, nil
+}
+
+// maxTextBytes is the largest decompressed text of a PNG
+const maxTextBytes = 16 << 20
+
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	text, err := io.ReadAll(io.LimitReader(zr, maxTextBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(text) > maxTextBytes {
+		return nil, fmt.Errorf("PNG text exceeds %v bytes", maxTextBytes)
+	}
+	return text, nil
+}
+
+func readEXRMetadata(data []byte) (map[string]string, error) {
+	metadata := map[string]string{}
+	offset := 8 // magic number and version
+	if len(data) < offset {
+		return nil, fmt.Errorf("truncated EXR header")
+	}
+	readString := func() (string, error) {
+		end := bytes.IndexByte(data[offset:], 0)
+		if end < 0 {
+			return "", fmt.Errorf("truncated EXR header")
+		}
+		s := string(data[offset : offset+end])
+		offset += end + 1
+		return s, nil
+	}
+
+	for {
+		name, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return metadata, nil
+		}
+		typ, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		if offset+4 > len(data) {
+			return nil, fmt.Errorf("truncated EXR header")
+		}
+		size := int(int32(binary.LittleEndian.Uint32(data[offset:])))
+		offset += 4
+		if size < 0 || size > len(data)-offset {
+			return nil, fmt.Errorf("invalid EXR attribute size: %v", size)
+		}
+		if typ == "string" {
+			metadata[name] = string(data[offset : offset+size])
+		}
+		offset += size
+	}
+}
+
+func readHDRMetadata(data []byte) (map[string]string, error) {
+	metadata := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, len(data))
+	s.Scan() // magic
+	for s.Scan() && s.Text() != "" {
+		key, value, found := strings.Cut(s.Text(), "=")
+		if found && key != "FORMAT" {
+			metadata[key] = value
+		}
+	}
+	return metadata, s.Err()
+}
+
+func readJPEGMetadata(data []byte) (map[string]string, error) {
+	comment := &bytes.Buffer{}
+	for offset := 2; offset+4 <= len(data) && data[offset] == 0xFF; {
+		marker := data[offset+1]
+		if marker == 0xDA { // start of scan: no more metadata
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if length < 2 {
+			// the length includes its own 2 bytes
+			return nil, fmt.Errorf("invalid JPEG segment length: %v", length)
+		}
+		if offset+2+length > len(data) {
+			return nil, fmt.Errorf("truncated JPEG segment")
+		}
+		if marker == 0xFE {
+			comment.Write(data[offset+4 : offset+2+length])
+		}
+		offset += 2 + length
+	}
+	return unmarshalMetadata(comment.Bytes())
+}
+
+func readTIFFMetadata(data []byte) (map[string]string, error) {
+	le := binary.LittleEndian
+	if len(data) < 8 {
+		return nil, fmt.Errorf("truncated TIFF")
+	}
+	ifd := int64(le.Uint32(data[4:]))
+	if ifd > int64(len(data))-2 {
+		return nil, fmt.Errorf("truncated TIFF")
+	}
+	for i := 0; i < int(le.Uint16(data[ifd:])); i++ {
+		e := int(ifd) + 2 + 12*i
+		if e+12 > len(data) {
+			return nil, fmt.Errorf("truncated TIFF")
+		}
+		if le.Uint16(data[e:]) != tiffImageDescription {
+			continue
+		}
+		count := int64(le.Uint32(data[e+4:]))
+		offset := int64(e + 8)
+		if count > 4 {
+			offset = int64(le.Uint32(data[e+8:]))
+		}
+		if offset > int64(len(data)) || count > int64(len(data))-offset {
+			return nil, fmt.Errorf("truncated TIFF")
+		}
+		return unmarshalMetadata(bytes.TrimRight(data[offset:offset+count], "\x00"))
+	}
+	return map[string]string{}, nil
+}
+
+func readZipMetadata(data []byte) (map[string]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	f, err := zr.Open(MetadataFile)
+	if err != nil {
+		return map[string]string{}, nil
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalMetadata(content)
+}
+
+// unmarshalMetadata decodes metadata saved as a JSON object (no metadata when empty)
+func unmarshalMetadata(data []byte) (map[string]string, error) {
+	metadata := map[string]string{}
+	if len(data) == 0 {
+		return metadata, nil
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,144 @@
+package imageio
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadMetadata(t *testing.T) {
+	metadata := map[string]string{
+		"Software": "DistributedRaytracer",
+		"Seed":     "42",
+		"Options":  `{"width":3,"height":2,"world":"` + strings.Repeat("x", 2000) + `"}`, // long: compressed
+		"Comment":  "température",                                                        // not ASCII: international text
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(1, 1, color.NRGBA{R: 255, A: 255})
+
+	tests := []struct {
+		format string
+		write  func(buf *bytes.Buffer) error
+	}{
+		{"png", func(buf *bytes.Buffer) error {
+			if err := WritePNG(buf, img, metadata); err != nil {
+				return err
+			}
+			_, err := png.Decode(bytes.NewReader(buf.Bytes()))
+			return err
+		}},
+		{"jpeg", func(buf *bytes.Buffer) error {
+			if err := WriteJPEG(buf, img, DefaultJPEGOptions(), metadata); err != nil {
+				return err
+			}
+			_, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+			return err
+		}},
+		{"tiff", func(buf *bytes.Buffer) error {
+			return WriteTIFF(buf, image.NewNRGBA64(img.Bounds()), metadata)
+		}},
+		{"exr", func(buf *bytes.Buffer) error {
+			img := testImage(3, 2)
+			img.Attributes = metadata
+			return WriteEXR(buf, img, DefaultEXROptions())
+		}},
+		{"hdr", func(buf *bytes.Buffer) error {
+			img := testImage(3, 2)
+			img.Attributes = metadata
+			return WriteHDR(buf, img)
+		}},
+		{"zip", func(buf *bytes.Buffer) error {
+			zw := zip.NewWriter(buf)
+			f, err := zw.Create(MetadataFile)
+			if err != nil {
+				return err
+			}
+			if err := json.NewEncoder(f).Encode(metadata); err != nil {
+				return err
+			}
+			return zw.Close()
+		}},
+	}
+
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		if err := test.write(buf); err != nil {
+			t.Fatalf("%v: %v", test.format, err)
+		}
+		read, err := ReadMetadata(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%v: %v", test.format, err)
+		}
+		if !reflect.DeepEqual(read, metadata) {
+			t.Errorf("%v: Expected %v, but got %v", test.format, metadata, read)
+		}
+
+		// truncated files fail (or lose their metadata) without panicking
+		for n := 0; n < buf.Len(); n++ {
+			ReadMetadata(buf.Bytes()[:n])
+		}
+	}
+}
+
+func TestReadMetadataErrors(t *testing.T) {
+	tests := [][]byte{
+		[]byte("GIF89a"),
+		{0xFF, 0xD8, 0xFF, 0xFE, 0x00, 0x05, '{'}, // truncated comment
+		{0xFF, 0xD8, 0xFF, 0xFE, 0x00, 0x03, '{'}, // invalid JSON
+		{0xFF, 0xD8, 0xFF, 0xFE, 0x00, 0x00},      // segment length below its own 2 bytes
+		{0xFF, 0xD8, 0xFF, 0xFE, 0x00, 0x01, 0xFF, 0xFE},
+		{0x76, 0x2F, 0x31, 0x01},                             // EXR magic without version
+		{0x76, 0x2F, 0x31, 0x01, 2, 0, 0, 0, 'a', 0, 's', 0}, // EXR attribute without size
+		{0x76, 0x2F, 0x31, 0x01, 2, 0, 0, 0, 'a', 0, 's', 0, 0xFF, 0xFF, 0xFF, 0x7F},
+		[]byte("II*\x00\xFF\xFF\xFF\xFF"),                 // IFD beyond the end
+		[]byte("II*\x00\x08\x00\x00\x00\x01\x00\x0E\x01"), // truncated IFD entry
+		// description of 0xFFFFFFFF bytes at offset 0xFFFFFFF0
+		[]byte("II*\x00\x08\x00\x00\x00\x01\x00\x0E\x01\x02\x00\xFF\xFF\xFF\xFF\xF0\xFF\xFF\xFF"),
+		// description of 5 bytes at offset 0xFFFFFFFF
+		[]byte("II*\x00\x08\x00\x00\x00\x01\x00\x0E\x01\x02\x00\x05\x00\x00\x00\xFF\xFF\xFF\xFF"),
+	}
+	for _, data := range tests {
+		if _, err := ReadMetadata(data); err == nil {
+			t.Errorf("Expected an error for %q", data)
+		}
+	}
+}
+
+// pngWithChunk returns a PNG signature followed by a chunk of the type
+func pngWithChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	return append([]byte("\x89PNG\r\n\x1a\n"), chunk...)
+}
+
+func TestReadPNGMetadataErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty zTXt", pngWithChunk("zTXt", []byte("Key\x00"))},
+		{"zTXt without text", pngWithChunk("zTXt", []byte("Key\x00\x00"))},
+		{"empty iTXt", pngWithChunk("iTXt", []byte("Key\x00"))},
+		{"iTXt without method", pngWithChunk("iTXt", []byte("Key\x00\x00"))},
+		{"iTXt without language", pngWithChunk("iTXt", []byte("Key\x00\x00\x00"))},
+		{"iTXt without translated keyword", pngWithChunk("iTXt", []byte("Key\x00\x00\x00en\x00"))},
+		{"compressed iTXt without text", pngWithChunk("iTXt", []byte("Key\x00\x01\x00\x00\x00"))},
+		{"truncated chunk", pngWithChunk("tEXt", []byte("Key\x00value"))[:20]},
+	}
+	for _, tc := range tests {
+		if _, err := ReadMetadata(tc.data); err == nil {
+			t.Errorf("Expected an error for %v", tc.name)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package imageio
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+)
+
+// WritePNG encodes the image as a PNG with the metadata as text chunks (tEXt for short ASCII values, compressed
+// iTXt otherwise)
+func WritePNG(w io.Writer, img image.Image, metadata map[string]string) error {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	// the text chunks follow the header chunk (signature, length, type, 13 bytes of data and crc)
+	const headerEnd = 8 + 8 + 13 + 4
+	if _, err := w.Write(data[:headerEnd]); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(metadata) {
+		chunk, err := pngTextChunk(key, metadata[key])
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(data[headerEnd:])
+	return err
+}
+
+// pngTextChunk creates the text chunk of a keyword (1 to 79 characters)
+func pngTextChunk(key, value string) ([]byte, error) {
+	if len(key) < 1 || len(key) > 79 {
+		return nil, fmt.Errorf("invalid PNG keyword: %q", key)
+	}
+
+	typ, data := "tEXt", &bytes.Buffer{}
+	data.WriteString(key)
+	data.WriteByte(0)
+	if len(value) < 1024 && ascii(value) {
+		data.WriteString(value)
+	} else {
+		// compressed UTF-8 text, no language
+		typ = "iTXt"
+		data.Write([]byte{1, 0, 0, 0})
+		zw := zlib.NewWriter(data)
+		if _, err := zw.Write([]byte(value)); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(data.Len()))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data.Bytes()...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:])), nil
+}
+
+// ascii tells whether the text is printable ASCII (the content of tEXt chunks)
+func ascii(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 || (s[i] < 0x20 && s[i] != '\n') {
+			return false
+		}
+	}
+	return true
+}
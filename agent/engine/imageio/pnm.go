@@ -0,0 +1,52 @@
+package imageio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+// WritePPM encodes an 8 bits image as a binary portable pixmap (P6, alpha is dropped)
+func WritePPM(w io.Writer, img *image.NRGBA) error {
+	b := img.Bounds()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P6\n%v %v\n255\n", b.Dx(), b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			bw.Write(img.Pix[i : i+3])
+		}
+	}
+	return bw.Flush()
+}
+
+// WritePFM encodes the R, G and B channels of the image as a portable float map (little endian 32 bits floats,
+// the first line being the bottom of the image)
+func WritePFM(w io.Writer, img *Image) error {
+	if err := img.validate(); err != nil {
+		return err
+	}
+	r, g, b := img.Channel("R"), img.Channel("G"), img.Channel("B")
+	if r == nil || g == nil || b == nil {
+		return fmt.Errorf("PFM requires R, G and B channels")
+	}
+
+	bw := bufio.NewWriter(w)
+	// a negative scale means little endian
+	fmt.Fprintf(bw, "PF\n%v %v\n-1.0\n", img.Width, img.Height)
+	line := make([]byte, 0, 12*img.Width)
+	for y := img.Height - 1; y >= 0; y-- {
+		line = line[:0]
+		for x := 0; x < img.Width; x++ {
+			k := y*img.Width + x
+			line = binary.LittleEndian.AppendUint32(line, math.Float32bits(r[k]))
+			line = binary.LittleEndian.AppendUint32(line, math.Float32bits(g[k]))
+			line = binary.LittleEndian.AppendUint32(line, math.Float32bits(b[k]))
+		}
+		bw.Write(line)
+	}
+	return bw.Flush()
+}
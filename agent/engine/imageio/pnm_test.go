@@ -0,0 +1,52 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestWritePPM(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{R: 1, G: 2, B: 3, A: 255})
+	img.Set(1, 0, color.NRGBA{R: 4, G: 5, B: 6, A: 128})
+
+	buf := &bytes.Buffer{}
+	if err := WritePPM(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	expected := append([]byte("P6\n2 1\n255\n"), 1, 2, 3, 4, 5, 6)
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("Expected %v, but got %v", expected, buf.Bytes())
+	}
+}
+
+func TestWritePFM(t *testing.T) {
+	img := NewImage(1, 2, "R", "G", "B")
+	img.Channel("R")[0], img.Channel("G")[0], img.Channel("B")[0] = 1, 2, 3 // top
+	img.Channel("R")[1], img.Channel("G")[1], img.Channel("B")[1] = 4, 5, 6 // bottom
+
+	buf := &bytes.Buffer{}
+	if err := WritePFM(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	header := "PF\n1 2\n-1.0\n"
+	if !bytes.HasPrefix(buf.Bytes(), []byte(header)) {
+		t.Fatalf("Expected header %q, but got %q", header, buf.Bytes()[:len(header)])
+	}
+
+	data := buf.Bytes()[len(header):]
+	// the bottom line comes first
+	for i, expected := range []float32{4, 5, 6, 1, 2, 3} {
+		if v := math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:])); v != expected {
+			t.Errorf("Expected %v, but got %v", expected, v)
+		}
+	}
+
+	if err := WritePFM(buf, NewImage(1, 1, "Z")); err == nil {
+		t.Errorf("Expected an error without R, G and B channels")
+	}
+}
@@ -0,0 +1,152 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"io"
+)
+
+// TIFF tags (in the ascending order required in a directory)
+const (
+	tiffImageWidth                = 256
+	tiffImageLength               = 257
+	tiffBitsPerSample             = 258
+	tiffCompression               = 259
+	tiffPhotometricInterpretation = 262
+	tiffImageDescription          = 270
+	tiffStripOffsets              = 273
+	tiffSamplesPerPixel           = 277
+	tiffRowsPerStrip              = 278
+	tiffStripByteCounts           = 279
+	tiffXResolution               = 282
+	tiffYResolution               = 283
+	tiffPlanarConfiguration       = 284
+	tiffResolutionUnit            = 296
+	tiffSoftware                  = 305
+	tiffExtraSamples              = 338
+)
+
+// TIFF field types
+const (
+	tiffASCII    = 2
+	tiffShort    = 3
+	tiffLong     = 4
+	tiffRational = 5
+)
+
+// tiffEntry is an entry of a TIFF directory: its value, or its data when it has more than 4 bytes (stored after the
+// directory)
+type tiffEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    uint32
+	data     []byte
+}
+
+// tiffASCIIEntry creates the entry of a null terminated string
+func tiffASCIIEntry(tag uint16, s string) tiffEntry {
+	return tiffEntry{tag: tag, typ: tiffASCII, count: uint32(len(s) + 1), data: append([]byte(s), 0)}
+}
+
+// WriteTIFF encodes a 16 bits image as an uncompressed baseline TIFF (RGB with unassociated alpha, a single
+// strip)
+//
+//	the metadata is saved as JSON in the image description, metadata["Software"] in the software tag
+func WriteTIFF(w io.Writer, img *image.NRGBA64, metadata map[string]string) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	le := binary.LittleEndian
+
+	bits, resolution := &bytes.Buffer{}, &bytes.Buffer{}
+	binary.Write(bits, le, [4]uint16{16, 16, 16, 16})
+	binary.Write(resolution, le, [2]uint32{72, 1})
+
+	entries := []tiffEntry{
+		{tag: tiffImageWidth, typ: tiffLong, count: 1, value: uint32(width)},
+		{tag: tiffImageLength, typ: tiffLong, count: 1, value: uint32(height)},
+		{tag: tiffBitsPerSample, typ: tiffShort, count: 4, data: bits.Bytes()},
+		{tag: tiffCompression, typ: tiffShort, count: 1, value: 1},
+		{tag: tiffPhotometricInterpretation, typ: tiffShort, count: 1, value: 2}, // RGB
+	}
+	if len(metadata) > 0 {
+		description, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, tiffASCIIEntry(tiffImageDescription, string(description)))
+	}
+	stripOffsets := len(entries)
+	entries = append(entries,
+		tiffEntry{tag: tiffStripOffsets, typ: tiffLong, count: 1}, // set once the size of the directory is known
+		tiffEntry{tag: tiffSamplesPerPixel, typ: tiffShort, count: 1, value: 4},
+		tiffEntry{tag: tiffRowsPerStrip, typ: tiffLong, count: 1, value: uint32(height)},
+		tiffEntry{tag: tiffStripByteCounts, typ: tiffLong, count: 1, value: uint32(width * height * 8)},
+		tiffEntry{tag: tiffXResolution, typ: tiffRational, count: 1, data: resolution.Bytes()},
+		tiffEntry{tag: tiffYResolution, typ: tiffRational, count: 1, data: resolution.Bytes()},
+		tiffEntry{tag: tiffPlanarConfiguration, typ: tiffShort, count: 1, value: 1}, // interleaved
+		tiffEntry{tag: tiffResolutionUnit, typ: tiffShort, count: 1, value: 2},      // inch
+	)
+	if software, ok := metadata["Software"]; ok {
+		entries = append(entries, tiffASCIIEntry(tiffSoftware, software))
+	}
+	entries = append(entries, tiffEntry{tag: tiffExtraSamples, typ: tiffShort, count: 1, value: 2}) // unassociated alpha
+
+	// the data of the entries follows the directory (word aligned), then the pixels
+	extra := &bytes.Buffer{}
+	offset := uint32(8 + 2 + 12*len(entries) + 4)
+	for i := range entries {
+		if entries[i].data == nil {
+			continue
+		}
+		if len(entries[i].data) <= 4 {
+			var value [4]byte
+			copy(value[:], entries[i].data)
+			entries[i].value = le.Uint32(value[:])
+			continue
+		}
+		entries[i].value = offset + uint32(extra.Len())
+		extra.Write(entries[i].data)
+		if extra.Len()%2 == 1 {
+			extra.WriteByte(0)
+		}
+	}
+	entries[stripOffsets].value = offset + uint32(extra.Len())
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("II")
+	binary.Write(buf, le, uint16(42))
+	binary.Write(buf, le, uint32(8)) // offset of the directory
+	binary.Write(buf, le, uint16(len(entries)))
+	for _, e := range entries {
+		binary.Write(buf, le, e.tag)
+		binary.Write(buf, le, e.typ)
+		binary.Write(buf, le, e.count)
+		if e.typ == tiffShort && e.count == 1 {
+			binary.Write(buf, le, [2]uint16{uint16(e.value), 0})
+		} else {
+			binary.Write(buf, le, e.value)
+		}
+	}
+	binary.Write(buf, le, uint32(0)) // no other directory
+	buf.Write(extra.Bytes())
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	// samples are big endian in image.NRGBA64
+	line := make([]byte, 0, 8*width)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		line = line[:0]
+		row := img.Pix[img.PixOffset(b.Min.X, y) : img.PixOffset(b.Max.X-1, y)+8]
+		for i := 0; i < len(row); i += 2 {
+			line = le.AppendUint16(line, binary.BigEndian.Uint16(row[i:]))
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
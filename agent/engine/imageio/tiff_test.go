@@ -0,0 +1,88 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestWriteTIFF(t *testing.T) {
+	img := image.NewNRGBA64(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.NRGBA64{R: 0x1234, G: 0x5678, B: 0x9ABC, A: 0xFFFF})
+	img.Set(2, 1, color.NRGBA64{R: 0xFFFF, G: 1, B: 2, A: 0x8000})
+
+	buf := &bytes.Buffer{}
+	if err := WriteTIFF(buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	le := binary.LittleEndian
+
+	if string(data[:2]) != "II" || le.Uint16(data[2:]) != 42 {
+		t.Fatalf("Expected a little endian TIFF header, but got %v", data[:4])
+	}
+
+	// read the directory
+	ifd := le.Uint32(data[4:])
+	count := int(le.Uint16(data[ifd:]))
+	tags := map[uint16]uint32{}
+	previous := uint16(0)
+	for i := 0; i < count; i++ {
+		e := data[int(ifd)+2+12*i:]
+		tag, typ, n := le.Uint16(e), le.Uint16(e[2:]), le.Uint32(e[4:])
+		if tag <= previous {
+			t.Errorf("Expected tags in ascending order, but got %v after %v", tag, previous)
+		}
+		previous = tag
+		if typ == tiffShort && n == 1 {
+			tags[tag] = uint32(le.Uint16(e[8:]))
+		} else {
+			tags[tag] = le.Uint32(e[8:])
+		}
+	}
+
+	cases := []struct {
+		tag      uint16
+		expected uint32
+	}{
+		{tiffImageWidth, 3},
+		{tiffImageLength, 2},
+		{tiffSamplesPerPixel, 4},
+		{tiffStripByteCounts, 3 * 2 * 8},
+		{tiffExtraSamples, 2},
+	}
+	for _, tc := range cases {
+		if tags[tc.tag] != tc.expected {
+			t.Errorf("Expected %v for tag %v, but got %v", tc.expected, tc.tag, tags[tc.tag])
+		}
+	}
+
+	bits := data[tags[tiffBitsPerSample]:]
+	for c := 0; c < 4; c++ {
+		if b := le.Uint16(bits[2*c:]); b != 16 {
+			t.Errorf("Expected 16 bits per sample, but got %v", b)
+		}
+	}
+
+	pixels := data[tags[tiffStripOffsets]:]
+	if len(pixels) != 3*2*8 {
+		t.Fatalf("Expected %v bytes of pixels, but got %v", 3*2*8, len(pixels))
+	}
+	sample := func(x, y, c int) uint16 { return le.Uint16(pixels[8*(y*3+x)+2*c:]) }
+	for _, tc := range []struct {
+		x, y, c  int
+		expected uint16
+	}{
+		{0, 0, 0, 0x1234},
+		{0, 0, 2, 0x9ABC},
+		{2, 1, 0, 0xFFFF},
+		{2, 1, 3, 0x8000},
+		{1, 0, 3, 0},
+	} {
+		if v := sample(tc.x, tc.y, tc.c); v != tc.expected {
+			t.Errorf("Expected %#x at %v,%v, but got %#x", tc.expected, tc.x, tc.y, v)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package imageio
+
+import (
+	"image"
+	"math"
+)
+
+// VideoWriter encodes a sequence of frames as they are rendered (frames must all have the size of the video)
+type VideoWriter interface {
+	// WriteFrame appends the frame to the video
+	WriteFrame(img *image.NRGBA) error
+	// Close completes the video (it does not close the underlying writer)
+	Close() error
+}
+
+// frameRate converts frames per second to a rational number (numerator, denominator) with a precision of 1/1000
+func frameRate(fps float64) (int, int) {
+	num, den := int(math.Round(fps*1000)), 1000
+	a, b := num, den
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a == 0 {
+		return 0, 1
+	}
+	return num / a, den / a
+}
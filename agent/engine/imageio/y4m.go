@@ -0,0 +1,77 @@
+package imageio
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+)
+
+// y4mWriter writes uncompressed YUV4MPEG2 streams (4:2:0 chroma subsampling, full range BT.601)
+type y4mWriter struct {
+	w             *bufio.Writer
+	width, height int
+	fps           float64
+	started       bool
+}
+
+// NewY4MWriter creates a writer of YUV4MPEG2 (.y4m) streams: every frame is written as soon as it is given
+func NewY4MWriter(w io.Writer, width, height int, fps float64) VideoWriter {
+	return &y4mWriter{w: bufio.NewWriter(w), width: width, height: height, fps: fps}
+}
+
+func (y *y4mWriter) WriteFrame(img *image.NRGBA) error {
+	b := img.Bounds()
+	if b.Dx() != y.width || b.Dy() != y.height {
+		return fmt.Errorf("frame is %vx%v, expected %vx%v", b.Dx(), b.Dy(), y.width, y.height)
+	}
+	y.header()
+
+	// chroma planes hold one value per 2x2 block (the last line and column being alone for odd sizes)
+	cw, ch := (y.width+1)/2, (y.height+1)/2
+	luma := make([]byte, y.width*y.height)
+	cb, cr := make([]float64, cw*ch), make([]float64, cw*ch)
+	counts := make([]float64, cw*ch)
+	for j := 0; j < y.height; j++ {
+		for i := 0; i < y.width; i++ {
+			o := img.PixOffset(b.Min.X+i, b.Min.Y+j)
+			r, g, bl := float64(img.Pix[o]), float64(img.Pix[o+1]), float64(img.Pix[o+2])
+			luma[j*y.width+i] = toByte(0.299*r + 0.587*g + 0.114*bl)
+			k := (j/2)*cw + i/2
+			cb[k] += 128 - 0.168736*r - 0.331264*g + 0.5*bl
+			cr[k] += 128 + 0.5*r - 0.418688*g - 0.081312*bl
+			counts[k]++
+		}
+	}
+
+	y.w.WriteString("FRAME\n")
+	y.w.Write(luma)
+	for _, plane := range [][]float64{cb, cr} {
+		chroma := make([]byte, len(plane))
+		for k, v := range plane {
+			chroma[k] = toByte(v / counts[k])
+		}
+		y.w.Write(chroma)
+	}
+	// flush so that the frame can be streamed right away
+	return y.w.Flush()
+}
+
+func (y *y4mWriter) Close() error {
+	y.header()
+	return y.w.Flush()
+}
+
+// header writes the stream header (once)
+func (y *y4mWriter) header() {
+	if y.started {
+		return
+	}
+	num, den := frameRate(y.fps)
+	fmt.Fprintf(y.w, "YUV4MPEG2 W%v H%v F%v:%v Ip A1:1 C420jpeg XCOLORRANGE=FULL\n", y.width, y.height, num, den)
+	y.started = true
+}
+
+func toByte(v float64) byte {
+	return byte(min(max(v+0.5, 0), 255))
+}
@@ -0,0 +1,70 @@
+package imageio
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestY4MWriter(t *testing.T) {
+	images := frames(color.NRGBA{R: 255, G: 255, B: 255, A: 255}, color.NRGBA{R: 255, A: 255})
+
+	buf := &bytes.Buffer{}
+	v := NewY4MWriter(buf, 16, 8, 29.97)
+	for _, img := range images {
+		if err := v.WriteFrame(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := v.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	header, data, _ := bytes.Cut(buf.Bytes(), []byte("\n"))
+	expected := "YUV4MPEG2 W16 H8 F2997:100 Ip A1:1 C420jpeg XCOLORRANGE=FULL"
+	if string(header) != expected {
+		t.Errorf("Expected %q, but got %q", expected, header)
+	}
+
+	frameSize := len("FRAME\n") + 16*8 + 2*8*4
+	if len(data) != 2*frameSize {
+		t.Fatalf("Expected 2 frames of %v bytes, but got %v bytes", frameSize, len(data))
+	}
+
+	cases := []struct {
+		frame     int
+		y, cb, cr byte
+	}{
+		{0, 255, 128, 128}, // white
+		{1, 76, 85, 255},   // red
+	}
+	for _, tc := range cases {
+		frame := data[tc.frame*frameSize+len("FRAME\n"):]
+		y, cb, cr := frame[0], frame[16*8], frame[16*8+8*4]
+		if y != tc.y || cb != tc.cb || cr != tc.cr {
+			t.Errorf("Expected YCbCr %v %v %v, but got %v %v %v (frame %v)", tc.y, tc.cb, tc.cr, y, cb, cr, tc.frame)
+		}
+	}
+
+	if err := v.WriteFrame(frames(color.NRGBA{})[0].SubImage(image.Rect(0, 0, 4, 4)).(*image.NRGBA)); err == nil {
+		t.Errorf("Expected an error for a frame of another size")
+	}
+}
+
+func TestFrameRate(t *testing.T) {
+	cases := []struct {
+		fps      float64
+		num, den int
+	}{
+		{25, 25, 1},
+		{12.5, 25, 2},
+		{29.97, 2997, 100},
+	}
+
+	for _, tc := range cases {
+		if num, den := frameRate(tc.fps); num != tc.num || den != tc.den {
+			t.Errorf("Expected %v:%v, but got %v:%v", tc.num, tc.den, num, den)
+		}
+	}
+}
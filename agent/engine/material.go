@@ -14,7 +14,21 @@ type Material interface {
 	scatter(r *geometry.Ray, rec *HitRecord) (wasScattered bool, attenuation *clr.Color, scattered *geometry.Ray)
 }
 
+// emitter is implemented by materials emitting light
+type emitter interface {
+	emitted(rec *HitRecord) clr.Color
+}
+
+// UnmarshalMaterial unmarshals JSON data into a Material (including its optional normal or bump map)
 func UnmarshalMaterial(data json.RawMessage) (Material, error) {
+	mat, err := unmarshalBaseMaterial(data)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalDetail(mat, data)
+}
+
+func unmarshalBaseMaterial(data json.RawMessage) (Material, error) {
 	var m struct {
 		Type string `json:"type"`
 	}
@@ -48,13 +62,58 @@ func UnmarshalMaterial(data json.RawMessage) (Material, error) {
 
 	case "Dielectric":
 		var d struct {
-			RefIdx float64 `json:"refIdx"`
+			RefIdx     float64    `json:"refIdx"`
+			Cauchy     *Cauchy    `json:"cauchy"`
+			Sellmeier  *Sellmeier `json:"sellmeier"`
+			Absorption clr.Color  `json:"absorption"`
+			Density    float64    `json:"density"`
+			Priority   int        `json:"priority"`
 		}
+		d.Density = 1.0
 		err := json.Unmarshal(data, &d)
 		if err != nil {
 			return nil, err
 		}
-		return Dielectric{refIdx: d.RefIdx}, nil
+		if d.Density < 0 || d.Absorption.R < 0 || d.Absorption.G < 0 || d.Absorption.B < 0 {
+			return nil, fmt.Errorf("Dielectric absorption and density must be positive")
+		}
+		die := Dielectric{refIdx: d.RefIdx, absorption: d.Absorption, density: d.Density, priority: d.Priority}
+		switch {
+		case d.Cauchy != nil && d.Sellmeier != nil:
+			return nil, fmt.Errorf("Dielectric cannot have both cauchy and sellmeier coefficients")
+		case d.Cauchy != nil:
+			die.dispersion = *d.Cauchy
+		case d.Sellmeier != nil:
+			die.dispersion = *d.Sellmeier
+		}
+		if die.refIdx == 0 && die.dispersion != nil {
+			die.refIdx = die.dispersion.ior(referenceWavelength)
+		}
+		return die, nil
+
+	case "Isotropic":
+		var i struct {
+			Albedo clr.Color `json:"albedo"`
+		}
+		err := json.Unmarshal(data, &i)
+		if err != nil {
+			return nil, err
+		}
+		return Isotropic{albedo: i.Albedo}, nil
+
+	case "HenyeyGreenstein":
+		var hg struct {
+			Albedo clr.Color `json:"albedo"`
+			G      float64   `json:"g"`
+		}
+		err := json.Unmarshal(data, &hg)
+		if err != nil {
+			return nil, err
+		}
+		if hg.G <= -1 || hg.G >= 1 {
+			return nil, fmt.Errorf("HenyeyGreenstein g must be in (-1,1): %v", hg.G)
+		}
+		return HenyeyGreenstein{albedo: hg.Albedo, g: hg.G}, nil
 
 	default:
 		return nil, fmt.Errorf("unknown material type: %s", m.Type)
@@ -80,7 +139,7 @@ func (mat Lambertian) scatter(r *geometry.Ray, rec *HitRecord) (bool, *clr.Color
 	if dir.NearZero() {
 		dir = rec.Normal
 	}
-	scattered := &geometry.Ray{Origin: rec.P, Direction: dir, Rnd: r.Rnd}
+	scattered := &geometry.Ray{Origin: rec.P, Direction: keepAbove(dir, rec), Rnd: r.Rnd}
 	attenuation := &mat.albedo
 	return true, attenuation, scattered
 }
@@ -109,24 +168,110 @@ func (mat Metal) scatter(r *geometry.Ray, rec *HitRecord) (bool, *clr.Color, *ge
 	attenuation := &mat.albedo
 
 	if geometry.Dot(scattered.Direction, rec.Normal) > 0 {
+		scattered.Direction = keepAbove(scattered.Direction, rec)
 		return true, attenuation, scattered
 	}
 
 	return false, nil, nil
 }
 
+// keepAbove returns the direction of a ray scattered off the surface of the record: with a perturbed shading
+// normal the ray may go below the actual surface, it is mirrored back above
+func keepAbove(dir geometry.Vec3, rec *HitRecord) geometry.Vec3 {
+	if geometry.Dot(dir, rec.GeometricNormal) < 0 {
+		return dir.Reflect(rec.GeometricNormal)
+	}
+	return dir
+}
+
+// Dielectric defines a transparent material (glass, water...)
+//
+//	refIdx is the index of refraction used when rendering in RGB; when a dispersion is defined, the index of
+//	refraction depends on the wavelength in spectral mode. Light travelling inside is absorbed according to the
+//	absorption coefficients (per world unit) scaled by density. When dielectrics overlap (an ice cube in a
+//	glass of water), the one with the highest priority defines the medium inside the overlap.
 type Dielectric struct {
-	refIdx float64
+	refIdx     float64
+	dispersion dispersion
+	absorption clr.Color
+	density    float64
+	priority   int
 }
 
 func (die Dielectric) MarshalJSON() ([]byte, error) {
-	return json.Marshal(struct {
-		Type   string  `json:"type"`
-		RefIdx float64 `json:"refIdx"`
+	d := struct {
+		Type       string     `json:"type"`
+		RefIdx     float64    `json:"refIdx"`
+		Cauchy     *Cauchy    `json:"cauchy,omitempty"`
+		Sellmeier  *Sellmeier `json:"sellmeier,omitempty"`
+		Absorption *clr.Color `json:"absorption,omitempty"`
+		Density    float64    `json:"density,omitempty"`
+		Priority   int        `json:"priority,omitempty"`
 	}{
-		Type:   "Dielectric",
-		RefIdx: die.refIdx,
-	})
+		Type:     "Dielectric",
+		RefIdx:   die.refIdx,
+		Priority: die.priority,
+	}
+	if die.absorbs() {
+		d.Absorption = &die.absorption
+		d.Density = die.density
+	}
+	switch dispersion := die.dispersion.(type) {
+	case Cauchy:
+		d.Cauchy = &dispersion
+	case Sellmeier:
+		d.Sellmeier = &dispersion
+	}
+	return json.Marshal(d)
+}
+
+// ior returns the index of refraction at lambda (nm, 0 meaning RGB rendering)
+func (die Dielectric) ior(lambda float64) float64 {
+	if lambda == 0 || die.dispersion == nil {
+		return die.refIdx
+	}
+	return die.dispersion.ior(lambda)
+}
+
+// dispersive returns whether the index of refraction depends on the wavelength
+func (die Dielectric) dispersive() bool {
+	return die.dispersion != nil
+}
+
+// referenceWavelength is the wavelength (nm) of the Fraunhofer d line used to define the index of refraction
+// when rendering in RGB
+const referenceWavelength = 587.6
+
+// dispersion defines how the index of refraction varies with the wavelength
+type dispersion interface {
+	ior(lambda float64) float64
+}
+
+// Cauchy defines the dispersion with Cauchy's equation n = A + B / lambda^2 (lambda in micrometers)
+type Cauchy struct {
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+}
+
+func (c Cauchy) ior(lambda float64) float64 {
+	um := lambda / 1000.0
+	return c.A + c.B/(um*um)
+}
+
+// Sellmeier defines the dispersion with the Sellmeier equation n^2 = 1 + sum(B_i lambda^2 / (lambda^2 - C_i))
+// (lambda in micrometers, C_i in micrometers squared)
+type Sellmeier struct {
+	B [3]float64 `json:"b"`
+	C [3]float64 `json:"c"`
+}
+
+func (s Sellmeier) ior(lambda float64) float64 {
+	um2 := (lambda / 1000.0) * (lambda / 1000.0)
+	n2 := 1.0
+	for i := 0; i < 3; i++ {
+		n2 += s.B[i] * um2 / (um2 - s.C[i])
+	}
+	return math.Sqrt(n2)
 }
 
 func schlick(cosine float64, iRefIdx float64) float64 {
@@ -136,34 +281,59 @@ func schlick(cosine float64, iRefIdx float64) float64 {
 }
 
 func (die Dielectric) scatter(r *geometry.Ray, rec *HitRecord) (bool, *clr.Color, *geometry.Ray) {
-	var (
-		outwardNormal geometry.Vec3
-		niOverNt      float64
-		cosine        float64
-	)
-
-	dotRayNormal := geometry.Dot(r.Direction, rec.Normal)
-	if dotRayNormal > 0 {
-		outwardNormal = rec.Normal.Negate()
-		niOverNt = die.refIdx
-		cosine = dotRayNormal / r.Direction.Length()
-		cosine = math.Sqrt(1.0 - die.refIdx*die.refIdx*(1.0-cosine*cosine))
-	} else {
-		outwardNormal = rec.Normal
-		niOverNt = 1.0 / die.refIdx
-		cosine = -dotRayNormal / r.Direction.Length()
+	// without any information about the surrounding media, assume air outside
+	n1, n2 := 1.0, die.ior(r.Lambda)
+	if geometry.Dot(r.Direction, rec.GeometricNormal) > 0 {
+		n1, n2 = n2, n1
 	}
 
-	wasRefracted, refracted := r.Direction.Refract(outwardNormal, niOverNt)
+	_, scattered := die.scatterBetween(r, rec, n1, n2)
+	return true, &clr.White, scattered
+}
+
+// scatterBetween scatters the ray at the interface between the medium of index n1 (where the ray comes from) and
+// the medium of index n2
+//
+//	returns whether the ray was refracted (as opposed to reflected) and the scattered ray
+func (die Dielectric) scatterBetween(r *geometry.Ray, rec *HitRecord, n1, n2 float64) (bool, *geometry.Ray) {
+	normal := rec.Normal
+	cosine := -geometry.Dot(r.Direction, normal) / r.Direction.Length()
+	if cosine < 0 {
+		normal = normal.Negate()
+		cosine = -cosine
+	}
 
-	var direction geometry.Vec3
+	niOverNt := n1 / n2
+	wasRefracted, refracted := r.Direction.Refract(normal, niOverNt)
+	if n1 > n2 {
+		// use the angle on the side of the less dense medium
+		cosine = math.Sqrt(1.0 - niOverNt*niOverNt*(1.0-cosine*cosine))
+	}
 
 	// refract only with some probability
-	if wasRefracted && r.Rnd.Float64() >= schlick(cosine, die.refIdx) {
-		direction = *refracted
-	} else {
-		direction = r.Direction.Unit().Reflect(rec.Normal)
+	if wasRefracted && r.Rnd.Float64() >= schlick(cosine, n2/n1) {
+		return true, &geometry.Ray{Origin: rec.P, Direction: *refracted, Rnd: r.Rnd, Lambda: r.Lambda}
 	}
 
-	return true, &clr.White, &geometry.Ray{Origin: rec.P, Direction: direction, Rnd: r.Rnd}
+	direction := r.Direction.Unit().Reflect(rec.Normal)
+	return false, &geometry.Ray{Origin: rec.P, Direction: direction, Rnd: r.Rnd, Lambda: r.Lambda}
+}
+
+// absorbs returns whether light is absorbed when travelling inside the material
+func (die Dielectric) absorbs() bool {
+	return die.density > 0 && (die.absorption.R > 0 || die.absorption.G > 0 || die.absorption.B > 0)
+}
+
+// transmittance returns the fraction of light going through distance (world units) inside the material
+// (Beer-Lambert law)
+func (die Dielectric) transmittance(distance float64) clr.Color {
+	sigma := die.absorption.Scale(die.density)
+	return clr.Color{R: beerLambert(sigma.R, distance), G: beerLambert(sigma.G, distance), B: beerLambert(sigma.B, distance)}
+}
+
+func beerLambert(sigma float64, distance float64) float64 {
+	if sigma == 0 {
+		return 1.0
+	}
+	return math.Exp(-sigma * distance)
 }
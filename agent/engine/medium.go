@@ -0,0 +1,214 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
+)
+
+// ConstantMedium defines a volume of constant density (smoke, fog...) bounded by a closed hittable
+type ConstantMedium struct {
+	boundary Hittable
+	density  float64
+	phase    Material
+}
+
+func NewConstantMedium(boundary Hittable, density float64, phase Material) ConstantMedium {
+	return ConstantMedium{boundary: boundary, density: density, phase: phase}
+}
+
+func (cm ConstantMedium) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string   `json:"type"`
+		Boundary Hittable `json:"boundary"`
+		Density  float64  `json:"density"`
+		Phase    Material `json:"phase"`
+	}{
+		Type:     "ConstantMedium",
+		Boundary: cm.boundary,
+		Density:  cm.density,
+		Phase:    cm.phase,
+	})
+}
+
+// Hit implements the Hit interface for a ConstantMedium: the ray hits the medium at a random distance
+// (free flight) which depends on the density
+func (cm ConstantMedium) Hit(r *geometry.Ray, interval *utils.Interval) (bool, *HitRecord) {
+	// find where the ray enters and exits the boundary (the origin may already be inside)
+	hit1, rec1 := cm.boundary.Hit(r, &utils.Universe)
+	if !hit1 {
+		return false, nil
+	}
+	hit2, rec2 := cm.boundary.Hit(r, &utils.Interval{Min: rec1.T + 0.0001, Max: math.MaxFloat64})
+	if !hit2 {
+		return false, nil
+	}
+
+	t1 := math.Max(rec1.T, interval.Min)
+	t2 := math.Min(rec2.T, interval.Max)
+	if t1 >= t2 {
+		return false, nil
+	}
+	t1 = math.Max(t1, 0)
+
+	rayLength := r.Direction.Length()
+	distanceInsideBoundary := (t2 - t1) * rayLength
+	hitDistance := -math.Log(1-r.Rnd.Float64()) / cm.density
+	if hitDistance > distanceInsideBoundary {
+		return false, nil
+	}
+
+	t := t1 + hitDistance/rayLength
+	normal := geometry.Vec3{X: 1, Y: 0, Z: 0} // arbitrary (unused by phase functions)
+	return true, &HitRecord{
+		T:               t,
+		P:               r.PointAt(t),
+		Normal:          normal,
+		GeometricNormal: normal,
+		Material:        cm.phase,
+	}
+}
+
+// Isotropic defines a phase function scattering light uniformly in all directions
+type Isotropic struct {
+	albedo clr.Color
+}
+
+func (mat Isotropic) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   string    `json:"type"`
+		Albedo clr.Color `json:"albedo"`
+	}{
+		Type:   "Isotropic",
+		Albedo: mat.albedo,
+	})
+}
+
+func (mat Isotropic) scatter(r *geometry.Ray, rec *HitRecord) (bool, *clr.Color, *geometry.Ray) {
+	scattered := &geometry.Ray{Origin: rec.P, Direction: geometry.RandomUnitSphere(r.Rnd), Rnd: r.Rnd}
+	return true, &mat.albedo, scattered
+}
+
+// HenyeyGreenstein defines an anisotropic phase function
+//
+//	g is in (-1,1): g > 0 scatters forward, g < 0 scatters backward, 0 is isotropic
+type HenyeyGreenstein struct {
+	albedo clr.Color
+	g      float64
+}
+
+func (mat HenyeyGreenstein) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   string    `json:"type"`
+		Albedo clr.Color `json:"albedo"`
+		G      float64   `json:"g"`
+	}{
+		Type:   "HenyeyGreenstein",
+		Albedo: mat.albedo,
+		G:      mat.g,
+	})
+}
+
+func (mat HenyeyGreenstein) scatter(r *geometry.Ray, rec *HitRecord) (bool, *clr.Color, *geometry.Ray) {
+	dir := sampleHenyeyGreenstein(r.Rnd, r.Direction.Unit(), mat.g)
+	scattered := &geometry.Ray{Origin: rec.P, Direction: dir, Rnd: r.Rnd}
+	return true, &mat.albedo, scattered
+}
+
+// sampleHenyeyGreenstein samples a new direction according to the Henyey-Greenstein phase function
+// around the direction of propagation dir (unit vector)
+func sampleHenyeyGreenstein(rnd utils.Rnd, dir geometry.Vec3, g float64) geometry.Vec3 {
+	var cosTheta float64
+	if math.Abs(g) < 1e-3 {
+		cosTheta = 1 - 2*rnd.Float64()
+	} else {
+		sqr := (1 - g*g) / (1 - g + 2*g*rnd.Float64())
+		cosTheta = (1 + g*g - sqr*sqr) / (2 * g)
+	}
+	sinTheta := math.Sqrt(math.Max(0, 1-cosTheta*cosTheta))
+	phi := 2 * math.Pi * rnd.Float64()
+
+	// build an orthonormal basis around dir
+	a := geometry.Vec3{X: 1, Y: 0, Z: 0}
+	if math.Abs(dir.X) > 0.9 {
+		a = geometry.Vec3{X: 0, Y: 1, Z: 0}
+	}
+	u := geometry.Cross(dir, a).Unit()
+	v := geometry.Cross(dir, u)
+
+	return u.Scale(sinTheta * math.Cos(phi)).Add(v.Scale(sinTheta * math.Sin(phi))).Add(dir.Scale(cosTheta))
+}
+
+// Fog defines a homogeneous medium filling the whole world (outside of the objects)
+//
+//	absorption and scattering are the coefficients (per world unit) for each channel, g is the anisotropy of the
+//	Henyey-Greenstein phase function. Since the sky is the only source of light, rays which do not hit anything
+//	only travel distance world units in the fog before reaching the sky (0 means the sky is not fogged at all).
+type Fog struct {
+	Absorption clr.Color `json:"absorption"`
+	Scattering clr.Color `json:"scattering"`
+	G          float64   `json:"g"`
+	Distance   float64   `json:"distance"`
+}
+
+// Validate checks the fog coefficients
+func (fog *Fog) Validate() error {
+	for _, c := range []clr.Color{fog.Absorption, fog.Scattering} {
+		if c.R < 0 || c.G < 0 || c.B < 0 {
+			return fmt.Errorf("fog coefficients must be positive")
+		}
+	}
+	if fog.G <= -1 || fog.G >= 1 {
+		return fmt.Errorf("fog g must be in (-1,1)")
+	}
+	if fog.Distance < 0 {
+		return fmt.Errorf("fog distance must be positive")
+	}
+	return nil
+}
+
+// sample samples a free flight distance along the ray which travels tMax (ray parameter) before hitting a surface.
+//
+//	returns whether the ray scattered in the fog (and the new ray) as well as the weight to apply to the light
+//	carried by the path (ratio of the transmittance and the sampling probability)
+func (fog *Fog) sample(r *geometry.Ray, tMax float64) (bool, clr.Color, *geometry.Ray) {
+	extinction := fog.Absorption.Add(fog.Scattering)
+
+	// sample the distance according to the average extinction and correct each channel with its own extinction
+	sigma := (extinction.R + extinction.G + extinction.B) / 3.0
+	if sigma <= 0 {
+		return false, clr.White, nil
+	}
+
+	rayLength := r.Direction.Length()
+	distance := -math.Log(1-r.Rnd.Float64()) / sigma
+
+	if maxDistance := tMax * rayLength; distance >= maxDistance {
+		// the ray goes through: transmittance / probability of not scattering
+		return false, transmittanceRatio(extinction, sigma, maxDistance), nil
+	}
+
+	// the ray scatters: scattering * transmittance / probability density of scattering at that distance
+	weight := fog.Scattering.Mult(transmittanceRatio(extinction, sigma, distance)).Scale(1.0 / sigma)
+
+	dir := r.Direction.Unit()
+	scattered := &geometry.Ray{
+		Origin:    r.PointAt(distance / rayLength),
+		Direction: sampleHenyeyGreenstein(r.Rnd, dir, fog.G),
+		Rnd:       r.Rnd,
+	}
+	return true, weight, scattered
+}
+
+// transmittanceRatio computes exp(-extinction * d) / exp(-sigma * d) for each channel
+func transmittanceRatio(extinction clr.Color, sigma float64, d float64) clr.Color {
+	return clr.Color{
+		R: math.Exp(-(extinction.R - sigma) * d),
+		G: math.Exp(-(extinction.G - sigma) * d),
+		B: math.Exp(-(extinction.B - sigma) * d),
+	}
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
+)
+
+const mediumSamples = 200000
+
+func TestConstantMediumFreeFlight(t *testing.T) {
+	// from the center of a large boundary, the distance to the collision follows exp(-density * d)
+	density := 0.5
+	medium := NewConstantMedium(Sphere{Radius: 1000}, density, Isotropic{})
+	rnd := rand.New(rand.NewSource(2024))
+
+	distances := []float64{0.5, 1, 2, 4}
+	beyond := make([]int, len(distances))
+	for i := 0; i < mediumSamples; i++ {
+		// the length of the direction must not change the distances (measured in world units)
+		r := &geometry.Ray{Direction: geometry.Vec3{X: 2}, Rnd: rnd}
+		hit, hr := medium.Hit(r, &utils.Interval{Min: 0.001, Max: math.MaxFloat64})
+		if !hit {
+			t.Fatalf("Expected a collision inside the boundary")
+		}
+		d := hr.T * r.Direction.Length()
+		for j, distance := range distances {
+			if d > distance {
+				beyond[j]++
+			}
+		}
+	}
+
+	for j, distance := range distances {
+		expected := math.Exp(-density * distance)
+		if p := float64(beyond[j]) / mediumSamples; math.Abs(p-expected) > 0.005 {
+			t.Errorf("Expected P(d > %v) = %v, but got %v", distance, expected, p)
+		}
+	}
+}
+
+func TestConstantMediumInterval(t *testing.T) {
+	// the collisions are bounded by the interval of the ray
+	medium := NewConstantMedium(Sphere{Radius: 1000}, 0.5, Isotropic{})
+	rnd := rand.New(rand.NewSource(2024))
+	for i := 0; i < 1000; i++ {
+		r := &geometry.Ray{Direction: geometry.Vec3{Z: 1}, Rnd: rnd}
+		if hit, hr := medium.Hit(r, &utils.Interval{Min: 0.001, Max: 1}); hit && hr.T > 1 {
+			t.Fatalf("Expected a collision before 1, but got %v", hr.T)
+		}
+	}
+}
+
+func TestHenyeyGreensteinMeanCosine(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2024))
+	dir := geometry.Vec3{X: 1, Y: 2, Z: -2}.Unit()
+
+	for _, g := range []float64{-0.8, -0.3, 0, 0.5, 0.9} {
+		sum := 0.0
+		for i := 0; i < mediumSamples; i++ {
+			wo := sampleHenyeyGreenstein(rnd, dir, g)
+			if math.Abs(wo.Length()-1) > 1e-9 {
+				t.Fatalf("Expected a unit direction, but got %v", wo)
+			}
+			sum += geometry.Dot(wo, dir)
+		}
+		if mean := sum / mediumSamples; math.Abs(mean-g) > 0.005 {
+			t.Errorf("Expected a mean cosine of %v, but got %v", g, mean)
+		}
+	}
+}
+
+func TestFogUnbiased(t *testing.T) {
+	// extinctions differ per channel: the distances are sampled with their average, the weights correct each one
+	fog := &Fog{
+		Absorption: clr.Color{R: 0.1, G: 0.3, B: 0.05},
+		Scattering: clr.Color{R: 0.2, G: 0.1, B: 0.6},
+		G:          0.5,
+	}
+	extinction := fog.Absorption.Add(fog.Scattering)
+	rnd := rand.New(rand.NewSource(2024))
+	distance := 2.0
+
+	var transmitted, scattered clr.Color
+	for i := 0; i < mediumSamples; i++ {
+		// the surface is hit at t = 1 along a ray of length distance
+		r := &geometry.Ray{Direction: geometry.Vec3{Z: -distance}, Rnd: rnd}
+		s, weight, _ := fog.sample(r, 1)
+		if s {
+			scattered = scattered.Add(weight)
+		} else {
+			transmitted = transmitted.Add(weight)
+		}
+	}
+	transmitted = transmitted.Scale(1.0 / mediumSamples)
+	scattered = scattered.Scale(1.0 / mediumSamples)
+
+	channels := func(c clr.Color) []float64 { return []float64{c.R, c.G, c.B} }
+	for i, sigma := range channels(extinction) {
+		// transmittance exp(-sigma d), and light scattered towards the path: integral of scattering * exp(-sigma t)
+		expectedTransmitted := math.Exp(-sigma * distance)
+		expectedScattered := channels(fog.Scattering)[i] / sigma * (1 - math.Exp(-sigma*distance))
+		if v := channels(transmitted)[i]; math.Abs(v-expectedTransmitted) > 0.01 {
+			t.Errorf("Expected a transmittance of %v (channel %v), but got %v", expectedTransmitted, i, v)
+		}
+		if v := channels(scattered)[i]; math.Abs(v-expectedScattered) > 0.01 {
+			t.Errorf("Expected a scattered weight of %v (channel %v), but got %v", expectedScattered, i, v)
+		}
+	}
+}
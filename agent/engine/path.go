@@ -0,0 +1,141 @@
+package engine
+
+import (
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/spectrum"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
+)
+
+// path holds the state of a path traced from the camera
+//
+//	In spectral mode the path carries 3 wavelengths: every color along the path (attenuation, emission, sky...)
+//	is converted to the values of its spectrum at those wavelengths, packed in a color (R for the hero
+//	wavelength). In RGB mode colors are used as is.
+//
+//	The path also keeps track of the dielectrics it is currently inside of (nested dielectrics), the one with the
+//	highest priority being the medium the path is travelling through.
+type path struct {
+	spectral          bool
+	lambdas           spectrum.Wavelengths
+	collapsed         bool       // whether only the hero wavelength is left (after hitting a dispersive material)
+	interiors         []interior // dielectrics the path is inside of, in the order they were entered
+	first             *HitRecord // first hit of the camera ray (nil when it reaches the sky), before shading
+	tracedRays        int        // rays of the path traced through the world
+	intersectionTests int        // tests of these rays against the primitives of the world
+}
+
+// interior identifies a dielectric object the path is inside of
+type interior struct {
+	object     int
+	dielectric Dielectric
+}
+
+// newPath starts a new path from the camera
+func newPath(rnd utils.Rnd, spectral bool) *path {
+	p := &path{spectral: spectral}
+	if spectral {
+		p.lambdas = spectrum.SampleWavelengths(rnd.Float64())
+	}
+	return p
+}
+
+// lambda returns the wavelength of the rays of the path (0 in RGB mode)
+func (p *path) lambda() float64 {
+	if !p.spectral {
+		return 0
+	}
+	return p.lambdas[0]
+}
+
+// convert converts a color to the values carried by the path
+func (p *path) convert(c clr.Color) clr.Color {
+	if !p.spectral {
+		return c
+	}
+	return spectrum.UpsampleAll(c, &p.lambdas)
+}
+
+// interact returns the weight to apply when the path interacts with the material: at the first dispersive
+// material, the secondary wavelengths are terminated (they would need to refract in other directions) and the
+// hero wavelength accounts for all of them
+func (p *path) interact(mat Material) clr.Color {
+	if !p.spectral || p.collapsed || !isDispersive(mat) {
+		return clr.White
+	}
+	p.collapsed = true
+	return clr.Color{R: spectrum.Count, G: 0, B: 0}
+}
+
+// rgb converts the values carried by the path back to an RGB color
+func (p *path) rgb(c clr.Color) clr.Color {
+	if !p.spectral {
+		return c
+	}
+	return spectrum.ToRGB(&p.lambdas, c)
+}
+
+// medium returns the index in interiors of the medium the path is travelling through (the dielectric with the
+// highest priority, the last entered one on ties), -1 when outside of any dielectric
+func (p *path) medium() int {
+	res := -1
+	for i, in := range p.interiors {
+		if res < 0 || in.dielectric.priority >= p.interiors[res].dielectric.priority {
+			res = i
+		}
+	}
+	return res
+}
+
+// mediumExcept returns the index of the medium the path would travel through without the interior at index skip
+func (p *path) mediumExcept(skip int) int {
+	res := -1
+	for i, in := range p.interiors {
+		if i != skip && (res < 0 || in.dielectric.priority >= p.interiors[res].dielectric.priority) {
+			res = i
+		}
+	}
+	return res
+}
+
+// find returns the index in interiors of the object, -1 if the path is not inside of it
+func (p *path) find(object int) int {
+	for i := len(p.interiors) - 1; i >= 0; i-- {
+		if p.interiors[i].object == object {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove removes the interior at index i
+func (p *path) remove(i int) {
+	p.interiors = append(p.interiors[:i], p.interiors[i+1:]...)
+}
+
+// ior returns the index of refraction of the interior at index i (air when i < 0)
+func (p *path) ior(i int) float64 {
+	if i < 0 {
+		return 1.0
+	}
+	return p.interiors[i].dielectric.ior(p.lambda())
+}
+
+// asDielectric returns the dielectric behind the material (if it is one)
+func asDielectric(mat Material) (Dielectric, bool) {
+	if d, ok := mat.(detailedMaterial); ok {
+		mat = d.Material
+	}
+	die, ok := mat.(Dielectric)
+	return die, ok
+}
+
+// isDispersive returns whether the index of refraction of the material depends on the wavelength
+func isDispersive(mat Material) bool {
+	if d, ok := mat.(detailedMaterial); ok {
+		mat = d.Material
+	}
+	if d, ok := mat.(interface{ dispersive() bool }); ok {
+		return d.dispersive()
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"math"
+	"testing"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
+)
+
+// constant is a random generator always returning the same value
+type constant float64
+
+func (c constant) Float64() float64 { return float64(c) }
+
+// recorder is a world recording the rays traced through it and their hits (nil for the misses)
+type recorder struct {
+	HittableList
+	rays []geometry.Ray
+	hits []*HitRecord
+}
+
+func (rec *recorder) Hit(r *geometry.Ray, interval *utils.Interval) (bool, *HitRecord) {
+	rec.rays = append(rec.rays, *r)
+	hit, hr := rec.HittableList.Hit(r, interval)
+	if hit {
+		first := *hr
+		rec.hits = append(rec.hits, &first)
+	} else {
+		rec.hits = append(rec.hits, nil)
+	}
+	return hit, hr
+}
+
+func TestPathMedium(t *testing.T) {
+	glass := interior{object: 0, dielectric: Dielectric{refIdx: 1.5, priority: 2}}
+	water := interior{object: 1, dielectric: Dielectric{refIdx: 1.33, priority: 1}}
+	ice := interior{object: 2, dielectric: Dielectric{refIdx: 1.31, priority: 1}}
+
+	cases := []struct {
+		interiors []interior
+		medium    int
+		except0   int // medium without the first interior
+	}{
+		{nil, -1, -1},
+		{[]interior{glass}, 0, -1},
+		{[]interior{glass, water}, 0, 1},        // the glass has the highest priority
+		{[]interior{water, glass}, 1, 1},        // regardless of the order
+		{[]interior{water, ice}, 1, 1},          // ties: the last entered one
+		{[]interior{water, glass, ice}, 1, 1},   // without the water, still the glass
+		{[]interior{glass, water, ice}, 0, 2},   // without the glass, the last of the ties
+		{[]interior{glass, glass, water}, 1, 1}, // the same object entered twice (overlapping paths)
+		{[]interior{ice, water, glass, water}, 2, 2},
+	}
+
+	for _, tc := range cases {
+		p := &path{interiors: tc.interiors}
+		if m := p.medium(); m != tc.medium {
+			t.Errorf("Expected medium %v, but got %v (%v)", tc.medium, m, tc.interiors)
+		}
+		if len(tc.interiors) > 0 {
+			if m := p.mediumExcept(0); m != tc.except0 {
+				t.Errorf("Expected medium %v without the first interior, but got %v (%v)", tc.except0, m, tc.interiors)
+			}
+		}
+	}
+}
+
+func TestPathFindRemove(t *testing.T) {
+	glass := interior{object: 0, dielectric: Dielectric{refIdx: 1.5, priority: 2}}
+	water := interior{object: 1, dielectric: Dielectric{refIdx: 1.33, priority: 1}}
+	p := &path{interiors: []interior{glass, water, glass}}
+
+	if i := p.find(0); i != 2 {
+		t.Errorf("Expected the last entry 2, but got %v", i)
+	}
+	if i := p.find(3); i != -1 {
+		t.Errorf("Expected -1, but got %v", i)
+	}
+
+	p.remove(p.find(1))
+	p.remove(p.find(0))
+	p.remove(p.find(0))
+	if len(p.interiors) != 0 {
+		t.Errorf("Expected no interior, but got %v", p.interiors)
+	}
+}
+
+// traceNested traces a ray through a glass sphere (radius 1) containing a water sphere (radius 0.5), returning
+// the rays traced and the interiors of the path at the end
+func traceNested(glassPriority, waterPriority int) ([]geometry.Ray, []interior) {
+	world := &recorder{HittableList: HittableList{
+		Sphere{Radius: 1, Material: Dielectric{refIdx: 1.5, priority: glassPriority}},
+		Sphere{Radius: 0.5, Material: Dielectric{refIdx: 1.33, priority: waterPriority}},
+	}}
+	scene := NewScene(1, 1, 1, testCamera(), world)
+
+	// always refract (the random value is above the Fresnel reflectance)
+	r := &geometry.Ray{Origin: geometry.Point3{X: 0.3, Z: 5}, Direction: geometry.Vec3{Z: -1}, Rnd: constant(0.999)}
+	p := newPath(r.Rnd, false)
+	scene.color(r, p, 0)
+	return world.rays, p.interiors
+}
+
+func sameDirection(a, b geometry.Vec3) bool {
+	return a.Unit().Sub(b.Unit()).Length() < 1e-9
+}
+
+func TestNestedDielectrics(t *testing.T) {
+	// the glass has the highest priority: the surfaces of the water inside it are false hits
+	rays, interiors := traceNested(2, 1)
+	if len(rays) != 5 {
+		t.Fatalf("Expected 5 rays (camera, glass, water in, water out, glass out), but got %v", len(rays))
+	}
+	if sameDirection(rays[0].Direction, rays[1].Direction) {
+		t.Errorf("Expected the ray to refract entering the glass, but got %v", rays[1].Direction)
+	}
+	for i := 2; i <= 3; i++ {
+		if !sameDirection(rays[i].Direction, rays[1].Direction) {
+			t.Errorf("Expected the false hit %v to keep the direction %v, but got %v", i, rays[1].Direction, rays[i].Direction)
+		}
+	}
+	if sameDirection(rays[4].Direction, rays[3].Direction) {
+		t.Errorf("Expected the ray to refract leaving the glass, but got %v", rays[4].Direction)
+	}
+	if len(interiors) != 0 {
+		t.Errorf("Expected no interior after leaving all the volumes, but got %v", interiors)
+	}
+
+	// the water has the highest priority: its surfaces are actual interfaces (with the glass)
+	rays, interiors = traceNested(1, 2)
+	if len(rays) != 5 {
+		t.Fatalf("Expected 5 rays, but got %v", len(rays))
+	}
+	for i := 2; i <= 3; i++ {
+		if sameDirection(rays[i].Direction, rays[i-1].Direction) {
+			t.Errorf("Expected the ray %v to refract at the water, but got %v", i, rays[i].Direction)
+		}
+	}
+	if len(interiors) != 0 {
+		t.Errorf("Expected no interior after leaving all the volumes, but got %v", interiors)
+	}
+}
+
+func TestDielectricAbsorption(t *testing.T) {
+	die := Dielectric{refIdx: 1.5, absorption: clr.White, density: 2}
+	if v := die.transmittance(0.5).R; math.Abs(v-math.Exp(-1)) > 1e-12 {
+		t.Errorf("Expected %v, but got %v", math.Exp(-1), v)
+	}
+}
@@ -0,0 +1,65 @@
+package sampler
+
+import "math"
+
+// primes are the bases of the dimensions of the Halton sequence (the dimensions beyond use random values)
+var primes = firstPrimes(128)
+
+// halton uses the Halton sequence (radical inverse of the sample index in a different prime base for each
+// dimension), shifted by a random offset per pixel and dimension (Cranley-Patterson rotation) so that
+// neighbouring pixels are not correlated
+type halton struct {
+	pixelSample
+}
+
+func (s *halton) Get1D() float64 {
+	d := s.dimension
+	s.dimension++
+
+	if d >= len(primes) {
+		return s.random(d)
+	}
+	v := radicalInverse(primes[d], uint64(s.index)) + toFloat(s.hash(d))
+	return math.Min(v-math.Floor(v), oneMinusEpsilon)
+}
+
+func (s *halton) Get2D() (float64, float64) {
+	return s.Get1D(), s.Get1D()
+}
+
+func (s *halton) Float64() float64 {
+	return s.Get1D()
+}
+
+// radicalInverse mirrors the digits of i in base around the decimal point
+func radicalInverse(base int, i uint64) float64 {
+	b := uint64(base)
+	inverse := 1.0 / float64(base)
+	reversed, factor := uint64(0), 1.0
+	for i > 0 {
+		reversed = reversed*b + i%b
+		factor *= inverse
+		i /= b
+	}
+	return math.Min(float64(reversed)*factor, oneMinusEpsilon)
+}
+
+func firstPrimes(n int) []int {
+	primes := make([]int, 0, n)
+	for candidate := 2; len(primes) < n; candidate++ {
+		prime := true
+		for _, p := range primes {
+			if p*p > candidate {
+				break
+			}
+			if candidate%p == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			primes = append(primes, candidate)
+		}
+	}
+	return primes
+}
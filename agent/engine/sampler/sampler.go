@@ -0,0 +1,124 @@
+package sampler
+
+import (
+	"fmt"
+	"math"
+)
+
+// Sampler generates the random values of the samples of a pixel, one dimension after the other (pixel position,
+// lens position, then the scattering events along the path...)
+//
+//	The values only depend on the seed, the pixel, the index of the sample and the dimension: they do not
+//	depend on which goroutine renders the pixel. A Sampler is a utils.Rnd (Float64 being Get1D) and a
+//	utils.Rnd2D so it can be given to any code drawing random values.
+type Sampler interface {
+	// StartPixelSample starts the sample index of the pixel (x,y) at the first dimension
+	StartPixelSample(x, y, index int)
+	// Get1D returns the value of the next dimension in [0,1)
+	Get1D() float64
+	// Get2D returns the values of the next two dimensions in [0,1)
+	Get2D() (float64, float64)
+	// Float64 is the same as Get1D
+	Float64() float64
+}
+
+// Kind is the type of sampler
+type Kind string
+
+const (
+	Independent Kind = "independent" // uniform random values
+	Stratified  Kind = "stratified"  // jittered strata (one per sample of the pixel)
+	Halton      Kind = "halton"      // Halton sequence randomly shifted per pixel
+	Sobol       Kind = "sobol"       // Owen-scrambled Sobol sequence
+)
+
+// Validate checks the kind of sampler (empty meaning independent)
+func (k Kind) Validate() error {
+	switch k {
+	case "", Independent, Stratified, Halton, Sobol:
+		return nil
+	default:
+		return fmt.Errorf("unknown sampler: %s", k)
+	}
+}
+
+// New creates a sampler (samplesPerPixel is the number of strata of the stratified sampler)
+func New(kind Kind, seed int64, samplesPerPixel int) (Sampler, error) {
+	switch kind {
+	case Independent, "":
+		return &independent{pixelSample{seed: uint64(seed)}}, nil
+	case Stratified:
+		return newStratified(uint64(seed), samplesPerPixel), nil
+	case Halton:
+		return &halton{pixelSample{seed: uint64(seed)}}, nil
+	case Sobol:
+		return &sobol{pixelSample{seed: uint64(seed)}}, nil
+	default:
+		return nil, fmt.Errorf("unknown sampler: %s", kind)
+	}
+}
+
+// pixelSample holds the state common to all the samplers
+type pixelSample struct {
+	seed      uint64
+	x, y      int
+	index     int
+	dimension int
+}
+
+func (s *pixelSample) StartPixelSample(x, y, index int) {
+	s.x, s.y, s.index, s.dimension = x, y, index, 0
+}
+
+// hash returns a hash of the pixel and dimension (and extra values)
+func (s *pixelSample) hash(dimension int, values ...uint64) uint64 {
+	h := mix(s.seed ^ 0x9E3779B97F4A7C15)
+	h = mix(h ^ uint64(s.x))
+	h = mix(h ^ uint64(s.y))
+	h = mix(h ^ uint64(dimension))
+	for _, v := range values {
+		h = mix(h ^ v)
+	}
+	return h
+}
+
+// random returns a uniform value for the current sample and the dimension
+func (s *pixelSample) random(dimension int) float64 {
+	return toFloat(s.hash(dimension, uint64(s.index)))
+}
+
+// independent draws uniform random values
+type independent struct {
+	pixelSample
+}
+
+func (s *independent) Get1D() float64 {
+	s.dimension++
+	return s.random(s.dimension - 1)
+}
+
+func (s *independent) Get2D() (float64, float64) {
+	return s.Get1D(), s.Get1D()
+}
+
+func (s *independent) Float64() float64 {
+	return s.Get1D()
+}
+
+// mix is the finalizer of splitmix64: a bijection spreading every bit of v to every bit of the result
+func mix(v uint64) uint64 {
+	v ^= v >> 30
+	v *= 0xBF58476D1CE4E5B9
+	v ^= v >> 27
+	v *= 0x94D049BB133111EB
+	v ^= v >> 31
+	return v
+}
+
+// toFloat converts random bits to a value in [0,1)
+func toFloat(v uint64) float64 {
+	return float64(v>>11) * 0x1p-53
+}
+
+// oneMinusEpsilon is the largest value below 1
+var oneMinusEpsilon = math.Nextafter(1, 0)
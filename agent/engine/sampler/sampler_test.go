@@ -0,0 +1,140 @@
+package sampler
+
+import (
+	"math"
+	"testing"
+)
+
+var kinds = []Kind{Independent, Stratified, Halton, Sobol}
+
+// values returns the first dimensions of the samples of a pixel
+func values(t *testing.T, kind Kind, seed int64, x, y, samples, dimensions int) [][]float64 {
+	s, err := New(kind, seed, samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make([][]float64, samples)
+	for i := range result {
+		s.StartPixelSample(x, y, i)
+		for d := 0; d < dimensions; d += 2 {
+			u, v := s.Get2D()
+			result[i] = append(result[i], u, v, s.Get1D())
+		}
+	}
+	return result
+}
+
+func TestDeterministic(t *testing.T) {
+	for _, kind := range kinds {
+		v1 := values(t, kind, 2024, 3, 7, 16, 6)
+		v2 := values(t, kind, 2024, 3, 7, 16, 6)
+		other := values(t, kind, 2024, 4, 7, 16, 6)
+		seeded := values(t, kind, 2025, 3, 7, 16, 6)
+
+		same, otherPixel, otherSeed := true, false, false
+		for i := range v1 {
+			for d := range v1[i] {
+				if v1[i][d] < 0 || v1[i][d] >= 1 {
+					t.Errorf("Expected a value in [0,1), but got %v (%v)", v1[i][d], kind)
+				}
+				same = same && v1[i][d] == v2[i][d]
+				otherPixel = otherPixel || v1[i][d] != other[i][d]
+				otherSeed = otherSeed || v1[i][d] != seeded[i][d]
+			}
+		}
+		if !same || !otherPixel || !otherSeed {
+			t.Errorf("Expected values depending only on the seed and pixel (%v)", kind)
+		}
+	}
+}
+
+// TestStratification checks that the 2D values of n samples fall in distinct cells of a sqrt(n) x sqrt(n) grid
+// and the 1D values in distinct intervals of [0,1)
+func TestStratification(t *testing.T) {
+	const n = 16
+	for _, kind := range []Kind{Stratified, Sobol} {
+		for pixel := 0; pixel < 8; pixel++ {
+			v := values(t, kind, 1, pixel, 2*pixel, n, 6)
+			for d := 0; d < len(v[0]); d += 3 {
+				cells, intervals := map[int]bool{}, map[int]bool{}
+				for i := 0; i < n; i++ {
+					cells[int(v[i][d]*4)+4*int(v[i][d+1]*4)] = true
+					intervals[int(v[i][d+2]*n)] = true
+				}
+				if len(cells) != n || len(intervals) != n {
+					t.Errorf("Expected %v strata, but got %v cells and %v intervals (%v, dimension %v)", n, len(cells), len(intervals), kind, d)
+				}
+			}
+		}
+	}
+}
+
+// TestConvergence integrates a smooth function over the unit square: low discrepancy samplers must have a
+// lower error than independent samples
+func TestConvergence(t *testing.T) {
+	const n = 64
+	f := func(u, v float64) float64 { return math.Sin(math.Pi*u) * math.Sin(math.Pi*v) }
+	expected := 4 / (math.Pi * math.Pi)
+
+	rmse := map[Kind]float64{}
+	for _, kind := range kinds {
+		sum := 0.0
+		for pixel := 0; pixel < 64; pixel++ {
+			v := values(t, kind, 7, pixel, 0, n, 2)
+			integral := 0.0
+			for i := range v {
+				integral += f(v[i][0], v[i][1])
+			}
+			e := integral/n - expected
+			sum += e * e
+		}
+		rmse[kind] = math.Sqrt(sum / 64)
+	}
+
+	for _, kind := range []Kind{Stratified, Halton, Sobol} {
+		if rmse[kind] > rmse[Independent]/2 {
+			t.Errorf("Expected %v to beat independent samples (%v), but got %v", kind, rmse[Independent], rmse[kind])
+		}
+	}
+}
+
+func TestRadicalInverse(t *testing.T) {
+	cases := []struct {
+		base     int
+		i        uint64
+		expected float64
+	}{
+		{2, 0, 0},
+		{2, 1, 0.5},
+		{2, 3, 0.75},
+		{3, 1, 1.0 / 3},
+		{3, 5, 7.0 / 9},
+	}
+
+	for _, tc := range cases {
+		if v := radicalInverse(tc.base, tc.i); math.Abs(v-tc.expected) > 1e-12 {
+			t.Errorf("Expected %v, but got %v", tc.expected, v)
+		}
+	}
+}
+
+func TestPermute(t *testing.T) {
+	for _, l := range []uint32{1, 5, 16, 100} {
+		seen := map[uint32]bool{}
+		for i := uint32(0); i < l; i++ {
+			seen[permute(i, l, 0xDEADBEEF)] = true
+		}
+		if len(seen) != int(l) {
+			t.Errorf("Expected a permutation of %v elements, but got %v distinct values", l, len(seen))
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("random", 0, 1); err == nil {
+		t.Errorf("Expected an error for an unknown sampler")
+	}
+	if err := Kind("sobol").Validate(); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
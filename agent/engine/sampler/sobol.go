@@ -0,0 +1,69 @@
+package sampler
+
+import (
+	"math"
+	"math/bits"
+)
+
+// sobol uses the first two dimensions of the Sobol sequence for every pair of dimensions (padding), with an
+// Owen scrambling of the values and a shuffling of the sample indices which are different for each pixel and
+// pair of dimensions (Burley 2020, "Practical Hash-based Owen Scrambling"). The first 2^k samples of each pair
+// of dimensions are stratified in every way allowed by a (0,2) sequence.
+type sobol struct {
+	pixelSample
+}
+
+func (s *sobol) Get1D() float64 {
+	d := s.dimension
+	s.dimension++
+
+	index := nestedUniformScramble(uint32(s.index), uint32(s.hash(d)))
+	return toUnit(nestedUniformScramble(bits.Reverse32(index), uint32(s.hash(d, 1))))
+}
+
+func (s *sobol) Get2D() (float64, float64) {
+	d := s.dimension
+	s.dimension += 2
+
+	index := nestedUniformScramble(uint32(s.index), uint32(s.hash(d)))
+	x := nestedUniformScramble(bits.Reverse32(index), uint32(s.hash(d, 1)))
+	y := nestedUniformScramble(sobolSecond(index), uint32(s.hash(d, 2)))
+	return toUnit(x), toUnit(y)
+}
+
+func (s *sobol) Float64() float64 {
+	return s.Get1D()
+}
+
+// sobolSecond returns the second dimension of the Sobol sequence (primitive polynomial x + 1, whose direction
+// numbers are v_k = v_(k-1) ^ (v_(k-1) >> 1))
+func sobolSecond(index uint32) uint32 {
+	result, v := uint32(0), uint32(1)<<31
+	for ; index != 0; index >>= 1 {
+		if index&1 != 0 {
+			result ^= v
+		}
+		v ^= v >> 1
+	}
+	return result
+}
+
+// laineKarrasPermutation is a hash whose bits only depend on the bits of lower significance of x
+func laineKarrasPermutation(x, seed uint32) uint32 {
+	x += seed
+	x ^= x * 0x6C50B47C
+	x ^= x * 0xB82F1E52
+	x ^= x * 0xC7AFE638
+	x ^= x * 0x8D22F6E6
+	return x
+}
+
+// nestedUniformScramble is an Owen scrambling of the bits of x: each bit is flipped depending on the bits of
+// higher significance
+func nestedUniformScramble(x, seed uint32) uint32 {
+	return bits.Reverse32(laineKarrasPermutation(bits.Reverse32(x), seed))
+}
+
+func toUnit(v uint32) float64 {
+	return math.Min(float64(v)*0x1p-32, oneMinusEpsilon)
+}
@@ -0,0 +1,83 @@
+package sampler
+
+import "math"
+
+// stratified splits [0,1) (and [0,1)^2) in as many strata as samples per pixel: each sample of a pixel falls
+// in its own stratum (in a random order for each dimension), at a random position within the stratum. The
+// samples beyond samplesPerPixel (adaptive sampling) use the strata again.
+type stratified struct {
+	pixelSample
+	samplesPerPixel int
+	nx, ny          int // strata of the 2D dimensions
+}
+
+func newStratified(seed uint64, samplesPerPixel int) *stratified {
+	n := max(samplesPerPixel, 1)
+	nx := int(math.Ceil(math.Sqrt(float64(n))))
+	return &stratified{
+		pixelSample:     pixelSample{seed: seed},
+		samplesPerPixel: n,
+		nx:              nx,
+		ny:              (n + nx - 1) / nx,
+	}
+}
+
+func (s *stratified) Get1D() float64 {
+	d := s.dimension
+	s.dimension++
+
+	n := s.samplesPerPixel
+	stratum := permute(uint32(s.index%n), uint32(n), uint32(s.hash(d)))
+	return math.Min((float64(stratum)+s.random(d))/float64(n), oneMinusEpsilon)
+}
+
+func (s *stratified) Get2D() (float64, float64) {
+	d := s.dimension
+	s.dimension += 2
+
+	// with a grid larger than the number of samples, each sample gets a different cell
+	cells := s.nx * s.ny
+	stratum := int(permute(uint32(s.index%s.samplesPerPixel), uint32(cells), uint32(s.hash(d))))
+	x := (float64(stratum%s.nx) + s.random(d)) / float64(s.nx)
+	y := (float64(stratum/s.nx) + s.random(d+1)) / float64(s.ny)
+	return math.Min(x, oneMinusEpsilon), math.Min(y, oneMinusEpsilon)
+}
+
+func (s *stratified) Float64() float64 {
+	return s.Get1D()
+}
+
+// permute returns the element i of a random permutation of [0,l) defined by p, without building the
+// permutation (Kensler 2013, "Correlated Multi-Jittered Sampling")
+func permute(i, l, p uint32) uint32 {
+	w := l - 1
+	w |= w >> 1
+	w |= w >> 2
+	w |= w >> 4
+	w |= w >> 8
+	w |= w >> 16
+	for {
+		i ^= p
+		i *= 0xE170893D
+		i ^= p >> 16
+		i ^= (i & w) >> 4
+		i ^= p >> 8
+		i *= 0x0929EB3F
+		i ^= p >> 23
+		i ^= (i & w) >> 1
+		i *= 1 | p>>27
+		i *= 0x6935FA69
+		i ^= (i & w) >> 11
+		i *= 0x74DCB303
+		i ^= (i & w) >> 2
+		i *= 0x9E501CC3
+		i ^= (i & w) >> 2
+		i *= 0xC860A3DF
+		i &= w
+		i ^= i >> 5
+		if i < l {
+			break
+		}
+	}
+	return (i + p) % l
+}
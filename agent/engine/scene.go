@@ -3,46 +3,121 @@ package engine
 import (
 	"fmt"
 	"math"
-	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ath0m/DistributedRaytracer/agent/engine/camera"
 	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/filter"
 	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/sampler"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/tonemap"
 	"github.com/ath0m/DistributedRaytracer/agent/engine/utils"
 )
 
-// Pixels represents the array of pixels (in packed RGB value) to Render and/or save
-type Pixels []uint32
-
 // Scene represents the scene to Render.
 type Scene struct {
 	width, height int
 	raysPerPixel  int
 	camera        camera.Camera
 	world         Hittable
+	fog           *Fog
+	spectral      bool
+	pipeline      *tonemap.Pipeline
+
+	aovs            []AOV
+	firstHits       bool // whether the AOVs require the first hit of the rays
+	materialIndices []int
+	adaptive        *Adaptive
+
+	samplerKind sampler.Kind
+	seed        int64
+	filter      filter.Filter
+	transparent bool
+	progress    func(lines, total int)
+}
+
+// SceneOption defines an optional setting of the scene
+type SceneOption func(scene *Scene)
+
+// WithFog fills the scene with a homogeneous medium (nil means no fog)
+func WithFog(fog *Fog) SceneOption {
+	return func(scene *Scene) {
+		scene.fog = fog
+	}
+}
+
+// WithSpectral renders with wavelengths instead of RGB colors (required for dispersion)
+func WithSpectral(spectral bool) SceneOption {
+	return func(scene *Scene) {
+		scene.spectral = spectral
+	}
+}
+
+// WithPipeline defines how the radiance is converted to pixel values (exposure, tone mapping...)
+func WithPipeline(pipeline *tonemap.Pipeline) SceneOption {
+	return func(scene *Scene) {
+		scene.pipeline = pipeline
+	}
 }
 
-func NewScene(width, height, raysPerPixel int, camera camera.Camera, world Hittable) *Scene {
-	return &Scene{
+// WithSampler defines how the random values of the samples are generated (Render fails when kind is invalid)
+func WithSampler(kind sampler.Kind, seed int64) SceneOption {
+	return func(scene *Scene) {
+		scene.samplerKind = kind
+		scene.seed = seed
+	}
+}
+
+// WithTransparentBackground renders the background seen from the camera transparent: the alpha of the pixels is the
+// fraction of the samples hitting an object (the background is still seen in reflections and lights the scene)
+func WithTransparentBackground(transparent bool) SceneOption {
+	return func(scene *Scene) {
+		scene.transparent = transparent
+	}
+}
+
+// WithProgress reports the progress of the render: progress is called with the number of lines rendered so far
+// each time a line completes (concurrently, from the goroutines rendering the lines)
+func WithProgress(progress func(lines, total int)) SceneOption {
+	return func(scene *Scene) {
+		scene.progress = progress
+	}
+}
+
+func NewScene(width, height, raysPerPixel int, cam camera.Camera, world Hittable, options ...SceneOption) *Scene {
+	scene := &Scene{
 		width:        width,
 		height:       height,
 		raysPerPixel: raysPerPixel,
-		camera:       camera,
+		camera:       camera.ForImage(cam, width, height),
 		world:        world,
+		pipeline:     tonemap.NewPipeline(tonemap.DefaultSettings()),
+	}
+	for _, option := range options {
+		option(scene)
 	}
+	return scene
 }
 
 // pixel is an internal type which represents the pixel to be processed
 //
 //	x,y are the coordinates
-//	k is the index in the Pixels array
+//	k is the index of the pixel in the framebuffer
 //	color is the color that has been computed by casting raysPerPixel through x/y coordinates (not normalized to avoid accumulating rounding errors)
+//	luminanceSq is the sum of the squared luminance of the rays (to estimate the variance)
+//	tracedRays counts the rays traced through the world (camera rays and scattered rays) and
+//	intersectionTests the tests of these rays against the primitives of the world
+//	features are the first hits of the rays (only when rendering AOVs)
 type pixel struct {
-	x, y, k      int
-	color        clr.Color
-	raysPerPixel int
+	x, y, k           int
+	color             clr.Color
+	luminanceSq       float64
+	raysPerPixel      int
+	tracedRays        int
+	intersectionTests int
+	features          features
 }
 
 // split is a util function which split an array into an array of array with count elements each (the last one may hold less...)
@@ -59,40 +134,96 @@ func split(buf []*pixel, count int) [][]*pixel {
 	return chunks
 }
 
-// render works on a single pixels, casting raysPerPixel through it and accumulating the color
+// render works on a single pixels, casting raysPerPixel through it and accumulating the color (then more rays
+// until the pixel converges with adaptive sampling)
 //
-//	returns the normalized and gamma corrected value
-func (scene *Scene) render(rnd utils.Rnd, pixel *pixel, raysPerPixel int) uint32 {
-	c := pixel.color
-
+//	every sample is also splatted in the strip of the line (the reconstruction filter weighting it in the
+//	neighbouring pixels)
+func (scene *Scene) render(smp sampler.Sampler, st *strip, pixel *pixel, raysPerPixel int) {
 	for s := 0; s < raysPerPixel; s++ {
-		u := (float64(pixel.x) + rnd.Float64()) / float64(scene.width)
-		v := (float64(pixel.y) + rnd.Float64()) / float64(scene.height)
-		r := scene.camera.Ray(rnd, u, v)
-		c = c.Add(color(r, scene.world, 0))
+		scene.sample(smp, st, pixel)
 	}
 
-	pixel.color = c
-	pixel.raysPerPixel += raysPerPixel
-
-	// normalize the color (average of all the rays cast so far)
-	c = c.Scale(1.0 / float64(pixel.raysPerPixel))
+	if scene.adaptive != nil {
+		for !scene.adaptive.converged(pixel) {
+			for s := 0; s < adaptiveBatch && pixel.raysPerPixel < scene.adaptive.MaxSamples; s++ {
+				scene.sample(smp, st, pixel)
+			}
+		}
+	}
+}
 
-	// gamma correction
-	c = clr.Color{R: math.Sqrt(c.R), G: math.Sqrt(c.G), B: math.Sqrt(c.B)}
+// sample casts a single ray through the pixel, the sampler providing all the random values along its path
+func (scene *Scene) sample(smp sampler.Sampler, st *strip, pixel *pixel) {
+	smp.StartPixelSample(pixel.x, pixel.y, pixel.raysPerPixel)
+	pixel.raysPerPixel++
+
+	du, dv := smp.Get2D()
+	x, y := float64(pixel.x)+du, float64(pixel.y)+dv
+	r, weight := camera.WeightedRay(scene.camera, smp, x/float64(scene.width), y/float64(scene.height))
+	if r == nil {
+		// blocked by the lens (vignetting): a black sample, otherwise outside of the image circle (fisheye): part
+		// of the background
+		alpha := 1.0
+		if scene.transparent && weight != clr.Black {
+			alpha = 0
+		}
+		st.splat(x, y, clr.Black, alpha)
+		return
+	}
+	p := newPath(smp, scene.spectral)
+	r.Lambda = p.lambda()
+	c := p.rgb(p.convert(weight).Mult(scene.color(r, p, 0)))
+	pixel.tracedRays += p.tracedRays
+	pixel.intersectionTests += p.intersectionTests
+
+	if scene.transparent && p.first == nil {
+		// the camera ray reaches the sky: part of the background
+		st.splat(x, y, clr.Black, 0)
+		return
+	}
+	if scene.firstHits {
+		scene.firstHit(r, p.first, &pixel.features)
+	}
 
-	return c.PixelValue()
+	pixel.color = pixel.color.Add(c)
+	pixel.luminanceSq += luminance(c) * luminance(c)
+	st.splat(x, y, c, 1)
 }
 
-// Render is the main method of a scene. It is non-blocking and returns right away with the array of pixels
-// that will be computed asynchronously and a channel to indicate when the processing is complete. Note that
-// no synchronization is required on the array of pixels since it is an array of 32 bits values.
+// Render is the main method of a scene. It is non-blocking and returns right away with the framebuffer
+// that will be computed asynchronously and a channel to indicate when the processing is complete.
 // The image (width x height) will be split in lines each one processed in a separate goroutine (parallelCount
-// of them).
-func (scene *Scene) Render(parallelCount int) (Pixels, chan struct{}) {
-	pixels := make([]uint32, scene.width*scene.height)
+// of them). The samples are splatted in a film (see film) which is resolved in the framebuffer once all the
+// lines are complete. It fails right away (nothing is rendered) when the sampler of the scene is invalid.
+func (scene *Scene) Render(parallelCount int) (*Framebuffer, chan struct{}, error) {
+	raysPerPixel := scene.raysPerPixel
+	if scene.adaptive != nil {
+		raysPerPixel = scene.adaptive.MinSamples
+	}
+
+	// each goroutine uses its own sampler (the values only depend on the seed and the pixel, so the image does
+	// not depend on which goroutine renders which line)
+	parallelCount = max(parallelCount, 1)
+	samplers := make([]sampler.Sampler, parallelCount)
+	for c := range samplers {
+		smp, err := sampler.New(scene.samplerKind, scene.seed, raysPerPixel)
+		if err != nil {
+			return nil, nil, err
+		}
+		samplers[c] = smp
+	}
+
+	fb := NewFramebuffer(scene.width, scene.height, scene.aovs...)
+	fb.Pipeline = scene.pipeline
 	completed := make(chan struct{})
 
+	f := scene.filter
+	if f == nil {
+		f = filter.New(filter.DefaultSettings())
+	}
+	fm := newFilm(scene.width, scene.height, f)
+
 	go func() {
 		allPixelsToProcess := make([]*pixel, scene.width*scene.height)
 
@@ -124,29 +255,25 @@ func (scene *Scene) Render(parallelCount int) (Pixels, chan struct{}) {
 
 		// create a wait group to wait until all goroutine completes
 		wg := sync.WaitGroup{}
+		linesRendered := atomic.Int64{}
 
 		// create parallelCount goroutines
 		for c := 0; c < parallelCount; c++ {
 			wg.Add(1)
 			go func() {
-				// due to high contention on global rand, each goroutine uses its own random number generator
-				// thus avoiding massive slowdown
-				rnd := rand.New(rand.NewSource(rand.Int63()))
+				smp := samplers[c]
 
 				// process a bunch of pixels (in this case a line)
 				for ps := range pixelsToProcess {
-
-					// redisplay the line without gamma correction => make it darker to be more visible
-					for _, p := range ps {
-						if p.raysPerPixel > 0 {
-							col := p.color.Scale(1.0 / float64(p.raysPerPixel))
-							pixels[p.k] = col.PixelValue()
-						}
-					}
-
 					// render every pixel in the line
+					st := fm.newStrip(ps[0].y)
 					for _, p := range ps {
-						pixels[p.k] = scene.render(rnd, p, scene.raysPerPixel)
+						scene.render(smp, st, p, raysPerPixel)
+						fb.setFeatures(p.k, p)
+					}
+					fm.merge(st)
+					if scene.progress != nil {
+						scene.progress(int(linesRendered.Add(1)), len(lines))
 					}
 				}
 				wg.Done()
@@ -156,34 +283,157 @@ func (scene *Scene) Render(parallelCount int) (Pixels, chan struct{}) {
 		// wait for the pass to be completed
 		wg.Wait()
 
-		totalTime := time.Since(totalStart)
-		fmt.Printf("Processed %v rays per pixel in %v.", scene.raysPerPixel, totalTime)
+		for k := range allPixelsToProcess {
+			c, alpha := fm.resolve(k)
+			fb.set(k, c, alpha)
+		}
+
+		fb.Statistics = Statistics{Duration: time.Since(totalStart), MinRaysPerPixel: math.MaxInt}
+		for _, p := range allPixelsToProcess {
+			fb.Statistics.Rays += p.raysPerPixel
+			fb.Statistics.TracedRays += p.tracedRays
+			fb.Statistics.IntersectionTests += p.intersectionTests
+			fb.Statistics.MinRaysPerPixel = min(fb.Statistics.MinRaysPerPixel, p.raysPerPixel)
+			fb.Statistics.MaxRaysPerPixel = max(fb.Statistics.MaxRaysPerPixel, p.raysPerPixel)
+		}
+		fmt.Printf("Processed %v rays per pixel in %v.\n", fb.Statistics.RaysPerPixel(len(allPixelsToProcess)), fb.Statistics.Duration)
 
 		// signal completion
 		completed <- struct{}{}
 	}()
 
-	return pixels, completed
+	return fb, completed, nil
 }
 
 // color computes the color of the ray by checking which hitable gets hit and scattering
-// more rays (recursive) depending on material
-func color(r *geometry.Ray, world Hittable, depth int) clr.Color {
+// more rays (recursive) depending on material (or on the fog when the ray scatters before hitting anything)
+func (scene *Scene) color(r *geometry.Ray, p *path, depth int) clr.Color {
+	r.Tests = &p.intersectionTests
+	hit, hr := scene.world.Hit(r, &utils.Interval{Min: 0.001, Max: math.MaxFloat64})
+	if hit && p.tracedRays == 0 {
+		// the camera ray: its hit gives the coverage and the AOVs of the sample (copied as shading changes it)
+		first := *hr
+		p.first = &first
+	}
+	p.tracedRays++
+
+	weight := clr.White
+	if m := p.medium(); m >= 0 {
+		// inside a dielectric: light is absorbed along the way
+		if die := p.interiors[m].dielectric; die.absorbs() {
+			distance := math.Inf(1)
+			if hit {
+				distance = hr.T * r.Direction.Length()
+			}
+			weight = p.convert(die.transmittance(distance))
+		}
+	} else if scene.fog != nil {
+		tMax := scene.fog.Distance / r.Direction.Length()
+		if hit {
+			tMax = hr.T
+		}
+
+		scattered, w, scatteredRay := scene.fog.sample(r, tMax)
+		if scattered {
+			if depth >= 50 {
+				return clr.Black
+			}
+			scatteredRay.Lambda = r.Lambda
+			return p.convert(w).Mult(scene.color(scatteredRay, p, depth+1))
+		}
+		weight = p.convert(w)
+	}
+
+	return weight.Mult(scene.surfaceColor(r, p, hit, hr, depth))
+}
 
-	if hit, hr := world.Hit(r, &utils.Interval{Min: 0.001, Max: math.MaxFloat64}); hit {
+// surfaceColor computes the color of the ray once it reached a surface (or the sky when nothing was hit)
+func (scene *Scene) surfaceColor(r *geometry.Ray, p *path, hit bool, hr *HitRecord, depth int) clr.Color {
+	if hit {
 		if depth >= 50 {
 			return clr.Black
 		}
 
+		if np, ok := hr.Material.(normalPerturber); ok {
+			np.perturbNormal(r, hr)
+		}
+
+		if die, ok := asDielectric(hr.Material); ok {
+			return scene.dielectricColor(r, p, hr, die, depth)
+		}
+
+		emitted := clr.Black
+		if e, ok := hr.Material.(emitter); ok {
+			emitted = p.convert(e.emitted(hr))
+		}
+
+		weight := p.interact(hr.Material)
 		if wasScattered, attenuation, scattered := hr.Material.scatter(r, hr); wasScattered {
-			return attenuation.Mult(color(scattered, world, depth+1))
+			scattered.Lambda = r.Lambda
+			return emitted.Add(weight.Mult(p.convert(*attenuation)).Mult(scene.color(scattered, p, depth+1)))
 		} else {
-			return clr.Black
+			return emitted
 		}
 	}
 
+	return p.convert(sky(r))
+}
+
+// sky returns the color of the sky in the direction of the ray
+func sky(r *geometry.Ray) clr.Color {
 	unitDirection := r.Direction.Unit()
 	t := 0.5 * (unitDirection.Y + 1.0)
 
 	return clr.White.Scale(1.0 - t).Add(clr.Color{R: 0.5, G: 0.7, B: 1.0}.Scale(t))
 }
+
+// dielectricColor computes the color of the ray hitting a dielectric. The indices of refraction on each side of
+// the interface depend on the dielectrics the path is inside of: when dielectrics overlap, only the surfaces of
+// the one with the highest priority are actual interfaces, the others are ignored (false hits).
+func (scene *Scene) dielectricColor(r *geometry.Ray, p *path, hr *HitRecord, die Dielectric, depth int) clr.Color {
+	current := p.medium()
+	entering := geometry.Dot(r.Direction, hr.GeometricNormal) < 0
+	continued := &geometry.Ray{Origin: hr.P, Direction: r.Direction, Rnd: r.Rnd, Lambda: r.Lambda}
+
+	if entering {
+		if current >= 0 && p.interiors[current].dielectric.priority > die.priority {
+			// false hit: the medium does not change
+			p.interiors = append(p.interiors, interior{object: hr.Object, dielectric: die})
+			return scene.color(continued, p, depth+1)
+		}
+
+		weight := p.interact(die)
+		if current >= 0 {
+			weight = weight.Mult(p.interact(p.interiors[current].dielectric))
+		}
+
+		refracted, scattered := die.scatterBetween(r, hr, p.ior(current), die.ior(p.lambda()))
+		if refracted {
+			p.interiors = append(p.interiors, interior{object: hr.Object, dielectric: die})
+		}
+		return weight.Mult(scene.color(scattered, p, depth+1))
+	}
+
+	i := p.find(hr.Object)
+	if i >= 0 && i != current {
+		// false hit: leaving a dielectric which does not define the medium
+		p.remove(i)
+		return scene.color(continued, p, depth+1)
+	}
+
+	next := current
+	if i >= 0 {
+		next = p.mediumExcept(i)
+	}
+
+	weight := p.interact(die)
+	if next >= 0 {
+		weight = weight.Mult(p.interact(p.interiors[next].dielectric))
+	}
+
+	refracted, scattered := die.scatterBetween(r, hr, die.ior(p.lambda()), p.ior(next))
+	if refracted && i >= 0 {
+		p.remove(i)
+	}
+	return weight.Mult(scene.color(scattered, p, depth+1))
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/camera"
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/sampler"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/tonemap"
+)
+
+// testCamera looks at the origin from Z=1
+func testCamera() camera.Camera {
+	return camera.NewCamera(geometry.Point3{Z: 1}, geometry.Point3{}, geometry.Vec3{Y: 1}, 90, 1, 0, 1)
+}
+
+func TestRenderInvalidSampler(t *testing.T) {
+	scene := NewScene(4, 4, 1, testCamera(), HittableList{}, WithSampler(sampler.Kind("unknown"), 0))
+	if _, _, err := scene.Render(2); err == nil {
+		t.Errorf("Expected an error for an unknown sampler")
+	}
+}
+
+func TestRender(t *testing.T) {
+	world := HittableList{Sphere{Center: geometry.Point3{}, Radius: 0.5, Material: Lambertian{}}}
+	scene := NewScene(4, 4, 2, testCamera(), world, WithSampler(sampler.Sobol, 2024))
+	fb, completed, err := scene.Render(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-completed
+	if fb.Statistics.Rays != 4*4*2 {
+		t.Errorf("Expected %v, but got %v", 4*4*2, fb.Statistics.Rays)
+	}
+}
+
+func TestRenderPipeline(t *testing.T) {
+	pipeline := tonemap.NewPipeline(tonemap.Settings{EV: 1, ToneMapper: tonemap.Clamp})
+	scene := NewScene(4, 4, 1, testCamera(), HittableList{}, WithPipeline(pipeline))
+	fb, completed, err := scene.Render(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-completed
+	if fb.Pipeline != pipeline {
+		t.Errorf("Expected the framebuffer to convert with the pipeline of the scene")
+	}
+
+	// without the option, the radiance is converted as is
+	if fb := NewFramebuffer(1, 1); fb.Pipeline == nil {
+		t.Errorf("Expected a default pipeline")
+	}
+}
+
+func TestRenderFirstHit(t *testing.T) {
+	// a dense medium covering the field of view: its collisions are random, the AOVs and coverage must be those of
+	// the beauty path
+	world := &recorder{HittableList: HittableList{NewConstantMedium(Sphere{Radius: 0.9}, 20, Isotropic{albedo: clr.White})}}
+	scene := NewScene(1, 1, 1, testCamera(), world, WithSampler(sampler.Independent, 2024), WithAOVs(Depth), WithTransparentBackground(true))
+	fb, completed, err := scene.Render(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-completed
+
+	if fb.Statistics.TracedRays != len(world.rays) {
+		t.Errorf("Expected %v traced rays (each ray traced once), but got %v", len(world.rays), fb.Statistics.TracedRays)
+	}
+	first := world.hits[0]
+	if first == nil {
+		t.Fatalf("Expected the camera ray to collide in the medium")
+	}
+	depth := first.T * world.rays[0].Direction.Length()
+	if d := float64(fb.AOVs[Depth][0]); math.Abs(d-depth) > 1e-5 {
+		t.Errorf("Expected the depth of the first hit %v, but got %v", depth, d)
+	}
+	if a := fb.Alpha(0, 0); a != 1 {
+		t.Errorf("Expected a covered pixel, but got alpha %v", a)
+	}
+}
+
+func TestRenderTransparentVignetting(t *testing.T) {
+	// the camera is inside a sphere: every sample is covered, the ones blocked by the lens included
+	cam, err := camera.UnmarshalCamera([]byte(`{"lookFrom": {}, "lookAt": {"Z": -1}, "vfov": 90, "aperture": 1, "focusDist": 5, "vignetting": 0.5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := HittableList{Sphere{Radius: 100, Material: Lambertian{albedo: clr.White}}}
+	scene := NewScene(8, 8, 16, cam, world, WithSampler(sampler.Independent, 2024), WithTransparentBackground(true))
+	fb, completed, err := scene.Render(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-completed
+
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			if a := fb.Alpha(x, y); math.Abs(a-1) > 1e-6 {
+				t.Errorf("Expected a covered pixel (%v,%v), but got alpha %v", x, y, a)
+			}
+		}
+	}
+	// the vignetting darkens the corners
+	if corner, center := fb.At(0, 0).R, fb.At(4, 4).R; corner >= center {
+		t.Errorf("Expected a darker corner %v than the center %v", corner, center)
+	}
+}
@@ -0,0 +1,148 @@
+// Package spectrum provides the conversions needed to render with wavelengths instead of RGB colors.
+//
+// Each camera path carries 3 wavelengths (hero wavelength sampling): a hero wavelength sampled uniformly in the
+// visible range and 2 others rotated by a third of the range. RGB colors are upsampled to smooth spectra using a
+// partition of unity of 3 basis functions (so that reflectances stay within [0,1] and white stays white), and
+// the radiance carried at each wavelength is converted back to RGB through the CIE 1931 color matching functions.
+package spectrum
+
+import (
+	"math"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+)
+
+const (
+	MinWavelength = 380.0 // nm
+	MaxWavelength = 780.0 // nm
+	Count         = 3     // number of wavelengths carried by a path
+)
+
+// Wavelengths defines the wavelengths (in nm) carried by a path, the first one being the hero wavelength
+type Wavelengths [Count]float64
+
+// SampleWavelengths returns the wavelengths for a path, u being a uniform random number in [0,1)
+func SampleWavelengths(u float64) Wavelengths {
+	var lambdas Wavelengths
+	span := MaxWavelength - MinWavelength
+	hero := MinWavelength + u*span
+	for i := range lambdas {
+		lambda := hero + float64(i)*span/Count
+		if lambda >= MaxWavelength {
+			lambda -= span
+		}
+		lambdas[i] = lambda
+	}
+	return lambdas
+}
+
+// gaussian is the piecewise gaussian used by the analytic fit of the color matching functions
+func gaussian(lambda, mu, sigma1, sigma2 float64) float64 {
+	sigma := sigma1
+	if lambda >= mu {
+		sigma = sigma2
+	}
+	t := (lambda - mu) / sigma
+	return math.Exp(-0.5 * t * t)
+}
+
+// CIE returns the CIE 1931 color matching functions at lambda (nm) using the multi-lobe fit from
+// "Simple Analytic Approximations to the CIE XYZ Color Matching Functions" (Wyman, Sloan, Shirley)
+func CIE(lambda float64) (x, y, z float64) {
+	x = 1.056*gaussian(lambda, 599.8, 37.9, 31.0) + 0.362*gaussian(lambda, 442.0, 16.0, 26.7) - 0.065*gaussian(lambda, 501.1, 20.4, 26.2)
+	y = 0.821*gaussian(lambda, 568.8, 46.9, 40.5) + 0.286*gaussian(lambda, 530.9, 16.3, 31.1)
+	z = 1.217*gaussian(lambda, 437.0, 11.8, 36.0) + 0.681*gaussian(lambda, 459.0, 26.0, 13.8)
+	return
+}
+
+// xyzToLinearSRGB converts XYZ to linear sRGB (D65)
+func xyzToLinearSRGB(x, y, z float64) [3]float64 {
+	return [3]float64{
+		3.2404542*x - 1.5371385*y - 0.4985314*z,
+		-0.9692660*x + 1.8760108*y + 0.0415560*z,
+		0.0556434*x - 0.2040259*y + 1.0572252*z,
+	}
+}
+
+func sigmoid(x float64) float64 {
+	return 1.0 / (1.0 + math.Exp(-x))
+}
+
+// basis returns the value at lambda of the 3 basis functions (red, green, blue) used to upsample RGB colors
+//
+//	they are smooth steps which sum up to 1 at every wavelength
+func basis(lambda float64) [3]float64 {
+	red := sigmoid((lambda - 595.0) / 10.0)
+	blue := 1 - sigmoid((lambda-490.0)/10.0)
+	return [3]float64{red, 1 - red - blue, blue}
+}
+
+// Upsample returns the value at lambda of the smooth spectrum corresponding to the RGB color
+func Upsample(c clr.Color, lambda float64) float64 {
+	b := basis(lambda)
+	return c.R*b[0] + c.G*b[1] + c.B*b[2]
+}
+
+// UpsampleAll returns the values of the spectrum corresponding to the RGB color at each wavelength
+//
+//	the values are packed in a color (R for the first wavelength, G for the second and B for the third) so
+//	that they can be carried along a path like an RGB color
+func UpsampleAll(c clr.Color, lambdas *Wavelengths) clr.Color {
+	return clr.Color{R: Upsample(c, lambdas[0]), G: Upsample(c, lambdas[1]), B: Upsample(c, lambdas[2])}
+}
+
+// calibration maps linear sRGB computed from the spectra back to the RGB colors they were upsampled from
+var calibration = computeCalibration()
+
+// computeCalibration computes the inverse of the matrix of the RGB responses of each basis function, which makes
+// the conversion RGB -> spectrum -> RGB the identity
+func computeCalibration() [3][3]float64 {
+	var m [3][3]float64
+	for lambda := MinWavelength + 0.5; lambda < MaxWavelength; lambda++ {
+		x, y, z := CIE(lambda)
+		rgb := xyzToLinearSRGB(x, y, z)
+		b := basis(lambda)
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				m[i][j] += rgb[i] * b[j]
+			}
+		}
+	}
+	return invert(m)
+}
+
+func invert(m [3][3]float64) [3][3]float64 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+
+	return [3][3]float64{
+		{(m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det, (m[0][2]*m[2][1] - m[0][1]*m[2][2]) / det, (m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det},
+		{(m[1][2]*m[2][0] - m[1][0]*m[2][2]) / det, (m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det, (m[0][2]*m[1][0] - m[0][0]*m[1][2]) / det},
+		{(m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det, (m[0][1]*m[2][0] - m[0][0]*m[2][1]) / det, (m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det},
+	}
+}
+
+// ToRGB converts the radiance carried at each wavelength (packed in a color, see UpsampleAll) to an RGB color
+//
+//	this is a Monte Carlo estimate of the integral over the visible range: averaging it over many sampled
+//	wavelengths gives the actual color
+func ToRGB(lambdas *Wavelengths, values clr.Color) clr.Color {
+	v := [Count]float64{values.R, values.G, values.B}
+	weight := (MaxWavelength - MinWavelength) / Count
+
+	var rgb [3]float64
+	for i, lambda := range lambdas {
+		x, y, z := CIE(lambda)
+		c := xyzToLinearSRGB(x, y, z)
+		for j := 0; j < 3; j++ {
+			rgb[j] += c[j] * v[i] * weight
+		}
+	}
+
+	var res [3]float64
+	for i := 0; i < 3; i++ {
+		res[i] = calibration[i][0]*rgb[0] + calibration[i][1]*rgb[1] + calibration[i][2]*rgb[2]
+	}
+	return clr.Color{R: res[0], G: res[1], B: res[2]}
+}
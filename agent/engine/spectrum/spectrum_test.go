@@ -0,0 +1,60 @@
+package spectrum
+
+import (
+	"math"
+	"testing"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+)
+
+func TestSampleWavelengths(t *testing.T) {
+	for _, u := range []float64{0, 0.25, 0.5, 0.999} {
+		lambdas := SampleWavelengths(u)
+		for _, lambda := range lambdas {
+			if lambda < MinWavelength || lambda >= MaxWavelength {
+				t.Errorf("Wavelength %v out of the visible range for u=%v", lambda, u)
+			}
+		}
+		if expected := MinWavelength + u*(MaxWavelength-MinWavelength); lambdas[0] != expected {
+			t.Errorf("Expected hero wavelength %v, but got %v", expected, lambdas[0])
+		}
+	}
+}
+
+func TestUpsampleBounds(t *testing.T) {
+	for lambda := MinWavelength; lambda < MaxWavelength; lambda += 5 {
+		if v := Upsample(clr.White, lambda); math.Abs(v-1) > 1e-9 {
+			t.Errorf("Expected white to be 1 at %v, but got %v", lambda, v)
+		}
+		for _, c := range []clr.Color{{R: 1}, {G: 1}, {B: 1}} {
+			if v := Upsample(c, lambda); v < 0 || v > 1 {
+				t.Errorf("Expected reflectance of %v in [0,1] at %v, but got %v", c, lambda, v)
+			}
+		}
+	}
+}
+
+// TestRoundTrip checks that converting an RGB color to a spectrum and back (averaging over all the wavelengths)
+// gives the original color
+func TestRoundTrip(t *testing.T) {
+	cases := []clr.Color{
+		clr.White,
+		{R: 1, G: 0, B: 0},
+		{R: 0, G: 1, B: 0},
+		{R: 0, G: 0, B: 1},
+		{R: 0.2, G: 0.5, B: 0.8},
+	}
+
+	const n = 1000
+	for _, c := range cases {
+		var sum clr.Color
+		for i := 0; i < n; i++ {
+			lambdas := SampleWavelengths((float64(i) + 0.5) / n)
+			sum = sum.Add(ToRGB(&lambdas, UpsampleAll(c, &lambdas)))
+		}
+		result := sum.Scale(1.0 / n)
+		if math.Abs(result.R-c.R) > 1e-2 || math.Abs(result.G-c.G) > 1e-2 || math.Abs(result.B-c.B) > 1e-2 {
+			t.Errorf("Expected %v, but got %v", c, result)
+		}
+	}
+}
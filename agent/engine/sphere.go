@@ -38,6 +38,7 @@ func (s *Sphere) UnmarshalJSON(data []byte) error {
 
 // Hit implements the Hit interface for a Sphere
 func (s Sphere) Hit(r *geometry.Ray, interval *utils.Interval) (bool, *HitRecord) {
+	r.CountTest()
 	oc := r.Origin.Sub(s.Center)
 	a := r.Direction.LengthSq()
 	b := geometry.Dot(oc, r.Direction)
@@ -58,11 +59,46 @@ func (s Sphere) Hit(r *geometry.Ray, interval *utils.Interval) (bool, *HitRecord
 	}
 
 	hitPoint := r.PointAt(root)
+	normal := hitPoint.Sub(s.Center).Scale(1 / s.Radius)
+	u, v, dpdu, dpdv := s.surface(hitPoint)
 	hr := HitRecord{
-		T:        root,
-		P:        hitPoint,
-		Normal:   hitPoint.Sub(s.Center).Scale(1 / s.Radius),
-		Material: s.Material,
+		T:               root,
+		P:               hitPoint,
+		Normal:          normal,
+		GeometricNormal: normal,
+		U:               u,
+		V:               v,
+		Dpdu:            dpdu,
+		Dpdv:            dpdv,
+		Material:        s.Material,
 	}
 	return true, &hr
 }
+
+// surface computes the (u,v) coordinates of a point on the sphere and the tangent frame at that point
+//
+//	u is the angle around the Y axis (from X=-1), v the angle from Y=-1 to Y=+1, both mapped to [0,1]
+func (s Sphere) surface(p geometry.Point3) (u, v float64, dpdu, dpdv geometry.Vec3) {
+	local := p.Sub(s.Center)
+	phi := math.Atan2(-local.Z, local.X) + math.Pi
+	theta := math.Acos(math.Max(-1, math.Min(1, -local.Y/s.Radius)))
+
+	u = phi / (2 * math.Pi)
+	v = theta / math.Pi
+
+	dpdu = geometry.Vec3{X: local.Z, Y: 0, Z: -local.X}.Scale(2 * math.Pi)
+
+	rho := math.Sqrt(local.X*local.X + local.Z*local.Z)
+	if rho < 1e-12 {
+		// at the poles the parametrization degenerates: pick any frame around the normal
+		dpdu = geometry.Vec3{X: 0, Y: 0, Z: -2 * math.Pi * s.Radius}
+		dpdv = geometry.Vec3{X: math.Pi * s.Radius, Y: 0, Z: 0}
+		if local.Y > 0 {
+			dpdv = dpdv.Negate()
+		}
+		return
+	}
+
+	dpdv = geometry.Vec3{X: -local.Y * local.X / rho, Y: rho, Z: -local.Y * local.Z / rho}.Scale(math.Pi)
+	return
+}
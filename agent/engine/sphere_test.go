@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/geometry"
+)
+
+// pointAt returns the point of the sphere at (u,v) (the inverse of surface)
+func (s Sphere) pointAt(u, v float64) geometry.Point3 {
+	phi := u*2*math.Pi - math.Pi
+	theta := v * math.Pi
+	rho := s.Radius * math.Sin(theta)
+	return s.Center.Translate(geometry.Vec3{X: rho * math.Cos(phi), Y: -s.Radius * math.Cos(theta), Z: -rho * math.Sin(phi)})
+}
+
+func TestSphereSurface(t *testing.T) {
+	s := Sphere{Center: geometry.Point3{X: 1, Y: -2, Z: 3}, Radius: 2}
+	const h = 1e-6
+
+	for _, uv := range [][2]float64{{0.1, 0.2}, {0.3, 0.5}, {0.5, 0.5}, {0.75, 0.9}, {0.9, 0.6}} {
+		p := s.pointAt(uv[0], uv[1])
+		u, v, dpdu, dpdv := s.surface(p)
+		if math.Abs(u-uv[0]) > 1e-9 || math.Abs(v-uv[1]) > 1e-9 {
+			t.Errorf("Expected (u,v) %v, but got (%v,%v)", uv, u, v)
+		}
+
+		// the derivatives match the finite differences of the parametrization
+		du := s.pointAt(uv[0]+h, uv[1]).Sub(s.pointAt(uv[0]-h, uv[1])).Scale(1 / (2 * h))
+		dv := s.pointAt(uv[0], uv[1]+h).Sub(s.pointAt(uv[0], uv[1]-h)).Scale(1 / (2 * h))
+		if du.Sub(dpdu).Length() > 1e-5 {
+			t.Errorf("Expected dpdu %v at %v, but got %v", du, uv, dpdu)
+		}
+		if dv.Sub(dpdv).Length() > 1e-5 {
+			t.Errorf("Expected dpdv %v at %v, but got %v", dv, uv, dpdv)
+		}
+	}
+}
+
+func TestSphereSurfaceFrame(t *testing.T) {
+	s := Sphere{Radius: 2}
+
+	// the poles included: the derivatives are tangent and dpdu x dpdv points outwards
+	for _, uv := range [][2]float64{{0.1, 0.2}, {0.6, 0.7}, {0.5, 0}, {0.5, 1}} {
+		p := s.pointAt(uv[0], uv[1])
+		_, _, dpdu, dpdv := s.surface(p)
+		n := p.Sub(s.Center).Unit()
+
+		if math.Abs(geometry.Dot(dpdu, n)) > 1e-9 || math.Abs(geometry.Dot(dpdv, n)) > 1e-9 {
+			t.Errorf("Expected tangent derivatives at %v, but got %v, %v", uv, dpdu, dpdv)
+		}
+		if cross := geometry.Cross(dpdu, dpdv); geometry.Dot(cross.Unit(), n) < 1-1e-9 {
+			t.Errorf("Expected dpdu x dpdv along the normal %v at %v, but got %v", n, uv, cross)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package texture
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/assets"
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+)
+
+// MaxPixels is the largest image loaded as a texture (in pixels, 4096x4096)
+const MaxPixels = 4096 * 4096
+
+// Image defines a texture backed by an image, addressed with (u,v) coordinates in [0,1]
+//
+//	u goes left to right, v goes bottom to top; coordinates outside [0,1] wrap around (repeat)
+type Image struct {
+	width, height int
+	pixels        []clr.Color
+}
+
+// Load reads an image file (png or jpeg) of the asset directory into a texture
+//
+//	the size of the image is checked against MaxPixels before decoding it
+func Load(file string) (*Image, error) {
+	f, _, err := assets.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > MaxPixels/config.Height {
+		return nil, fmt.Errorf("%s: image of %vx%v pixels exceeds the maximum of %v pixels", file, config.Width, config.Height, MaxPixels)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromImage(img), nil
+}
+
+// FromImage converts an image into a texture (values are in [0,1], not gamma decoded)
+func FromImage(img image.Image) *Image {
+	bounds := img.Bounds()
+	tex := &Image{
+		width:  bounds.Dx(),
+		height: bounds.Dy(),
+		pixels: make([]clr.Color, bounds.Dx()*bounds.Dy()),
+	}
+
+	k := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			tex.pixels[k] = clr.Color{R: float64(r) / 0xFFFF, G: float64(g) / 0xFFFF, B: float64(b) / 0xFFFF}
+			k++
+		}
+	}
+
+	return tex
+}
+
+// Size returns the size of the texture in texels
+func (tex *Image) Size() (width, height int) {
+	return tex.width, tex.height
+}
+
+// texel returns the texel at x,y (wrapping around)
+func (tex *Image) texel(x, y int) clr.Color {
+	x = ((x % tex.width) + tex.width) % tex.width
+	y = ((y % tex.height) + tex.height) % tex.height
+	return tex.pixels[y*tex.width+x]
+}
+
+// Value returns the bilinearly filtered color at (u,v)
+func (tex *Image) Value(u, v float64) clr.Color {
+	// image rows go top to bottom while v goes bottom to top
+	x := u*float64(tex.width) - 0.5
+	y := (1-v)*float64(tex.height) - 0.5
+
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+
+	top := tex.texel(ix, iy).Scale(1 - fx).Add(tex.texel(ix+1, iy).Scale(fx))
+	bottom := tex.texel(ix, iy+1).Scale(1 - fx).Add(tex.texel(ix+1, iy+1).Scale(fx))
+
+	return top.Scale(1 - fy).Add(bottom.Scale(fy))
+}
+
+// Height returns the scalar value at (u,v) (average of the channels) to be used as a height map
+func (tex *Image) Height(u, v float64) float64 {
+	c := tex.Value(u, v)
+	return (c.R + c.G + c.B) / 3.0
+}
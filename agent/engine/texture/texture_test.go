@@ -0,0 +1,97 @@
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/assets"
+)
+
+func checkerboard() *Image {
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.Gray{Y: 255})
+	img.Set(1, 1, color.Gray{Y: 255})
+	return FromImage(img)
+}
+
+func TestImageValue(t *testing.T) {
+	tex := checkerboard()
+
+	cases := []struct {
+		u, v     float64
+		expected float64
+	}{
+		{0.25, 0.75, 1.0}, // top left texel center
+		{0.75, 0.75, 0.0}, // top right texel center
+		{0.25, 0.25, 0.0}, // bottom left texel center
+		{0.75, 0.25, 1.0}, // bottom right texel center
+		{0.5, 0.5, 0.5},   // between all 4 texels
+		{1.25, 0.75, 1.0}, // wraps around
+	}
+
+	for _, tc := range cases {
+		result := tex.Value(tc.u, tc.v)
+		if math.Abs(result.R-tc.expected) > 1e-8 || result.R != result.G || result.R != result.B {
+			t.Errorf("Expected %v at (%v,%v), but got %v", tc.expected, tc.u, tc.v, result)
+		}
+	}
+}
+
+func TestImageHeight(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{R: 255, G: 0, B: 0, A: 255})
+	tex := FromImage(img)
+
+	if h := tex.Height(0.5, 0.5); math.Abs(h-1.0/3.0) > 1e-8 {
+		t.Errorf("Expected %v, but got %v", 1.0/3.0, h)
+	}
+}
+
+// writePNG writes a PNG of the size in the directory, its header patched to declare width x height pixels
+func writePNG(t *testing.T, dir, name string, width, height uint32) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// IHDR: length and type (8 bytes after the signature), width and height, then the CRC of the chunk
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "assets")
+	os.Mkdir(dir, 0o755)
+	assets.SetDir(dir)
+	defer assets.SetDir("assets")
+	writePNG(t, dir, "texel.png", 1, 1)
+	writePNG(t, dir, "huge.png", 100000, 100000)
+	writePNG(t, root, "outside.png", 1, 1)
+
+	tex, err := Load("texel.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, h := tex.Size(); w != 1 || h != 1 {
+		t.Errorf("Expected 1x1, but got %vx%v", w, h)
+	}
+
+	for _, file := range []string{filepath.Join(root, "outside.png"), "../outside.png", "huge.png"} {
+		if _, err := Load(file); err == nil {
+			t.Errorf("Expected an error for %v", file)
+		}
+	}
+}
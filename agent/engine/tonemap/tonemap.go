@@ -0,0 +1,158 @@
+// Package tonemap converts the radiance computed by the renderer into displayable colors: exposure, white
+// balance, tone mapping and sRGB encoding.
+package tonemap
+
+import (
+	"fmt"
+	"math"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+)
+
+// tone mappers
+const (
+	Clamp    = "clamp"
+	Reinhard = "reinhard"
+	Filmic   = "filmic"
+	ACES     = "aces"
+)
+
+// referenceEV is the exposure value (at ISO 100) giving an exposure of 1: the radiance computed by the renderer
+// is relative (a white sky is 1), ISO 100, 1/125 s at f/8 renders it as is
+const referenceEV = 13.0
+
+// Settings defines how radiance is converted to displayable colors
+//
+//	The exposure is either given directly (ev, in stops) or computed from the settings of a camera (iso, shutter
+//	in seconds and fstop), ev then being a compensation. whiteBalance is the color temperature (Kelvin) of the
+//	light which should appear white (0 for no white balance).
+type Settings struct {
+	EV           float64 `json:"ev"`
+	ISO          float64 `json:"iso,omitempty"`
+	Shutter      float64 `json:"shutter,omitempty"`
+	FStop        float64 `json:"fstop,omitempty"`
+	WhiteBalance float64 `json:"whiteBalance,omitempty"`
+	ToneMapper   string  `json:"toneMapper"`
+}
+
+// DefaultSettings returns the settings rendering the radiance as is (clamped)
+func DefaultSettings() Settings {
+	return Settings{ToneMapper: Clamp}
+}
+
+// Validate checks the settings
+func (s *Settings) Validate() error {
+	photographic := s.ISO != 0 || s.Shutter != 0 || s.FStop != 0
+	if photographic && (s.ISO <= 0 || s.Shutter <= 0 || s.FStop <= 0) {
+		return fmt.Errorf("iso, shutter and fstop must all be positive when one of them is set")
+	}
+	if s.WhiteBalance != 0 && (s.WhiteBalance < 1667 || s.WhiteBalance > 25000) {
+		return fmt.Errorf("whiteBalance must be in [1667,25000] Kelvin: %v", s.WhiteBalance)
+	}
+	switch s.ToneMapper {
+	case Clamp, Reinhard, Filmic, ACES:
+	default:
+		return fmt.Errorf("unknown tone mapper: %s", s.ToneMapper)
+	}
+	return nil
+}
+
+// Pipeline applies the settings to colors
+type Pipeline struct {
+	exposure   float64
+	balance    [3][3]float64
+	toneMapper func(float64) float64
+}
+
+// NewPipeline creates the pipeline for the settings (which must be valid)
+func NewPipeline(s Settings) *Pipeline {
+	ev := referenceEV
+	if s.ISO > 0 {
+		// EV100 = log2(N^2 / t) - log2(ISO / 100)
+		ev = math.Log2(s.FStop*s.FStop/s.Shutter) - math.Log2(s.ISO/100.0)
+	}
+
+	p := &Pipeline{
+		exposure: math.Pow(2, referenceEV-ev+s.EV),
+		balance:  identity,
+	}
+	if s.WhiteBalance > 0 {
+		p.balance = whiteBalance(s.WhiteBalance)
+	}
+
+	switch s.ToneMapper {
+	case Reinhard:
+		p.toneMapper = reinhard
+	case Filmic:
+		p.toneMapper = filmic
+	case ACES:
+		p.toneMapper = aces
+	default:
+		p.toneMapper = clamp
+	}
+
+	return p
+}
+
+// Exposure returns the factor applied to the radiance
+func (p *Pipeline) Exposure() float64 {
+	return p.exposure
+}
+
+// Linear applies the exposure and white balance (the result is still linear radiance)
+func (p *Pipeline) Linear(c clr.Color) clr.Color {
+	return multiply(&p.balance, c.Scale(p.exposure))
+}
+
+// Apply converts linear radiance to an sRGB encoded color in [0,1]
+func (p *Pipeline) Apply(c clr.Color) clr.Color {
+	c = p.Linear(c)
+	return clr.Color{
+		R: EncodeSRGB(p.toneMapper(c.R)),
+		G: EncodeSRGB(p.toneMapper(c.G)),
+		B: EncodeSRGB(p.toneMapper(c.B)),
+	}
+}
+
+// EncodeSRGB applies the sRGB transfer function to a linear value in [0,1]
+func EncodeSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * math.Max(v, 0)
+	}
+	return 1.055*math.Pow(math.Min(v, 1), 1/2.4) - 0.055
+}
+
+// DecodeSRGB is the inverse of EncodeSRGB
+func DecodeSRGB(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func clamp(v float64) float64 {
+	return math.Min(math.Max(v, 0), 1)
+}
+
+func reinhard(v float64) float64 {
+	v = math.Max(v, 0)
+	return v / (1 + v)
+}
+
+// hable is the filmic curve from Uncharted 2 (John Hable)
+func hable(x float64) float64 {
+	const a, b, c, d, e, f = 0.15, 0.50, 0.10, 0.20, 0.02, 0.30
+	return (x*(a*x+c*b)+d*e)/(x*(a*x+b)+d*f) - e/f
+}
+
+func filmic(v float64) float64 {
+	const exposureBias, whitePoint = 2.0, 11.2
+	return clamp(hable(math.Max(v, 0)*exposureBias) / hable(whitePoint))
+}
+
+// aces is the fit of the ACES reference rendering transform by Krzysztof Narkowicz
+func aces(v float64) float64 {
+	const a, b, c, d, e = 2.51, 0.03, 2.43, 0.59, 0.14
+	x := math.Max(v, 0) * 0.6
+	return clamp((x * (a*x + b)) / (x*(c*x+d) + e))
+}
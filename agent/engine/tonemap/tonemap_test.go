@@ -0,0 +1,109 @@
+package tonemap
+
+import (
+	"math"
+	"testing"
+
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+)
+
+func TestEncodeSRGB(t *testing.T) {
+	cases := []struct {
+		v, expected float64
+	}{
+		{-1.0, 0.0},
+		{0.0, 0.0},
+		{0.002, 0.02584},
+		{0.18, 0.46135},
+		{1.0, 1.0},
+		{2.0, 1.0},
+	}
+
+	for _, tc := range cases {
+		result := EncodeSRGB(tc.v)
+		if math.Abs(result-tc.expected) > 1e-4 {
+			t.Errorf("Expected %v for %v, but got %v", tc.expected, tc.v, result)
+		}
+		if tc.v >= 0 && tc.v <= 1 {
+			if back := DecodeSRGB(result); math.Abs(back-tc.v) > 1e-9 {
+				t.Errorf("Expected %v after decoding, but got %v", tc.v, back)
+			}
+		}
+	}
+}
+
+func TestToneMappers(t *testing.T) {
+	for _, name := range []string{Clamp, Reinhard, Filmic, ACES} {
+		p := NewPipeline(Settings{ToneMapper: name})
+
+		previous := -1.0
+		for v := 0.0; v < 100; v += 0.05 {
+			result := p.Apply(clr.Color{R: v, G: v, B: v})
+			if result.R < 0 || result.R > 1 {
+				t.Errorf("%s: expected a value in [0,1] for %v, but got %v", name, v, result.R)
+			}
+			if result.R < previous {
+				t.Errorf("%s: expected a monotonic curve, but got %v after %v", name, result.R, previous)
+			}
+			previous = result.R
+		}
+
+		if black := p.Apply(clr.Black); black != clr.Black {
+			t.Errorf("%s: expected black for black, but got %v", name, black)
+		}
+	}
+}
+
+func TestExposure(t *testing.T) {
+	cases := []struct {
+		settings Settings
+		expected float64
+	}{
+		{Settings{ToneMapper: Clamp}, 1.0},
+		{Settings{EV: 1, ToneMapper: Clamp}, 2.0},
+		{Settings{EV: -2, ToneMapper: Clamp}, 0.25},
+		{Settings{ISO: 100, Shutter: 1.0 / 128.0, FStop: 8, ToneMapper: Clamp}, 1.0},
+		{Settings{ISO: 200, Shutter: 1.0 / 128.0, FStop: 8, ToneMapper: Clamp}, 2.0},
+		{Settings{ISO: 100, Shutter: 1.0 / 128.0, FStop: 16, ToneMapper: Clamp}, 0.25},
+		{Settings{ISO: 100, Shutter: 1.0 / 128.0, FStop: 16, EV: 2, ToneMapper: Clamp}, 1.0},
+	}
+
+	for _, tc := range cases {
+		if result := NewPipeline(tc.settings).Exposure(); math.Abs(result-tc.expected) > 1e-9 {
+			t.Errorf("Expected exposure %v for %+v, but got %v", tc.expected, tc.settings, result)
+		}
+	}
+}
+
+func TestWhiteBalance(t *testing.T) {
+	// the white of sRGB (D65, about 6504K) is left unchanged
+	p := NewPipeline(Settings{WhiteBalance: 6504, ToneMapper: Clamp})
+	if result := p.Linear(clr.White); math.Abs(result.R-1) > 0.01 || math.Abs(result.G-1) > 0.01 || math.Abs(result.B-1) > 0.01 {
+		t.Errorf("Expected white to stay white, but got %v", result)
+	}
+
+	// balancing for a warm light makes the image cooler
+	p = NewPipeline(Settings{WhiteBalance: 3000, ToneMapper: Clamp})
+	if result := p.Linear(clr.White); result.B <= result.R {
+		t.Errorf("Expected white to turn blue, but got %v", result)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalid := []Settings{
+		{ToneMapper: "gamma"},
+		{ISO: 100, ToneMapper: Clamp},
+		{ISO: 100, Shutter: -1, FStop: 8, ToneMapper: Clamp},
+		{WhiteBalance: 1000, ToneMapper: Clamp},
+	}
+	for _, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("Expected an error for %+v", s)
+		}
+	}
+
+	valid := DefaultSettings()
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected the default settings to be valid, but got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package tonemap
+
+import (
+	clr "github.com/ath0m/DistributedRaytracer/agent/engine/color"
+)
+
+var identity = [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+var (
+	srgbToXYZ = [3][3]float64{
+		{0.4124564, 0.3575761, 0.1804375},
+		{0.2126729, 0.7151522, 0.0721750},
+		{0.0193339, 0.1191920, 0.9503041},
+	}
+	xyzToSRGB = [3][3]float64{
+		{3.2404542, -1.5371385, -0.4985314},
+		{-0.9692660, 1.8760108, 0.0415560},
+		{0.0556434, -0.2040259, 1.0572252},
+	}
+	bradford = [3][3]float64{
+		{0.8951, 0.2664, -0.1614},
+		{-0.7502, 1.7135, 0.0367},
+		{0.0389, -0.0685, 1.0296},
+	}
+	bradfordInverse = [3][3]float64{
+		{0.9869929, -0.1470543, 0.1599627},
+		{0.4323053, 0.5183603, 0.0492912},
+		{-0.0085287, 0.0400428, 0.9684867},
+	}
+	// white point of sRGB (D65) in XYZ
+	d65 = [3]float64{0.95047, 1.0, 1.08883}
+)
+
+func multiply(m *[3][3]float64, c clr.Color) clr.Color {
+	return clr.Color{
+		R: m[0][0]*c.R + m[0][1]*c.G + m[0][2]*c.B,
+		G: m[1][0]*c.R + m[1][1]*c.G + m[1][2]*c.B,
+		B: m[2][0]*c.R + m[2][1]*c.G + m[2][2]*c.B,
+	}
+}
+
+func product(a, b [3][3]float64) [3][3]float64 {
+	var res [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				res[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return res
+}
+
+// planckianLocus returns the chromaticity (x,y) of a black body at temperature (Kelvin, in [1667,25000]) using
+// the cubic spline approximation from Kim et al.
+func planckianLocus(temperature float64) (float64, float64) {
+	t := temperature
+	t2, t3 := t*t, t*t*t
+
+	var x float64
+	if t <= 4000 {
+		x = -0.2661239e9/t3 - 0.2343589e6/t2 + 0.8776956e3/t + 0.179910
+	} else {
+		x = -3.0258469e9/t3 + 2.1070379e6/t2 + 0.2226347e3/t + 0.240390
+	}
+
+	x2, x3 := x*x, x*x*x
+	var y float64
+	switch {
+	case t <= 2222:
+		y = -1.1063814*x3 - 1.34811020*x2 + 2.18555832*x - 0.20219683
+	case t <= 4000:
+		y = -0.9549476*x3 - 1.37418593*x2 + 2.09137015*x - 0.16748867
+	default:
+		y = 3.0817580*x3 - 5.87338670*x2 + 3.75112997*x - 0.37001483
+	}
+
+	return x, y
+}
+
+// daylightLocus returns the chromaticity (x,y) of the CIE daylight illuminant at temperature (Kelvin, in
+// [4000,25000])
+func daylightLocus(temperature float64) (float64, float64) {
+	t := temperature
+	t2, t3 := t*t, t*t*t
+
+	var x float64
+	if t <= 7000 {
+		x = -4.6070e9/t3 + 2.9678e6/t2 + 0.09911e3/t + 0.244063
+	} else {
+		x = -2.0064e9/t3 + 1.9018e6/t2 + 0.24748e3/t + 0.237040
+	}
+	return x, -3.0*x*x + 2.87*x - 0.275
+}
+
+// whiteBalance returns the matrix (in linear sRGB) adapting the white of a light at temperature to the white of
+// sRGB (von Kries adaptation in the Bradford cone space). Lights are daylight above 4000K (D65 being 6504K)
+// and black bodies (incandescent) below.
+func whiteBalance(temperature float64) [3][3]float64 {
+	x, y := planckianLocus(temperature)
+	if temperature >= 4000 {
+		x, y = daylightLocus(temperature)
+	}
+	source := [3]float64{x / y, 1.0, (1 - x - y) / y}
+
+	var src, dst [3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			src[i] += bradford[i][j] * source[j]
+			dst[i] += bradford[i][j] * d65[j]
+		}
+	}
+
+	scale := [3][3]float64{{dst[0] / src[0], 0, 0}, {0, dst[1] / src[1], 0}, {0, 0, dst[2] / src[2]}}
+	return product(xyzToSRGB, product(bradfordInverse, product(scale, product(bradford, srgbToXYZ))))
+}
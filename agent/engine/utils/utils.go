@@ -3,3 +3,18 @@ package utils
 type Rnd interface {
 	Float64() float64
 }
+
+// Rnd2D is implemented by generators which provide pairs of values jointly well distributed (low discrepancy
+// samplers)
+type Rnd2D interface {
+	Rnd
+	Get2D() (float64, float64)
+}
+
+// Get2D returns two random values (a pair of dimensions of the sample when rnd is a Rnd2D)
+func Get2D(rnd Rnd) (float64, float64) {
+	if r, ok := rnd.(Rnd2D); ok {
+		return r.Get2D()
+	}
+	return rnd.Float64(), rnd.Float64()
+}
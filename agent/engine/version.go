@@ -0,0 +1,4 @@
+package engine
+
+// Version is the version of the rendering engine (recorded in the metadata of the images)
+const Version = "1.0.0"
@@ -0,0 +1,124 @@
+// Package volume provides voxel grids used by heterogeneous media.
+//
+// Grids are stored on disk as raw little endian float32 values (no header), one value per voxel, x varying the
+// fastest, then y, then z (value of voxel x,y,z at index x + y*nx + z*nx*ny). The resolution is not stored in the
+// file and must be provided when loading it (this is the layout produced by numpy's ndarray.tofile on an array
+// of shape (nz, ny, nx) and dtype '<f4'). Grid files are read from the asset directory (see package assets).
+package volume
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/assets"
+)
+
+// Grid defines a voxel grid of scalar values
+type Grid struct {
+	nx, ny, nz int
+	data       []float32
+	max        float64
+}
+
+// voxels returns the number of voxels of a grid of the resolution (an error when it is invalid or its file
+// would not fit in memory)
+func voxels(nx, ny, nz int) (int, error) {
+	if nx <= 0 || ny <= 0 || nz <= 0 {
+		return 0, fmt.Errorf("invalid grid resolution %vx%vx%v", nx, ny, nz)
+	}
+	const maxVoxels = math.MaxInt / 4 // 4 bytes per voxel
+	if nx > maxVoxels/ny || nx*ny > maxVoxels/nz {
+		return 0, fmt.Errorf("grid resolution %vx%vx%v is too large", nx, ny, nz)
+	}
+	return nx * ny * nz, nil
+}
+
+// NewGrid creates a grid from its values (x varying the fastest)
+func NewGrid(nx, ny, nz int, data []float32) (*Grid, error) {
+	n, err := voxels(nx, ny, nz)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != n {
+		return nil, fmt.Errorf("grid of resolution %vx%vx%v requires %v values, got %v", nx, ny, nz, n, len(data))
+	}
+
+	grid := &Grid{nx: nx, ny: ny, nz: nz, data: data}
+	for _, d := range data {
+		if math.IsNaN(float64(d)) || math.IsInf(float64(d), 0) || d < 0 {
+			return nil, fmt.Errorf("grid values must be finite and positive, got %v", d)
+		}
+		grid.max = math.Max(grid.max, float64(d))
+	}
+	return grid, nil
+}
+
+// LoadRaw reads a raw float32 grid file of the asset directory of the given resolution
+//
+//	the size of the file is checked against the resolution before reading it
+func LoadRaw(file string, nx, ny, nz int) (*Grid, error) {
+	n, err := voxels(nx, ny, nz)
+	if err != nil {
+		return nil, err
+	}
+	f, info, err := assets.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if expected := int64(n) * 4; info.Size() != expected {
+		return nil, fmt.Errorf("%s: expected %v bytes for a %vx%vx%v grid, got %v", file, expected, nx, ny, nz, info.Size())
+	}
+
+	content := make([]byte, n*4)
+	if _, err := io.ReadFull(f, content); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	data := make([]float32, n)
+	for i := range data {
+		data[i] = math.Float32frombits(binary.LittleEndian.Uint32(content[i*4:]))
+	}
+
+	return NewGrid(nx, ny, nz, data)
+}
+
+// Resolution returns the number of voxels along each axis
+func (g *Grid) Resolution() (nx, ny, nz int) {
+	return g.nx, g.ny, g.nz
+}
+
+// Max returns the maximum value of the grid
+func (g *Grid) Max() float64 {
+	return g.max
+}
+
+func (g *Grid) voxel(x, y, z int) float64 {
+	x = min(max(x, 0), g.nx-1)
+	y = min(max(y, 0), g.ny-1)
+	z = min(max(z, 0), g.nz-1)
+	return float64(g.data[x+y*g.nx+z*g.nx*g.ny])
+}
+
+// Lookup returns the trilinearly interpolated value at (u,v,w) in [0,1]^3 (the unit cube covering the whole
+// grid, voxel values being defined at the center of each voxel)
+func (g *Grid) Lookup(u, v, w float64) float64 {
+	x := u*float64(g.nx) - 0.5
+	y := v*float64(g.ny) - 0.5
+	z := w*float64(g.nz) - 0.5
+
+	x0, y0, z0 := math.Floor(x), math.Floor(y), math.Floor(z)
+	fx, fy, fz := x-x0, y-y0, z-z0
+	ix, iy, iz := int(x0), int(y0), int(z0)
+
+	lerp := func(a, b, t float64) float64 { return a*(1-t) + b*t }
+
+	c00 := lerp(g.voxel(ix, iy, iz), g.voxel(ix+1, iy, iz), fx)
+	c10 := lerp(g.voxel(ix, iy+1, iz), g.voxel(ix+1, iy+1, iz), fx)
+	c01 := lerp(g.voxel(ix, iy, iz+1), g.voxel(ix+1, iy, iz+1), fx)
+	c11 := lerp(g.voxel(ix, iy+1, iz+1), g.voxel(ix+1, iy+1, iz+1), fx)
+
+	return lerp(lerp(c00, c10, fy), lerp(c01, c11, fy), fz)
+}
@@ -0,0 +1,109 @@
+package volume
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/assets"
+)
+
+func TestGridLookup(t *testing.T) {
+	// 2x1x1 grid: 0 on the left voxel, 1 on the right one
+	grid, err := NewGrid(2, 1, 1, []float32{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		u        float64
+		expected float64
+	}{
+		{0.0, 0.0},    // clamped to the left voxel
+		{0.25, 0.0},   // center of the left voxel
+		{0.5, 0.5},    // between both voxels
+		{0.625, 0.75}, // interpolated
+		{0.75, 1.0},   // center of the right voxel
+		{1.0, 1.0},    // clamped to the right voxel
+	}
+
+	for _, tc := range cases {
+		result := grid.Lookup(tc.u, 0.5, 0.5)
+		if math.Abs(result-tc.expected) > 1e-9 {
+			t.Errorf("Expected %v at u=%v, but got %v", tc.expected, tc.u, result)
+		}
+	}
+
+	if grid.Max() != 1.0 {
+		t.Errorf("Expected max 1.0, but got %v", grid.Max())
+	}
+}
+
+func TestNewGridErrors(t *testing.T) {
+	if _, err := NewGrid(2, 2, 2, []float32{1, 2, 3}); err == nil {
+		t.Errorf("Expected an error for a wrong number of values")
+	}
+	if _, err := NewGrid(1, 1, 1, []float32{-1}); err == nil {
+		t.Errorf("Expected an error for a negative value")
+	}
+	if _, err := NewGrid(0, 1, 1, nil); err == nil {
+		t.Errorf("Expected an error for an empty resolution")
+	}
+}
+
+func TestLoadRaw(t *testing.T) {
+	values := []float32{0.5, 1.5, 2.5, 3.5}
+	content := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(content[i*4:], math.Float32bits(v))
+	}
+
+	dir := t.TempDir()
+	assets.SetDir(dir)
+	defer assets.SetDir("assets")
+	if err := os.WriteFile(filepath.Join(dir, "grid.raw"), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	grid, err := LoadRaw("grid.raw", 2, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := grid.voxel(1, 1, 0); v != 3.5 {
+		t.Errorf("Expected 3.5, but got %v", v)
+	}
+}
+
+func TestLoadRawErrors(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "assets")
+	os.Mkdir(dir, 0o755)
+	assets.SetDir(dir)
+	defer assets.SetDir("assets")
+	os.WriteFile(filepath.Join(dir, "grid.raw"), make([]byte, 16), 0o644)
+	os.WriteFile(filepath.Join(root, "outside.raw"), make([]byte, 16), 0o644)
+	os.Symlink("/dev/zero", filepath.Join(dir, "zero.raw"))
+
+	cases := []struct {
+		name       string
+		file       string
+		nx, ny, nz int
+	}{
+		{"absolute path", filepath.Join(root, "outside.raw"), 2, 2, 1},
+		{"parent directory", "../outside.raw", 2, 2, 1},
+		{"device", "zero.raw", 2, 2, 1},
+		{"file too small", "grid.raw", 2, 2, 2},
+		{"file too large", "grid.raw", 1, 1, 1},
+		{"empty resolution", "grid.raw", 0, 2, 2},
+		{"resolution overflowing", "grid.raw", 1 << 40, 1 << 40, 1 << 40},
+		{"size overflowing", "grid.raw", 1 << 21, 1 << 21, 1 << 21},
+	}
+
+	for _, tc := range cases {
+		if _, err := LoadRaw(tc.file, tc.nx, tc.ny, tc.nz); err == nil {
+			t.Errorf("Expected an error for %v", tc.name)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 
 	"github.com/ath0m/DistributedRaytracer/agent/engine/camera"
@@ -10,27 +11,150 @@ import (
 type World struct {
 	Camera  camera.Camera `json:"camera"`
 	Objects HittableList  `json:"objects"`
+	Fog     *Fog          `json:"fog,omitempty"` // optional medium filling the world
+	Names   []string      `json:"-"`             // optional name of each object ("name" field of the object)
+}
+
+func (w World) MarshalJSON() ([]byte, error) {
+	objects := make([]json.RawMessage, len(w.Objects))
+	for i, obj := range w.Objects {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		if i < len(w.Names) && w.Names[i] != "" {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil {
+				return nil, err
+			}
+			fields["name"], _ = json.Marshal(w.Names[i])
+			if data, err = json.Marshal(fields); err != nil {
+				return nil, err
+			}
+		}
+		objects[i] = data
+	}
+
+	return json.Marshal(struct {
+		Camera  camera.Camera     `json:"camera"`
+		Objects []json.RawMessage `json:"objects"`
+		Fog     *Fog              `json:"fog,omitempty"`
+	}{
+		Camera:  w.Camera,
+		Objects: objects,
+		Fog:     w.Fog,
+	})
+}
+
+// ObjectError is the error of an object of a world definition
+type ObjectError struct {
+	Index int // of the object in the objects of the world
+	Err   error
+}
+
+func (e *ObjectError) Error() string {
+	return fmt.Sprintf("object %d: %v", e.Index, e.Err)
+}
+
+func (e *ObjectError) Unwrap() error {
+	return e.Err
 }
 
 func (w *World) UnmarshalJSON(data []byte) error {
 	aux := &struct {
-		Camera  json.RawMessage `json:"camera"`
-		Objects []Sphere        `json:"objects"`
+		Camera  json.RawMessage   `json:"camera"`
+		Objects []json.RawMessage `json:"objects"`
+		Fog     *Fog              `json:"fog"`
 	}{}
 	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
-	w.Camera = camera.UnmarshalCamera(aux.Camera)
+	c, err := camera.UnmarshalCamera(aux.Camera)
+	if err != nil {
+		return err
+	}
+	w.Camera = c
 
-	// Convert []Sphere to HittableList
 	w.Objects = HittableList{}
-	for _, obj := range aux.Objects {
+	w.Names = make([]string, len(aux.Objects))
+	for i, data := range aux.Objects {
+		obj, err := UnmarshalHittable(data)
+		if err != nil {
+			return &ObjectError{Index: i, Err: err}
+		}
 		w.Objects = append(w.Objects, obj)
+
+		var named struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(data, &named); err != nil {
+			return &ObjectError{Index: i, Err: err}
+		}
+		w.Names[i] = named.Name
+	}
+
+	if aux.Fog != nil {
+		if err := aux.Fog.Validate(); err != nil {
+			return err
+		}
 	}
+	w.Fog = aux.Fog
 
 	return nil
 }
 
+// MaterialIndices returns the index of the material of each object: objects sharing the same material
+// definition share the same index (indices are assigned in the order of the objects)
+func (w *World) MaterialIndices() []int {
+	indices := make([]int, len(w.Objects))
+	known := map[string]int{}
+	for i, obj := range w.Objects {
+		indices[i] = len(known)
+
+		var fields struct {
+			Material json.RawMessage `json:"material"`
+			Phase    json.RawMessage `json:"phase"`
+		}
+		data, err := json.Marshal(obj)
+		if err == nil {
+			err = json.Unmarshal(data, &fields)
+		}
+		key := string(fields.Material) + string(fields.Phase)
+		if err != nil || key == "" {
+			// no material definition (grid medium...): a material of its own
+			known[fmt.Sprintf("#%d", i)] = indices[i]
+			continue
+		}
+
+		if index, ok := known[key]; ok {
+			indices[i] = index
+		} else {
+			known[key] = indices[i]
+		}
+	}
+	return indices
+}
+
+// ObjectInfo describes an object of the world in the AOVs
+type ObjectInfo struct {
+	Index    int    `json:"index"`
+	Name     string `json:"name,omitempty"`
+	Material int    `json:"material"`
+}
+
+// ObjectInfos returns the index, name and material index of every object
+func (w *World) ObjectInfos() []ObjectInfo {
+	materials := w.MaterialIndices()
+	infos := make([]ObjectInfo, len(w.Objects))
+	for i := range infos {
+		infos[i] = ObjectInfo{Index: i, Material: materials[i]}
+		if i < len(w.Names) {
+			infos[i].Name = w.Names[i]
+		}
+	}
+	return infos
+}
+
 func LoadWorld(file string) (*World, error) {
 	content, err := os.ReadFile(file)
 	if err != nil {
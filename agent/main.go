@@ -1,9 +1,204 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/assets"
 	"github.com/ath0m/DistributedRaytracer/agent/server"
 )
 
+const usage = `Usage:
+  agent [serve] [flags]         start the render server (see agent serve -h)
+  agent render [flags]          render a world to a file (see agent render -h)
+  agent metadata FILE           print the metadata of a rendered file
+  agent rerender [-o OUT] FILE  render a file again with the options of its metadata
+`
+
 func main() {
-	server.Start()
+	command, args := "serve", os.Args[1:]
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command, args = os.Args[1], os.Args[2:]
+	}
+
+	var err error
+	switch command {
+	case "serve":
+		err = serve(args)
+	case "render":
+		err = render(args)
+	case "metadata":
+		err = printMetadata(args)
+	case "rerender":
+		err = rerender(args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// serve starts the server with the configuration of the flags, environment and config file
+func serve(args []string) error {
+	config, err := server.LoadConfig(args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	return server.Start(config)
+}
+
+// render renders the world file described by the flags, printing the progress of the lines
+func render(args []string) error {
+	defaults := server.DefaultRenderOptions(engine.World{})
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	world := flags.String("world", "assets/world.json", "world file")
+	assetDir := flags.String("assets", assets.Dir(), "directory of the files of the world (textures, grids...)")
+	width := flags.Int("width", defaults.Width, "width in pixels")
+	height := flags.Int("height", defaults.Height, "height in pixels")
+	samples := flags.Int("samples", defaults.RaysPerPixel, "rays per pixel")
+	seed := flags.Int64("seed", defaults.Seed, "seed of the sampler")
+	threads := flags.Int("threads", runtime.NumCPU(), "goroutines rendering the lines")
+	output := flags.String("o", "output.png", "output file (the format follows the extension)")
+	flags.Parse(args)
+
+	if *width <= 0 || *height <= 0 || *samples <= 0 || *threads <= 0 {
+		return fmt.Errorf("width, height, samples and threads must be positive")
+	}
+	format, err := server.FormatOf(*output)
+	if err != nil {
+		return err
+	}
+	assets.SetDir(*assetDir)
+	loaded, err := engine.LoadWorld(*world)
+	if err != nil {
+		return err
+	}
+
+	options := server.DefaultRenderOptions(*loaded)
+	options.Width, options.Height, options.RaysPerPixel, options.Seed = *width, *height, *samples, *seed
+	options.Threads = *threads
+	options.Progress = progressPrinter()
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = writeFile(*output, func(w io.Writer) error {
+		return server.Render(w, &options, format)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved %s in %v.\n", *output, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// progressPrinter returns a function printing the percentage of lines rendered on a single line of the terminal
+// (the lines are reported concurrently, possibly out of order)
+func progressPrinter() func(lines, total int) {
+	lock := sync.Mutex{}
+	printed := 0
+	return func(lines, total int) {
+		lock.Lock()
+		defer lock.Unlock()
+		if lines <= printed {
+			return
+		}
+		printed = lines
+		fmt.Fprintf(os.Stderr, "\rRendering %3d%% (%v/%v lines)", 100*lines/total, lines, total)
+		if lines == total {
+			fmt.Fprintln(os.Stderr)
+			printed = 0 // next frame
+		}
+	}
+}
+
+// printMetadata prints the metadata of the file as JSON
+func printMetadata(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("metadata requires a file")
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	metadata, err := server.ReadMetadata(data)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(metadata)
+}
+
+// rerender renders the file again in the same format (to the output, by default the file name prefixed with
+// rerender-)
+func rerender(args []string) error {
+	flags := flag.NewFlagSet("rerender", flag.ExitOnError)
+	output := flags.String("o", "", "output file")
+	assetDir := flags.String("assets", assets.Dir(), "directory of the files of the world (textures, grids...)")
+	flags.Parse(args)
+	assets.SetDir(*assetDir)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("rerender requires a file")
+	}
+	file := flags.Arg(0)
+	if *output == "" {
+		*output = filepath.Join(filepath.Dir(file), "rerender-"+filepath.Base(file))
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	options, err := server.RerenderOptions(data)
+	if err != nil {
+		return err
+	}
+	if err := options.Validate(); err != nil {
+		return err
+	}
+
+	return writeFile(*output, func(w io.Writer) error {
+		return server.Render(w, options, options.Format)
+	})
+}
+
+// writeFile writes the file with write: to a temporary file of its directory, renamed once complete (a failed
+// render leaves neither a truncated file nor the temporary one, and keeps the file it would have replaced)
+func writeFile(file string, write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // once renamed, fails harmlessly
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), file)
 }
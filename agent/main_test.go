@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ath0m/DistributedRaytracer/agent/server"
+)
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	world := filepath.Join(dir, "world.json")
+	data := `{
+		"camera": {"lookFrom": {"Z": 3}, "lookAt": {}, "vup": {"Y": 1}, "vfov": 40, "aperture": 0, "focusDist": 3},
+		"objects": [{"center": {}, "radius": 1, "material": {"type": "Lambertian", "albedo": {"R": 0.5, "G": 0.5, "B": 0.5}}}]
+	}`
+	if err := os.WriteFile(world, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "image.png")
+	err := render([]string{"-world", world, "-assets", dir, "-width", "8", "-height", "6", "-samples", "2", "-threads", "2", "-o", output})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 8 || size.Y != 6 {
+		t.Errorf("Expected an image of 8x6 pixels, but got %v", size)
+	}
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata, err := server.ReadMetadata(content); err != nil || metadata[server.MetadataSeed] != "2024" {
+		t.Errorf("Expected the metadata of the render, but got %v (%v)", metadata, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, ".image.png-*")); len(files) > 0 {
+		t.Errorf("Expected no temporary file, but got %v", files)
+	}
+}
+
+func TestWriteFileFailure(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "image.png")
+	if err := os.WriteFile(file, []byte("previous"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a render failing after writing part of the file
+	failure := errors.New("render failed")
+	err := writeFile(file, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected %v, but got %v", failure, err)
+	}
+	if content, err := os.ReadFile(file); err != nil || string(content) != "previous" {
+		t.Errorf("Expected the previous file, but got %q (%v)", content, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected no temporary file, but got %v", entries)
+	}
+
+	if err := writeFile(file, func(w io.Writer) error {
+		_, err := w.Write([]byte("complete"))
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(file); err != nil || string(content) != "complete" {
+		t.Errorf("Expected the complete file, but got %q (%v)", content, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected no temporary file, but got %v", entries)
+	}
+}
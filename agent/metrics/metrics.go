@@ -0,0 +1,254 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Kinds of metrics
+const (
+	counter   = "counter"
+	gauge     = "gauge"
+	histogram = "histogram"
+)
+
+// ContentType is the content type of the Prometheus text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Registry holds metrics and writes them in the Prometheus text format (in their order of registration)
+type Registry struct {
+	lock     sync.Mutex
+	families []*family
+}
+
+// family is a metric and its series (one per combination of the values of its labels)
+type family struct {
+	lock    sync.Mutex
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64 // upper bounds of the buckets of histograms (+Inf excluded)
+	series  map[string]*series
+	value   func() float64 // value of the gauges computed when written
+}
+
+// series is the value of a metric for the values of its labels
+//
+//	counts holds the number of observations of each bucket of histograms (not cumulative), then of +Inf
+type series struct {
+	values []string
+	value  float64
+	counts []uint64
+	sum    float64
+}
+
+// Counter is a metric which only increases
+type Counter struct{ family *family }
+
+// Gauge is a metric which goes up and down
+type Gauge struct{ family *family }
+
+// Histogram counts observations in buckets
+type Histogram struct{ family *family }
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// register adds a metric (names must be unique)
+func (r *Registry) register(f *family) *family {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, other := range r.families {
+		if other.name == f.name {
+			panic(fmt.Sprintf("metric registered twice: %s", f.name))
+		}
+	}
+	f.series = map[string]*series{}
+	r.families = append(r.families, f)
+	return f
+}
+
+// Counter registers a counter with the labels
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(&family{name: name, help: help, kind: counter, labels: labels})}
+}
+
+// Gauge registers a gauge with the labels
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(&family{name: name, help: help, kind: gauge, labels: labels})}
+}
+
+// GaugeFunc registers a gauge (without labels) whose value is computed each time the metrics are written
+func (r *Registry) GaugeFunc(name, help string, value func() float64) {
+	r.register(&family{name: name, help: help, kind: gauge, value: value})
+}
+
+// Histogram registers a histogram with the upper bounds of its buckets (increasing) and the labels
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("buckets of %s are not sorted", name))
+	}
+	return &Histogram{r.register(&family{name: name, help: help, kind: histogram, labels: labels, buckets: buckets})}
+}
+
+// ExponentialBuckets returns count buckets, the first one being start and the next ones factor times the previous
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// Add increases the counter of the label values by v (must not be negative)
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("counter %s cannot decrease", c.family.name))
+	}
+	c.family.update(values, func(s *series) { s.value += v })
+}
+
+// Inc increases the counter of the label values by 1
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Set sets the gauge of the label values
+func (g *Gauge) Set(v float64, values ...string) {
+	g.family.update(values, func(s *series) { s.value = v })
+}
+
+// Observe adds an observation to the histogram of the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.family.update(values, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(h.family.buckets)+1)
+		}
+		s.counts[sort.SearchFloat64s(h.family.buckets, v)]++
+		s.sum += v
+	})
+}
+
+// update updates the series of the label values (created on first use)
+func (f *family) update(values []string, update func(s *series)) {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		f.series[key] = s
+	}
+	update(s)
+}
+
+// WriteText writes the metrics in the Prometheus text format (the series of each metric sorted by label values)
+func (r *Registry) WriteText(w io.Writer) error {
+	r.lock.Lock()
+	families := append([]*family(nil), r.families...)
+	r.lock.Unlock()
+
+	var b strings.Builder
+	for _, f := range families {
+		f.write(&b)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteText(w)
+}
+
+// write writes the help, type and series of the metric
+func (f *family) write(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n", f.name, escape(f.help, false))
+	fmt.Fprintf(b, "# TYPE %s %s\n", f.name, f.kind)
+
+	if f.value != nil {
+		writeSample(b, f.name, nil, nil, f.value())
+		return
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != histogram {
+			writeSample(b, f.name, f.labels, s.values, s.value)
+			continue
+		}
+		labels := append(append([]string(nil), f.labels...), "le")
+		cumulative := uint64(0)
+		for i, count := range s.counts {
+			cumulative += count
+			bound := math.Inf(1)
+			if i < len(f.buckets) {
+				bound = f.buckets[i]
+			}
+			writeSample(b, f.name+"_bucket", labels, append(append([]string(nil), s.values...), formatFloat(bound)), float64(cumulative))
+		}
+		writeSample(b, f.name+"_sum", f.labels, s.values, s.sum)
+		writeSample(b, f.name+"_count", f.labels, s.values, float64(cumulative))
+	}
+}
+
+// writeSample writes a line of the text format: the name, the labels and the value
+func writeSample(b *strings.Builder, name string, labels, values []string, v float64) {
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%s=\"%s\"", label, escape(values[i], true))
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(v))
+	b.WriteByte('\n')
+}
+
+// escape escapes the backslashes and line feeds of help texts, and the double quotes of label values
+func escape(s string, quotes bool) string {
+	r := strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	if quotes {
+		r = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	}
+	return r.Replace(s)
+}
+
+// formatFloat formats a value as Prometheus does (+Inf, -Inf and NaN for the special values)
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
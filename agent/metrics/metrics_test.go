@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	renders := r.Counter("renders_total", "Renders completed.", "format")
+	queued := r.Gauge("queued", "Renders waiting.")
+	duration := r.Histogram("duration_seconds", "Render time.", []float64{1, 10}, "format")
+	r.GaugeFunc("goroutines", "Goroutines\nrunning.", func() float64 { return 3 })
+
+	renders.Inc("png")
+	renders.Add(2, "exr")
+	renders.Inc("png")
+	queued.Set(4)
+	duration.Observe(0.5, "png")
+	duration.Observe(1, "png")
+	duration.Observe(12, "png")
+
+	expected := `# HELP renders_total Renders completed.
+# TYPE renders_total counter
+renders_total{format="exr"} 2
+renders_total{format="png"} 2
+# HELP queued Renders waiting.
+# TYPE queued gauge
+queued 4
+# HELP duration_seconds Render time.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{format="png",le="1"} 2
+duration_seconds_bucket{format="png",le="10"} 2
+duration_seconds_bucket{format="png",le="+Inf"} 3
+duration_seconds_sum{format="png"} 13.5
+duration_seconds_count{format="png"} 3
+# HELP goroutines Goroutines\nrunning.
+# TYPE goroutines gauge
+goroutines 3
+`
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != expected {
+		t.Errorf("Expected %v, but got %v", expected, b.String())
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("errors_total", "Errors.", "message").Inc("a \"quoted\" \\ line\n")
+
+	var b strings.Builder
+	r.WriteText(&b)
+	expected := `errors_total{message="a \"quoted\" \\ line\n"} 1`
+	if !strings.Contains(b.String(), expected) {
+		t.Errorf("Expected %v, but got %v", expected, b.String())
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	cases := []struct {
+		v        float64
+		expected string
+	}{
+		{0, "0"},
+		{1.5, "1.5"},
+		{1e12, "1e+12"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tc := range cases {
+		if s := formatFloat(tc.v); s != tc.expected {
+			t.Errorf("Expected %v, but got %v", tc.expected, s)
+		}
+	}
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	buckets := ExponentialBuckets(0.5, 4, 3)
+	expected := []float64{0.5, 2, 8}
+	for i := range expected {
+		if buckets[i] != expected[i] {
+			t.Errorf("Expected %v, but got %v", expected, buckets)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.Gauge("up", "Up.").Set(1)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Expected %v, but got %v", ContentType, ct)
+	}
+	if !strings.Contains(w.Body.String(), "up 1\n") {
+		t.Errorf("Expected %v, but got %v", "up 1", w.Body.String())
+	}
+}
+
+func TestLabelCount(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic, but got none")
+		}
+	}()
+	NewRegistry().Counter("renders_total", "Renders.", "format").Inc()
+}
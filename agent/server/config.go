@@ -0,0 +1,235 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Config defines the agent server
+//
+//	It is loaded with LoadConfig, each setting being taken from (by order of precedence) the command line flags,
+//	the environment variables (AGENT_ followed by the name of the flag in upper case, dashes replaced by
+//	underscores), the JSON config file or the defaults.
+type Config struct {
+	Address     string     `json:"address"`     // listen address
+	TLS         TLSConfig  `json:"tls"`         // HTTPS when both files are set
+	World       string     `json:"world"`       // world file rendered when requests do not define one
+	Assets      string     `json:"assets"`      // directory of the files of the worlds (textures, grids...)
+	Defaults    Defaults   `json:"defaults"`    // options of the requests not defining them
+	Workers     int        `json:"workers"`     // goroutines rendering lines, shared by all the renders
+	Parallelism int        `json:"parallelism"` // workers of a render (unless the request defines it)
+	MaxRenders  int        `json:"maxRenders"`  // renders in progress at the same time
+	MaxQueued   int        `json:"maxQueued"`   // renders waiting for workers (others are rejected)
+	Limits      Limits     `json:"limits"`      // size of the requests
+	LogLevel    slog.Level `json:"logLevel"`    // debug, info, warn or error
+}
+
+// TLSConfig defines the certificate of the server
+type TLSConfig struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+}
+
+// Defaults defines the default options of the requests
+type Defaults struct {
+	Width        int   `json:"width"`
+	Height       int   `json:"height"`
+	RaysPerPixel int   `json:"raysperpixel"`
+	Seed         int64 `json:"seed"`
+}
+
+// Limits defines how large requests can be (0 means no limit)
+type Limits struct {
+	MaxBodyBytes    int64   `json:"maxBodyBytes"`    // size of the body
+	MaxPixels       int     `json:"maxPixels"`       // width x height
+	MaxRaysPerPixel int     `json:"maxRaysPerPixel"` // rays per pixel (maximum samples when adaptive)
+	MaxObjects      int     `json:"maxObjects"`      // objects of the world
+	MaxCost         float64 `json:"maxCost"`         // estimated cost of the render (see RenderOptions.EstimateCost)
+}
+
+// DefaultConfig returns the configuration of a server on port 8090 rendering the world of assets/world.json, with
+// a worker per CPU (all of them rendering each request by default)
+func DefaultConfig() Config {
+	return Config{
+		Address: ":8090",
+		World:   "assets/world.json",
+		Assets:  "assets",
+		Defaults: Defaults{
+			Width:        800,
+			Height:       400,
+			RaysPerPixel: 10,
+			Seed:         2024,
+		},
+		Workers:     runtime.NumCPU(),
+		Parallelism: runtime.NumCPU(),
+		MaxRenders:  runtime.NumCPU(),
+		MaxQueued:   16,
+		Limits: Limits{
+			MaxBodyBytes:    16 << 20,
+			MaxPixels:       7680 * 4320,
+			MaxRaysPerPixel: 100000,
+			MaxObjects:      100000,
+			MaxCost:         1e12,
+		},
+		LogLevel: slog.LevelInfo,
+	}
+}
+
+// flagSet defines the flags setting the fields of the config (and the config file)
+func (c *Config) flagSet(file *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.StringVar(file, "config", "", "JSON config file")
+	fs.StringVar(&c.Address, "address", c.Address, "listen address")
+	fs.StringVar(&c.TLS.CertFile, "tls-cert", c.TLS.CertFile, "certificate file (HTTPS)")
+	fs.StringVar(&c.TLS.KeyFile, "tls-key", c.TLS.KeyFile, "private key file (HTTPS)")
+	fs.StringVar(&c.World, "world", c.World, "default world file")
+	fs.StringVar(&c.Assets, "assets", c.Assets, "directory of the files of the worlds (textures, grids...)")
+	fs.IntVar(&c.Defaults.Width, "width", c.Defaults.Width, "default width in pixels")
+	fs.IntVar(&c.Defaults.Height, "height", c.Defaults.Height, "default height in pixels")
+	fs.IntVar(&c.Defaults.RaysPerPixel, "rays-per-pixel", c.Defaults.RaysPerPixel, "default rays per pixel")
+	fs.Int64Var(&c.Defaults.Seed, "seed", c.Defaults.Seed, "default seed")
+	fs.IntVar(&c.Workers, "workers", c.Workers, "goroutines rendering lines, shared by all the renders")
+	fs.IntVar(&c.Parallelism, "parallelism", c.Parallelism, "default workers of a render")
+	fs.IntVar(&c.MaxRenders, "max-renders", c.MaxRenders, "renders in progress at the same time")
+	fs.IntVar(&c.MaxQueued, "max-queued", c.MaxQueued, "renders waiting for workers")
+	fs.Int64Var(&c.Limits.MaxBodyBytes, "max-body-bytes", c.Limits.MaxBodyBytes, "maximum size of a request body")
+	fs.IntVar(&c.Limits.MaxPixels, "max-pixels", c.Limits.MaxPixels, "maximum pixels of an image")
+	fs.IntVar(&c.Limits.MaxRaysPerPixel, "max-rays-per-pixel", c.Limits.MaxRaysPerPixel, "maximum rays per pixel")
+	fs.IntVar(&c.Limits.MaxObjects, "max-objects", c.Limits.MaxObjects, "maximum objects of a world")
+	fs.Float64Var(&c.Limits.MaxCost, "max-cost", c.Limits.MaxCost, "maximum estimated cost of a render (intersection tests)")
+	fs.TextVar(&c.LogLevel, "log-level", c.LogLevel, "debug, info, warn or error")
+	return fs
+}
+
+// envName returns the environment variable of a flag
+func envName(flag string) string {
+	return "AGENT_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// LoadConfig loads the configuration from the command line arguments, the environment (getenv) and the config
+// file (-config flag or AGENT_CONFIG variable), then validates it
+func LoadConfig(args []string, getenv func(string) string) (*Config, error) {
+	config := DefaultConfig()
+	file := ""
+	fs := config.flagSet(&file)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	flags := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+
+	// start over from the defaults, then apply the settings by increasing precedence
+	config = DefaultConfig()
+	if file == "" {
+		file = getenv(envName("config"))
+	}
+	if file != "" {
+		if err := config.load(file); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if value := getenv(envName(f.Name)); value != "" && err == nil && f.Name != "config" {
+			if e := fs.Set(f.Name, value); e != nil {
+				err = fmt.Errorf("invalid %s: %w", envName(f.Name), e)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range flags {
+		if err := fs.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// load decodes the JSON config file over the config (unknown fields are errors)
+func (c *Config) load(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", file, err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("invalid config file %s: data after the configuration", file)
+	}
+	return nil
+}
+
+// Validate checks the config
+func (c *Config) Validate() error {
+	if c.Address == "" {
+		return fmt.Errorf("address is missing")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("TLS requires both a certificate and a key file")
+	}
+	for _, file := range []string{c.TLS.CertFile, c.TLS.KeyFile, c.World} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return err
+		}
+	}
+	if c.World == "" {
+		return fmt.Errorf("world is missing")
+	}
+	if info, err := os.Stat(c.Assets); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("assets is not a directory: %s", c.Assets)
+	}
+	if c.Defaults.Width <= 0 || c.Defaults.Height <= 0 || c.Defaults.RaysPerPixel <= 0 {
+		return fmt.Errorf("default width, height and rays per pixel must be positive: %vx%vx%v",
+			c.Defaults.Width, c.Defaults.Height, c.Defaults.RaysPerPixel)
+	}
+	if c.Workers < 1 {
+		return fmt.Errorf("workers must be positive: %v", c.Workers)
+	}
+	if c.Parallelism < 1 || c.Parallelism > c.Workers {
+		return fmt.Errorf("parallelism must be in [1,%v] (workers): %v", c.Workers, c.Parallelism)
+	}
+	if c.MaxRenders < 1 {
+		return fmt.Errorf("max renders must be positive: %v", c.MaxRenders)
+	}
+	if c.MaxQueued < 0 {
+		return fmt.Errorf("max queued cannot be negative: %v", c.MaxQueued)
+	}
+	l := c.Limits
+	if l.MaxBodyBytes < 0 || l.MaxPixels < 0 || l.MaxRaysPerPixel < 0 || l.MaxObjects < 0 || l.MaxCost < 0 {
+		return fmt.Errorf("limits cannot be negative")
+	}
+	if l.MaxPixels > 0 && c.Defaults.Width > l.MaxPixels/c.Defaults.Height {
+		return fmt.Errorf("default size %vx%v exceeds the maximum pixels %v", c.Defaults.Width, c.Defaults.Height, l.MaxPixels)
+	}
+	if l.MaxRaysPerPixel > 0 && c.Defaults.RaysPerPixel > l.MaxRaysPerPixel {
+		return fmt.Errorf("default rays per pixel %v exceeds the maximum %v", c.Defaults.RaysPerPixel, l.MaxRaysPerPixel)
+	}
+	return nil
+}
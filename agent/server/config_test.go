@@ -0,0 +1,160 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testConfigFiles creates a world file, an asset directory and a config file (the JSON config) in a temporary
+// directory, returning the arguments of LoadConfig pointing at them
+func testConfigFiles(t *testing.T, config string) (world, assets, file string) {
+	dir := t.TempDir()
+	world, assets, file = filepath.Join(dir, "world.json"), filepath.Join(dir, "assets"), filepath.Join(dir, "config.json")
+	if err := os.WriteFile(world, []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(assets, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return world, assets, file
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	world, assets, file := testConfigFiles(t, `{"defaults": {"width": 100, "height": 50}, "maxQueued": 3}`)
+	env := map[string]string{
+		"AGENT_WORLD":          world,
+		"AGENT_ASSETS":         assets,
+		"AGENT_CONFIG":         file,
+		"AGENT_WIDTH":          "200",
+		"AGENT_RAYS_PER_PIXEL": "7",
+		"AGENT_MAX_PIXELS":     "1000000",
+		"AGENT_LOG_LEVEL":      "debug",
+	}
+	getenv := func(name string) string { return env[name] }
+
+	config, err := LoadConfig([]string{"-width", "300", "-max-queued", "5"}, getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := DefaultConfig()
+	cases := []struct {
+		name            string
+		value, expected any
+	}{
+		{"flag over env and file", config.Defaults.Width, 300},
+		{"flag over file", config.MaxQueued, 5},
+		{"env over default", config.Defaults.RaysPerPixel, 7},
+		{"env with dashes", config.Limits.MaxPixels, 1000000},
+		{"env text", config.LogLevel.String(), "DEBUG"},
+		{"file over default", config.Defaults.Height, 50},
+		{"default", config.Defaults.Seed, defaults.Defaults.Seed},
+		{"default", config.Address, defaults.Address},
+		{"env world", config.World, world},
+	}
+	for _, tc := range cases {
+		if tc.value != tc.expected {
+			t.Errorf("Expected %v (%v), but got %v", tc.expected, tc.name, tc.value)
+		}
+	}
+
+	// without the flag, the environment, then without both the file
+	if config, err := LoadConfig(nil, getenv); err != nil || config.Defaults.Width != 200 || config.MaxQueued != 3 {
+		t.Errorf("Expected the width of the environment and the queue of the file, but got %v (%v)", config, err)
+	}
+	delete(env, "AGENT_WIDTH")
+	if config, err := LoadConfig(nil, getenv); err != nil || config.Defaults.Width != 100 {
+		t.Errorf("Expected the width of the file, but got %v (%v)", config, err)
+	}
+	delete(env, "AGENT_CONFIG")
+	if config, err := LoadConfig([]string{"-config", file}, getenv); err != nil || config.Defaults.Width != 100 {
+		t.Errorf("Expected the width of the file of the flag, but got %v (%v)", config, err)
+	}
+	if config, err := LoadConfig(nil, getenv); err != nil || config.Defaults.Width != defaults.Defaults.Width {
+		t.Errorf("Expected the default width, but got %v (%v)", config, err)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for flag, expected := range map[string]string{"width": "AGENT_WIDTH", "tls-cert": "AGENT_TLS_CERT", "max-rays-per-pixel": "AGENT_MAX_RAYS_PER_PIXEL"} {
+		if name := envName(flag); name != expected {
+			t.Errorf("Expected %v, but got %v", expected, name)
+		}
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	world, assets, file := testConfigFiles(t, `{"width": 100}`)
+	env := map[string]string{"AGENT_WORLD": world, "AGENT_ASSETS": assets}
+
+	cases := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{"unknown flag", []string{"-size", "3"}, nil},
+		{"arguments", []string{"world.json"}, nil},
+		{"invalid flag", []string{"-workers", "many"}, nil},
+		{"invalid env", nil, map[string]string{"AGENT_WORKERS": "many"}},
+		{"unknown field of the file", []string{"-config", file}, nil},
+		{"missing file", []string{"-config", file + ".missing"}, nil},
+		{"invalid setting", []string{"-workers", "0"}, nil},
+	}
+	for _, tc := range cases {
+		getenv := func(name string) string {
+			if v, ok := tc.env[name]; ok {
+				return v
+			}
+			return env[name]
+		}
+		if _, err := LoadConfig(tc.args, getenv); err == nil {
+			t.Errorf("Expected an error (%v)", tc.name)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	world, assets, _ := testConfigFiles(t, `{}`)
+	valid := func() Config {
+		c := DefaultConfig()
+		c.World, c.Assets = world, assets
+		return c
+	}
+	if c := valid(); c.Validate() != nil {
+		t.Fatalf("Expected a valid config, but got %v", c.Validate())
+	}
+
+	cases := []struct {
+		name   string
+		update func(c *Config)
+	}{
+		{"no address", func(c *Config) { c.Address = "" }},
+		{"certificate without key", func(c *Config) { c.TLS.CertFile = world }},
+		{"missing certificate", func(c *Config) { c.TLS.CertFile, c.TLS.KeyFile = world+".missing", world }},
+		{"no world", func(c *Config) { c.World = "" }},
+		{"missing world", func(c *Config) { c.World = world + ".missing" }},
+		{"missing assets", func(c *Config) { c.Assets = assets + ".missing" }},
+		{"assets file", func(c *Config) { c.Assets = world }},
+		{"zero width", func(c *Config) { c.Defaults.Width = 0 }},
+		{"negative rays per pixel", func(c *Config) { c.Defaults.RaysPerPixel = -1 }},
+		{"no workers", func(c *Config) { c.Workers = 0 }},
+		{"no parallelism", func(c *Config) { c.Parallelism = 0 }},
+		{"parallelism above workers", func(c *Config) { c.Parallelism = c.Workers + 1 }},
+		{"no renders", func(c *Config) { c.MaxRenders = 0 }},
+		{"negative queue", func(c *Config) { c.MaxQueued = -1 }},
+		{"negative limit", func(c *Config) { c.Limits.MaxCost = -1 }},
+		{"default size above the limit", func(c *Config) { c.Limits.MaxPixels = 100 }},
+		{"default size overflowing", func(c *Config) { c.Defaults.Width, c.Defaults.Height = 1<<32, 1<<32 }},
+		{"default rays above the limit", func(c *Config) { c.Limits.MaxRaysPerPixel = 1 }},
+	}
+	for _, tc := range cases {
+		c := valid()
+		tc.update(&c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("Expected an error (%v)", tc.name)
+		}
+	}
+}
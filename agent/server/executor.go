@@ -0,0 +1,168 @@
+package server
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// executor admits the renders on a pool of workers shared by all the requests
+//
+//	A render takes as many workers as goroutines rendering its lines (its parallelism) and at most maxRunning
+//	renders run at the same time. The others wait in a bounded queue, started by decreasing priority then in
+//	their order of arrival (a render waits for the ones before it, even when it would fit in the free workers).
+//	When the queue is full, renders are rejected with an estimate of when to retry.
+type executor struct {
+	lock       sync.Mutex
+	workers    int // size of the pool
+	free       int // workers not taken by a render
+	maxRunning int
+	running    int
+	maxQueued  int
+	queue      renderQueue
+	arrivals   uint64
+	duration   time.Duration // moving average of the renders (to estimate the wait)
+}
+
+// queued is a render waiting for its workers
+type queued struct {
+	priority int
+	arrival  uint64
+	workers  int
+	ready    chan struct{} // closed once the workers are taken
+	index    int           // in the queue, -1 once started
+}
+
+// QueueFullError rejects a render when the queue is full
+type QueueFullError struct {
+	RetryAfter time.Duration // estimated wait before a render could start
+}
+
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("render queue is full, retry in %v", e.RetryAfter)
+}
+
+func newExecutor(workers, maxRunning, maxQueued int) *executor {
+	return &executor{workers: workers, free: workers, maxRunning: maxRunning, maxQueued: maxQueued}
+}
+
+// acquire waits for the workers of a render (at most the size of the pool) and returns the function releasing
+// them once the render is complete
+//
+//	it fails right away with a QueueFullError when the queue is full, or when the context is done while waiting
+func (e *executor) acquire(ctx context.Context, workers, priority int) (func(), error) {
+	workers = min(max(workers, 1), e.workers)
+
+	e.lock.Lock()
+	if len(e.queue) >= e.maxQueued && !e.fits(workers) {
+		err := &QueueFullError{RetryAfter: e.estimateWait()}
+		e.lock.Unlock()
+		return nil, err
+	}
+	r := &queued{priority: priority, arrival: e.arrivals, workers: workers, ready: make(chan struct{})}
+	e.arrivals++
+	heap.Push(&e.queue, r)
+	e.dispatch()
+	e.lock.Unlock()
+
+	select {
+	case <-r.ready:
+	case <-ctx.Done():
+		e.lock.Lock()
+		defer e.lock.Unlock()
+		if r.index >= 0 {
+			heap.Remove(&e.queue, r.index)
+			e.dispatch() // the renders after it may fit
+			return nil, ctx.Err()
+		}
+		// started in the meantime: give the workers back
+		e.free += r.workers
+		e.running--
+		e.dispatch()
+		return nil, ctx.Err()
+	}
+
+	start := time.Now()
+	return func() {
+		e.lock.Lock()
+		defer e.lock.Unlock()
+		e.release(r, start)
+	}, nil
+}
+
+// fits tells whether a render of the workers can start right away (locked)
+func (e *executor) fits(workers int) bool {
+	return len(e.queue) == 0 && e.running < e.maxRunning && e.free >= workers
+}
+
+// dispatch starts the renders at the head of the queue while their workers are free (locked)
+func (e *executor) dispatch() {
+	for len(e.queue) > 0 && e.running < e.maxRunning && e.free >= e.queue[0].workers {
+		r := heap.Pop(&e.queue).(*queued)
+		e.free -= r.workers
+		e.running++
+		close(r.ready)
+	}
+}
+
+// release gives the workers of a render started at start back to the pool (locked)
+func (e *executor) release(r *queued, start time.Time) {
+	e.free += r.workers
+	e.running--
+	if d := time.Since(start); e.duration == 0 {
+		e.duration = d
+	} else {
+		e.duration = (4*e.duration + d) / 5
+	}
+	e.dispatch()
+}
+
+// estimateWait estimates how long a render submitted now would wait: the renders in progress and queued are
+// assumed to take the average duration, running as many at a time as now (locked)
+func (e *executor) estimateWait() time.Duration {
+	batches := math.Ceil(float64(len(e.queue)+1) / float64(max(e.running, 1)))
+	return max(time.Duration(batches)*e.duration, time.Second)
+}
+
+// stats returns the number of renders queued and in progress
+func (e *executor) stats() (queued, running int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return len(e.queue), e.running
+}
+
+// renderQueue orders the queued renders by decreasing priority, then by arrival (container/heap)
+type renderQueue []*queued
+
+func (q renderQueue) Len() int { return len(q) }
+
+func (q renderQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].arrival < q[j].arrival
+}
+
+func (q renderQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *renderQueue) Push(x any) {
+	r := x.(*queued)
+	r.index = len(*q)
+	*q = append(*q, r)
+}
+
+func (q *renderQueue) Pop() any {
+	old := *q
+	r := old[len(old)-1]
+	old[len(old)-1] = nil
+	r.index = -1
+	*q = old[:len(old)-1]
+	return r
+}
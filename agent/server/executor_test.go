@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until n renders are queued by the executor
+func waitQueued(t *testing.T, e *executor, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for queued, _ := e.stats(); queued != n; queued, _ = e.stats() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %v queued renders, but got %v", n, queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// singleWorker returns the default config with a single worker and a single render queued
+func singleWorker() Config {
+	config := DefaultConfig()
+	config.Workers, config.Parallelism, config.MaxRenders, config.MaxQueued = 1, 1, 1, 1
+	return config
+}
+
+func TestExecutorOrder(t *testing.T) {
+	e := newExecutor(1, 1, 10)
+	release, err := e.acquire(context.Background(), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// started one at a time: by decreasing priority, then by arrival
+	type started struct {
+		name    string
+		release func()
+	}
+	order := make(chan started)
+	renders := []struct {
+		name     string
+		priority int
+	}{{"a", 0}, {"b", 1}, {"c", 0}, {"d", 1}, {"e", -1}, {"f", 2}}
+	for i, r := range renders {
+		go func() {
+			release, err := e.acquire(context.Background(), 1, r.priority)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			order <- started{r.name, release}
+		}()
+		waitQueued(t, e, i+1)
+	}
+
+	release()
+	for _, expected := range []string{"f", "b", "d", "a", "c", "e"} {
+		s := <-order
+		if s.name != expected {
+			t.Errorf("Expected %v, but got %v", expected, s.name)
+		}
+		if _, running := e.stats(); running != 1 {
+			t.Errorf("Expected a single render in progress, but got %v", running)
+		}
+		s.release()
+	}
+	if queued, running := e.stats(); queued != 0 || running != 0 {
+		t.Errorf("Expected an idle executor, but got %v queued and %v running", queued, running)
+	}
+}
+
+func TestExecutorWorkers(t *testing.T) {
+	e := newExecutor(4, 4, 10)
+	first, err := e.acquire(context.Background(), 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the render taking the whole pool waits, and the next one waits behind it even though a worker is free
+	started := make(chan int, 2)
+	for i, workers := range []int{10, 1} {
+		go func() {
+			release, err := e.acquire(context.Background(), workers, 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			started <- workers
+			release()
+		}()
+		waitQueued(t, e, i+1)
+	}
+	first()
+	for _, expected := range []int{10, 1} {
+		if workers := <-started; workers != expected {
+			t.Errorf("Expected %v, but got %v", expected, workers)
+		}
+	}
+}
+
+func TestExecutorQueueFull(t *testing.T) {
+	e := newExecutor(1, 1, 1)
+	release, err := e.acquire(context.Background(), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.acquire(ctx, 1, 0)
+	waitQueued(t, e, 1)
+
+	_, err = e.acquire(context.Background(), 1, 10)
+	var full *QueueFullError
+	if !errors.As(err, &full) {
+		t.Fatalf("Expected a QueueFullError, but got %v", err)
+	}
+	if full.RetryAfter < time.Second {
+		t.Errorf("Expected to retry after a second at least, but got %v", full.RetryAfter)
+	}
+
+	// the server answers 429 with the estimate
+	s := testServer(t, singleWorker())
+	s.executor = e
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/render", strings.NewReader(`{"width": 4, "height": 4}`)))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected %v, but got %v", http.StatusTooManyRequests, w.Code)
+	}
+	if retry := w.Header().Get("Retry-After"); retry != "1" {
+		t.Errorf("Expected Retry-After 1, but got %q", retry)
+	}
+}
+
+func TestExecutorCancel(t *testing.T) {
+	e := newExecutor(1, 1, 10)
+	release, err := e.acquire(context.Background(), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a canceled render leaves the queue, the ones after it still start
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error)
+	go func() {
+		_, err := e.acquire(ctx, 1, 1)
+		canceled <- err
+	}()
+	waitQueued(t, e, 1)
+	next := make(chan func())
+	go func() {
+		release, err := e.acquire(context.Background(), 1, 0)
+		if err != nil {
+			t.Error(err)
+		}
+		next <- release
+	}()
+	waitQueued(t, e, 2)
+
+	cancel()
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, but got %v", context.Canceled, err)
+	}
+	waitQueued(t, e, 1)
+	release()
+	(<-next)()
+	if queued, running := e.stats(); queued != 0 || running != 0 {
+		t.Errorf("Expected an idle executor, but got %v queued and %v running", queued, running)
+	}
+}
+
+func TestServeRenderCanceled(t *testing.T) {
+	cases := []struct {
+		timeout time.Duration // canceled once queued when 0
+		status  int
+	}{
+		{0, statusClientClosedRequest},
+		{10 * time.Millisecond, http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range cases {
+		s := testServer(t, singleWorker())
+		release, err := s.executor.acquire(context.Background(), 1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if tc.timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), tc.timeout)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/render", strings.NewReader(`{"width": 4, "height": 4}`)).WithContext(ctx)
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			s.Handler().ServeHTTP(w, req)
+			close(done)
+		}()
+		if tc.timeout == 0 {
+			waitQueued(t, s.executor, 1)
+			cancel()
+		}
+		<-done
+		cancel()
+		release()
+
+		if w.Code != tc.status {
+			t.Errorf("Expected %v, but got %v", tc.status, w.Code)
+		}
+		// the metrics record the status instead of a 200
+		metrics := httptest.NewRecorder()
+		s.Handler().ServeHTTP(metrics, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		sample := `raytracer_requests_total{handler="render",code="` + strconv.Itoa(tc.status) + `"} 1`
+		if !strings.Contains(metrics.Body.String(), sample) {
+			t.Errorf("Expected the sample %v, but got %v", sample, metrics.Body.String())
+		}
+	}
+}
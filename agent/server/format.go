@@ -0,0 +1,273 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"maps"
+	"math"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/imageio"
+)
+
+// Format defines the file format of the rendered image
+type Format string
+
+const (
+	PNG  Format = "png"
+	JPEG Format = "jpeg"
+	TIFF Format = "tiff" // 16 bits per channel
+	PPM  Format = "ppm"  // binary portable pixmap
+	PFM  Format = "pfm"  // portable float map (linear)
+	GIF  Format = "gif"  // animated when rendering an animation
+	HDR  Format = "hdr"  // Radiance RGBE
+	EXR  Format = "exr"  // OpenEXR
+	ZIP  Format = "zip"  // PNG of the image and of each AOV
+	AVI  Format = "avi"  // Motion JPEG video
+	Y4M  Format = "y4m"  // YUV4MPEG2 uncompressed video
+)
+
+// contentTypes maps the formats to their media type
+var contentTypes = map[Format]string{
+	PNG:  "image/png",
+	JPEG: "image/jpeg",
+	TIFF: "image/tiff",
+	PPM:  "image/x-portable-pixmap",
+	PFM:  "image/x-portable-floatmap",
+	GIF:  "image/gif",
+	HDR:  "image/vnd.radiance",
+	EXR:  "image/x-exr",
+	ZIP:  "application/zip",
+	AVI:  "video/x-msvideo",
+	Y4M:  "video/x-yuv4mpeg",
+}
+
+// video tells whether the format is a video (see streamVideo)
+func (f Format) video() bool {
+	return f == AVI || f == Y4M
+}
+
+// extensions maps the file extensions to their format
+var extensions = map[string]Format{
+	".png":  PNG,
+	".jpg":  JPEG,
+	".jpeg": JPEG,
+	".tif":  TIFF,
+	".tiff": TIFF,
+	".ppm":  PPM,
+	".pfm":  PFM,
+	".gif":  GIF,
+	".hdr":  HDR,
+	".exr":  EXR,
+	".zip":  ZIP,
+	".avi":  AVI,
+	".y4m":  Y4M,
+}
+
+// FormatOf returns the format of a file from its extension
+func FormatOf(file string) (Format, error) {
+	format, ok := extensions[strings.ToLower(filepath.Ext(file))]
+	if !ok {
+		return "", fmt.Errorf("unknown format of %s", file)
+	}
+	return format, nil
+}
+
+// negotiateFormat picks the format of the response: the format option when set, otherwise the supported media
+// type of the Accept header with the highest weight (q value, the first one listed on ties), fallback by default
+//
+//	the wildcards (*/* and image/*...) weigh the fallback, the media types listed taking precedence over them. The
+//	request is not acceptable (406) when the header refuses (q=0) every supported media type it matches
+func negotiateFormat(option Format, accept string, fallback Format) (Format, error) {
+	if option != "" {
+		if _, ok := contentTypes[option]; !ok {
+			return "", invalid("format", fmt.Errorf("unknown format: %s", option))
+		}
+		return option, nil
+	}
+
+	var formats []Format // supported, in the order of the header
+	weights := map[Format]float64{}
+	wildcard := -1.0 // weight of the wildcards matching the fallback (-1 when there are none)
+	fallbackType, _, _ := strings.Cut(contentTypes[fallback], "/")
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		if mediaType == "*/*" || mediaType == fallbackType+"/*" {
+			wildcard = max(wildcard, q)
+			continue
+		}
+		for format, contentType := range contentTypes {
+			if _, ok := weights[format]; !ok && mediaType == contentType {
+				formats = append(formats, format)
+				weights[format] = q
+			}
+		}
+	}
+
+	best, bestWeight := Format(""), 0.0
+	for _, format := range formats {
+		if weights[format] > bestWeight {
+			best, bestWeight = format, weights[format]
+		}
+	}
+	if _, listed := weights[fallback]; !listed && wildcard > bestWeight {
+		best = fallback
+	}
+	switch {
+	case best != "":
+		return best, nil
+	case len(formats) > 0 || wildcard == 0:
+		return "", &RequestError{Status: http.StatusNotAcceptable, Errors: []FieldError{{Message: fmt.Sprintf("no accepted media type is supported: %s", accept)}}}
+	default:
+		// no supported media type (or no header)
+		return fallback, nil
+	}
+}
+
+// encode writes the frames in the requested format (with their AOVs for multi-layer formats), only GIF holding
+// more than the first frame
+//
+//	the metadata is embedded in the formats supporting it (png, jpeg, tiff, hdr, exr and zip)
+func encode(frames []*engine.Framebuffer, format Format, options *RenderOptions, metadata map[string]string) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	fb := frames[0]
+	var err error
+	switch format {
+	case JPEG:
+		err = imageio.WriteJPEG(buf, engine.CreateImage(fb), options.JPEG, metadata)
+	case TIFF:
+		err = imageio.WriteTIFF(buf, engine.CreateImage16(fb), metadata)
+	case PPM:
+		err = imageio.WritePPM(buf, engine.CreateImage(fb))
+	case PFM:
+		err = imageio.WritePFM(buf, fb.Image())
+	case GIF:
+		images := make([]*image.NRGBA, len(frames))
+		for i, frame := range frames {
+			images[i] = engine.CreateImage(frame)
+		}
+		fps := engine.NewAnimation().FPS
+		if options.Animation != nil {
+			fps = options.Animation.FPS
+		}
+		err = imageio.WriteGIF(buf, images, int(math.Round(100/fps)), options.GIF)
+	case HDR:
+		img := fb.Image()
+		img.Attributes = metadata
+		err = imageio.WriteHDR(buf, img)
+	case EXR:
+		img := fb.Image()
+		if options.Alpha == imageio.Straight {
+			img.Unpremultiply()
+		}
+		img.Attributes = maps.Clone(metadata)
+		if len(fb.AOVs) > 0 {
+			objects, err := json.Marshal(options.World.ObjectInfos())
+			if err != nil {
+				return nil, err
+			}
+			img.Attributes["objects"] = string(objects)
+		}
+		err = imageio.WriteEXR(buf, img, options.EXR)
+	case ZIP:
+		err = writeZip(buf, fb, options, metadata)
+	default:
+		err = imageio.WritePNG(buf, engine.CreateImage(fb), metadata)
+	}
+	return buf, err
+}
+
+// streamVideo renders the frames of the animation in order, writing each of them as soon as it is rendered (and
+// flushing the writer when it is an http.Flusher)
+func streamVideo(w io.Writer, format Format, animation *engine.Animation, options *RenderOptions, render func(frame int) (*engine.Framebuffer, error)) error {
+	sequence := animation.Sequence()
+	flusher, _ := w.(http.Flusher)
+
+	var video imageio.VideoWriter
+	for i, f := range sequence {
+		fb, err := render(f)
+		if err != nil {
+			return fmt.Errorf("frame %v: %w", i, err)
+		}
+
+		if video == nil {
+			if format == AVI {
+				video = imageio.NewAVIWriter(w, fb.Width, fb.Height, len(sequence), animation.FPS, options.JPEG)
+			} else {
+				video = imageio.NewY4MWriter(w, fb.Width, fb.Height, animation.FPS)
+			}
+		}
+		err = video.WriteFrame(engine.CreateImage(fb))
+		if err != nil {
+			return fmt.Errorf("frame %v: %w", i, err)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	return video.Close()
+}
+
+// writeZip writes an archive with beauty.png, one PNG per AOV, objects.json (index, name and material index of
+// the objects) and metadata.json
+func writeZip(buf *bytes.Buffer, fb *engine.Framebuffer, options *RenderOptions, metadata map[string]string) error {
+	zw := zip.NewWriter(buf)
+
+	f, err := zw.Create("beauty.png")
+	if err != nil {
+		return err
+	}
+	if err := imageio.WritePNG(f, engine.CreateImage(fb), metadata); err != nil {
+		return err
+	}
+
+	for _, aov := range options.AOVs {
+		f, err := zw.Create(string(aov) + ".png")
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, engine.CreateAOVImage(fb, aov)); err != nil {
+			return err
+		}
+	}
+
+	f, err = zw.Create("objects.json")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(options.World.ObjectInfos()); err != nil {
+		return err
+	}
+
+	f, err = zw.Create(imageio.MetadataFile)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(metadata); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/filter"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/imageio"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/sampler"
+)
+
+// Keys of the metadata embedded in the rendered files
+const (
+	MetadataSoftware      = "Software"      // name and version of the renderer
+	MetadataVersion       = "EngineVersion" // version of the engine
+	MetadataRenderOptions = "RenderOptions" // JSON of the options (with the world) rendering the exact same image
+	MetadataWorldHash     = "WorldHash"     // SHA-256 of the JSON of the world
+	MetadataSeed          = "Seed"          // seed of the sampler
+	MetadataFormat        = "Format"        // format of the file
+	MetadataIntegrator    = "Integrator"    // JSON of the integrator settings
+	MetadataStatistics    = "Statistics"    // JSON of the render time and rays per pixel
+)
+
+// integratorSettings describes how the light transport is sampled
+type integratorSettings struct {
+	Type         string           `json:"type"` // path or spectral (path tracing of wavelengths)
+	RaysPerPixel int              `json:"raysperpixel"`
+	Adaptive     *engine.Adaptive `json:"adaptive,omitempty"`
+	Sampler      sampler.Kind     `json:"sampler"`
+	Filter       filter.Settings  `json:"filter"`
+}
+
+// renderStatistics describes the render of the (first) frame
+type renderStatistics struct {
+	RenderTime      string  `json:"renderTime"`
+	Rays            int     `json:"rays"`
+	RaysPerPixel    float64 `json:"raysPerPixel"` // mean
+	MinRaysPerPixel int     `json:"minRaysPerPixel"`
+	MaxRaysPerPixel int     `json:"maxRaysPerPixel"`
+}
+
+// integrator names the light transport of the options
+func (o *RenderOptions) integrator() string {
+	if o.Spectral {
+		return "spectral"
+	}
+	return "path"
+}
+
+// metadata returns the metadata of the framebuffer rendered with the options in the format: what is needed to
+// render it again and how it was rendered
+func (o *RenderOptions) metadata(format Format, fb *engine.Framebuffer) (map[string]string, error) {
+	recorded := *o
+	recorded.Format = format
+	options, err := json.Marshal(recorded)
+	if err != nil {
+		return nil, err
+	}
+	world, err := json.Marshal(o.World)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(world)
+
+	integrator, err := json.Marshal(integratorSettings{
+		Type:         o.integrator(),
+		RaysPerPixel: o.RaysPerPixel,
+		Adaptive:     o.Adaptive,
+		Sampler:      o.Sampler,
+		Filter:       o.Filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := fb.Statistics
+	statistics, err := json.Marshal(renderStatistics{
+		RenderTime:      s.Duration.String(),
+		Rays:            s.Rays,
+		RaysPerPixel:    s.RaysPerPixel(fb.Width * fb.Height),
+		MinRaysPerPixel: s.MinRaysPerPixel,
+		MaxRaysPerPixel: s.MaxRaysPerPixel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		MetadataSoftware:      "DistributedRaytracer " + engine.Version,
+		MetadataVersion:       engine.Version,
+		MetadataRenderOptions: string(options),
+		MetadataWorldHash:     hex.EncodeToString(hash[:]),
+		MetadataSeed:          strconv.FormatInt(o.Seed, 10),
+		MetadataFormat:        string(format),
+		MetadataIntegrator:    string(integrator),
+		MetadataStatistics:    string(statistics),
+	}, nil
+}
+
+// ReadMetadata returns the metadata embedded in a rendered file
+func ReadMetadata(data []byte) (map[string]string, error) {
+	return imageio.ReadMetadata(data)
+}
+
+// RerenderOptions returns the options recorded in the metadata of a rendered file (rendering the same image, in
+// the same format)
+func RerenderOptions(data []byte) (*RenderOptions, error) {
+	metadata, err := ReadMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+	recorded, ok := metadata[MetadataRenderOptions]
+	if !ok {
+		return nil, fmt.Errorf("no render options in the metadata")
+	}
+	if version := metadata[MetadataVersion]; version != engine.Version {
+		slog.Warn("Rendering with another engine.", "recorded", version, "engine", engine.Version)
+	}
+
+	options := DefaultRenderOptions(engine.World{})
+	if err := DecodeRenderOptions([]byte(recorded), &options); err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
+
+// handleMetadata returns the metadata of the file posted as a JSON object
+func (s *Server) handleMetadata(w http.ResponseWriter, req *http.Request) {
+	data, err := io.ReadAll(s.readBody(w, req))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	metadata, err := ReadMetadata(data)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metadata)
+}
+
+// handleRerender renders the file posted again, with the options recorded in its metadata
+func (s *Server) handleRerender(w http.ResponseWriter, req *http.Request) {
+	data, err := io.ReadAll(s.readBody(w, req))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	options, err := RerenderOptions(data)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.serveRender(w, req, options)
+}
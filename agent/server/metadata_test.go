@@ -0,0 +1,150 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine/imageio"
+)
+
+// post posts the body to the path of the server, failing unless the response is a 200
+func post(t *testing.T, s *Server, path string, body []byte) []byte {
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected %v from %v, but got %v: %v", http.StatusOK, path, w.Code, w.Body.String())
+	}
+	return w.Body.Bytes()
+}
+
+// pngPixels decodes a PNG (its metadata differs between renders)
+func pngPixels(t *testing.T, data []byte) image.Image {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// exrBlocks returns the scanline blocks of an OpenEXR file (its header and offsets differ between renders)
+func exrBlocks(t *testing.T, data []byte) [][]byte {
+	le := binary.LittleEndian
+	offset := 8
+	for data[offset] != 0 {
+		// name, type, size and value of the attribute
+		offset += bytes.IndexByte(data[offset:], 0) + 1
+		offset += bytes.IndexByte(data[offset:], 0) + 1
+		offset += 4 + int(le.Uint32(data[offset:]))
+	}
+	table := offset + 1
+	first := int(le.Uint64(data[table:]))
+	var blocks [][]byte
+	for i := table; i < first; i += 8 {
+		block := data[le.Uint64(data[i:]):]
+		blocks = append(blocks, block[:8+le.Uint32(block[4:])])
+	}
+	if len(blocks) == 0 {
+		t.Fatalf("Expected scanline blocks")
+	}
+	return blocks
+}
+
+// zipEntries returns the files of an archive but the metadata, the PNG files decoded
+func zipEntries(t *testing.T, data []byte) map[string]any {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]any{}
+	for _, f := range zr.File {
+		if f.Name == imageio.MetadataFile {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[f.Name] = content
+		if strings.HasSuffix(f.Name, ".png") {
+			entries[f.Name] = pngPixels(t, content)
+		}
+	}
+	return entries
+}
+
+func TestRerender(t *testing.T) {
+	s := testServer(t, DefaultConfig())
+	cases := []struct {
+		request  string
+		contents func(t *testing.T, data []byte) any
+	}{
+		{`{"width": 8, "height": 6, "raysperpixel": 2, "format": "png", "tonemapping": {"ev": 0.5}}`,
+			func(t *testing.T, data []byte) any { return pngPixels(t, data) }},
+		{`{"width": 8, "height": 6, "raysperpixel": 2, "format": "exr", "aovs": ["depth", "object"], "sampler": "sobol"}`,
+			func(t *testing.T, data []byte) any { return exrBlocks(t, data) }},
+		{`{"width": 8, "height": 6, "raysperpixel": 2, "format": "zip", "aovs": ["normal"], "seed": 7}`,
+			func(t *testing.T, data []byte) any { return zipEntries(t, data) }},
+	}
+
+	for _, tc := range cases {
+		rendered := post(t, s, "/render", []byte(tc.request))
+		rerendered := post(t, s, "/rerender", rendered)
+		if !reflect.DeepEqual(tc.contents(t, rendered), tc.contents(t, rerendered)) {
+			t.Errorf("Expected the same image rendered again (%v)", tc.request)
+		}
+
+		var metadata [2]map[string]string
+		for i, data := range [][]byte{rendered, rerendered} {
+			m, err := ReadMetadata(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			delete(m, MetadataStatistics) // the render time differs
+			metadata[i] = m
+		}
+		if metadata[0][MetadataWorldHash] == "" || metadata[0][MetadataWorldHash] != metadata[1][MetadataWorldHash] {
+			t.Errorf("Expected the world hash %v, but got %v", metadata[0][MetadataWorldHash], metadata[1][MetadataWorldHash])
+		}
+		if !reflect.DeepEqual(metadata[0], metadata[1]) {
+			t.Errorf("Expected the metadata %v, but got %v", metadata[0], metadata[1])
+		}
+	}
+
+	// the metadata of the file is returned as is
+	rendered := post(t, s, "/render", []byte(`{"width": 4, "height": 4, "raysperpixel": 1}`))
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metadata", bytes.NewReader(rendered)))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"`+MetadataWorldHash+`"`) {
+		t.Errorf("Expected the metadata, but got %v: %v", w.Code, w.Body.String())
+	}
+}
+
+func TestRerenderErrors(t *testing.T) {
+	s := testServer(t, DefaultConfig())
+	plain := &bytes.Buffer{}
+	if err := png.Encode(plain, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range [][]byte{[]byte("not an image"), plain.Bytes()} {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/rerender", bytes.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %v, but got %v: %v", http.StatusBadRequest, w.Code, w.Body.String())
+		}
+	}
+}
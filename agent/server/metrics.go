@@ -0,0 +1,133 @@
+package server
+
+import (
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine"
+	"github.com/ath0m/DistributedRaytracer/agent/metrics"
+)
+
+// serverMetrics are the metrics of the server, served by /metrics in the Prometheus text format
+//
+//	the metrics of the renders are labelled by integrator (path or spectral) and format, the ones of the
+//	requests by handler and status code
+type serverMetrics struct {
+	registry          *metrics.Registry
+	requests          *metrics.Counter
+	errors            *metrics.Counter
+	renders           *metrics.Counter
+	renderErrors      *metrics.Counter
+	duration          *metrics.Histogram
+	pixels            *metrics.Counter
+	samples           *metrics.Counter
+	rays              *metrics.Counter
+	samplesPerSecond  *metrics.Gauge
+	raysPerSecond     *metrics.Gauge
+	intersectionTests *metrics.Histogram
+}
+
+func newServerMetrics(executor *executor) *serverMetrics {
+	r := metrics.NewRegistry()
+	render := []string{"integrator", "format"}
+	m := &serverMetrics{
+		registry:          r,
+		requests:          r.Counter("raytracer_requests_total", "Requests answered.", "handler", "code"),
+		errors:            r.Counter("raytracer_errors_total", "Requests answered with an error status.", "handler", "code"),
+		renders:           r.Counter("raytracer_renders_total", "Renders completed.", render...),
+		renderErrors:      r.Counter("raytracer_render_errors_total", "Renders which failed (or were aborted while streamed).", render...),
+		duration:          r.Histogram("raytracer_render_duration_seconds", "Time to render and encode a file.", metrics.ExponentialBuckets(0.1, 2, 14), render...),
+		pixels:            r.Counter("raytracer_pixels_rendered_total", "Pixels rendered (of every frame).", render...),
+		samples:           r.Counter("raytracer_samples_total", "Rays cast through the pixels.", render...),
+		rays:              r.Counter("raytracer_rays_total", "Rays traced through the world (samples and scattered rays).", render...),
+		samplesPerSecond:  r.Gauge("raytracer_samples_per_second", "Rays cast through the pixels per second by the last render.", render...),
+		raysPerSecond:     r.Gauge("raytracer_rays_per_second", "Rays traced through the world per second by the last render.", render...),
+		intersectionTests: r.Histogram("raytracer_intersection_tests_per_ray", "Intersection tests against the primitives of the world (spheres, medium boundaries, grid bounds) per ray cast through the pixels, bounces included.", metrics.ExponentialBuckets(1, 4, 10), render...),
+	}
+
+	r.GaugeFunc("raytracer_queued_renders", "Renders waiting for workers.", func() float64 {
+		queued, _ := executor.stats()
+		return float64(queued)
+	})
+	r.GaugeFunc("raytracer_active_renders", "Renders in progress.", func() float64 {
+		_, running := executor.stats()
+		return float64(running)
+	})
+	r.GaugeFunc("raytracer_memory_heap_bytes", "Bytes of the allocated heap objects.", func() float64 {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return float64(stats.HeapAlloc)
+	})
+	r.GaugeFunc("raytracer_memory_sys_bytes", "Bytes of memory obtained from the system.", func() float64 {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return float64(stats.Sys)
+	})
+	r.GaugeFunc("raytracer_goroutines", "Goroutines running.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	r.Gauge("raytracer_build_info", "Version of the engine.", "version").Set(1, engine.Version)
+	return m
+}
+
+// instrument counts the requests answered by the handler, and the errors
+func (m *serverMetrics) instrument(handler string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+		h(rw, req)
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		code := strconv.Itoa(status)
+		m.requests.Inc(handler, code)
+		if status >= http.StatusBadRequest {
+			m.errors.Inc(handler, code)
+		}
+	}
+}
+
+// renderTotals sums the statistics of the frames of a render
+type renderTotals struct {
+	duration          time.Duration
+	pixels            int
+	samples           int
+	rays              int
+	intersectionTests int
+}
+
+// add adds the statistics of a rendered frame
+func (t *renderTotals) add(fb *engine.Framebuffer) {
+	s := fb.Statistics
+	t.duration += s.Duration
+	t.pixels += fb.Width * fb.Height
+	t.samples += s.Rays
+	t.rays += s.TracedRays
+	t.intersectionTests += s.IntersectionTests
+}
+
+// observe records a render of the options in the format which took elapsed (err tells whether it failed)
+func (m *serverMetrics) observe(options *RenderOptions, format Format, elapsed time.Duration, totals *renderTotals, err error) {
+	labels := []string{options.integrator(), string(format)}
+	// the frames rendered count even when the render fails (the work is done)
+	m.pixels.Add(float64(totals.pixels), labels...)
+	m.samples.Add(float64(totals.samples), labels...)
+	m.rays.Add(float64(totals.rays), labels...)
+	if err != nil {
+		m.renderErrors.Inc(labels...)
+		return
+	}
+
+	m.renders.Inc(labels...)
+	m.duration.Observe(elapsed.Seconds(), labels...)
+	if seconds := totals.duration.Seconds(); seconds > 0 {
+		m.samplesPerSecond.Set(float64(totals.samples)/seconds, labels...)
+		m.raysPerSecond.Set(float64(totals.rays)/seconds, labels...)
+	}
+	if totals.samples > 0 {
+		m.intersectionTests.Observe(float64(totals.intersectionTests)/float64(totals.samples), labels...)
+	}
+}
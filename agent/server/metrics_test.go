@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ath0m/DistributedRaytracer/agent/metrics"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	s := testServer(t, DefaultConfig())
+	post(t, s, "/render", []byte(`{"width": 4, "height": 4, "raysperpixel": 2}`))
+	post(t, s, "/render", []byte(`{"width": 4, "height": 2, "raysperpixel": 1, "spectral": true, "format": "exr"}`))
+	for _, body := range []string{`{"width": 0}`, `{`} {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/render", strings.NewReader(body)))
+	}
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metadata", strings.NewReader("not an image")))
+
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected %v, but got %v", http.StatusOK, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != metrics.ContentType {
+		t.Errorf("Expected %v, but got %v", metrics.ContentType, contentType)
+	}
+
+	text := w.Body.String()
+	for _, sample := range []string{
+		`raytracer_renders_total{integrator="path",format="png"} 1`,
+		`raytracer_renders_total{integrator="spectral",format="exr"} 1`,
+		`raytracer_render_duration_seconds_count{integrator="path",format="png"} 1`,
+		`raytracer_render_duration_seconds_bucket{integrator="spectral",format="exr",le="+Inf"} 1`,
+		`raytracer_pixels_rendered_total{integrator="path",format="png"} 16`,
+		`raytracer_pixels_rendered_total{integrator="spectral",format="exr"} 8`,
+		`raytracer_samples_total{integrator="path",format="png"} 32`,
+		`raytracer_samples_total{integrator="spectral",format="exr"} 8`,
+		`raytracer_intersection_tests_per_ray_count{integrator="path",format="png"} 1`,
+		`raytracer_requests_total{handler="render",code="200"} 2`,
+		`raytracer_requests_total{handler="render",code="400"} 1`,
+		`raytracer_requests_total{handler="render",code="422"} 1`,
+		`raytracer_requests_total{handler="metadata",code="400"} 1`,
+		`raytracer_errors_total{handler="render",code="400"} 1`,
+		`raytracer_errors_total{handler="render",code="422"} 1`,
+		`raytracer_errors_total{handler="metadata",code="400"} 1`,
+		`raytracer_queued_renders 0`,
+		`raytracer_active_renders 0`,
+		`raytracer_build_info{version="`,
+	} {
+		if !strings.Contains(text, "\n"+sample) {
+			t.Errorf("Expected the sample %v, but got:\n%v", sample, text)
+		}
+	}
+	for _, series := range []string{"raytracer_rays_total", "raytracer_samples_per_second", "raytracer_rays_per_second"} {
+		if !strings.Contains(text, "\n"+series+`{integrator="path",format="png"} `) {
+			t.Errorf("Expected the series %v, but got:\n%v", series, text)
+		}
+	}
+	if strings.Contains(text, `raytracer_errors_total{handler="render",code="200"}`) {
+		t.Errorf("Expected no error counted for the renders answered with 200")
+	}
+}
@@ -1,74 +1,387 @@
 package server
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"image/png"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"os"
 	"runtime"
+	"strconv"
+	"time"
 
 	"github.com/ath0m/DistributedRaytracer/agent/engine"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/assets"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/camera"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/denoise"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/filter"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/imageio"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/sampler"
+	"github.com/ath0m/DistributedRaytracer/agent/engine/tonemap"
 )
 
-var defaultWorld engine.World
+// Server renders the requests of the agent
+type Server struct {
+	config   *Config
+	world    engine.World // default world
+	executor *executor
+	metrics  *serverMetrics
+}
+
+// New creates the server of the (validated) config, loading its default world (the files of the worlds are read
+// from the asset directory of the config)
+func New(config *Config) (*Server, error) {
+	assets.SetDir(config.Assets)
+	world, err := engine.LoadWorld(config.World)
+	if err != nil {
+		return nil, err
+	}
+	executor := newExecutor(config.Workers, config.MaxRenders, config.MaxQueued)
+	return &Server{config: config, world: *world, executor: executor, metrics: newServerMetrics(executor)}, nil
+}
 
 type RenderOptions struct {
-	Width        int          `json:"width"`        // width in pixel
-	Height       int          `json:"height"`       // height in pixel
-	RaysPerPixel int          `json:"raysperpixel"` // number of rays per pixel
-	Seed         int64        `json:"seed"`         // seed for random number generator
-	World        engine.World `json:"world"`        // Optional world definition
+	Width        int                 `json:"width"`           // width in pixel
+	Height       int                 `json:"height"`          // height in pixel
+	RaysPerPixel int                 `json:"raysperpixel"`    // number of rays per pixel
+	Seed         int64               `json:"seed"`            // seed for random number generator
+	World        engine.World        `json:"world"`           // Optional world definition
+	Spectral     bool                `json:"spectral"`        // render with wavelengths instead of RGB (dispersion)
+	ToneMapping  tonemap.Settings    `json:"tonemapping"`     // exposure, white balance and tone mapper
+	Format       Format              `json:"format"`          // png, jpeg, tiff, ppm, pfm, gif, hdr, exr, zip, avi or y4m (overrides the Accept header)
+	JPEG         imageio.JPEGOptions `json:"jpeg"`            // quality of JPEG files
+	GIF          imageio.GIFOptions  `json:"gif"`             // palette size and dithering of GIF files
+	EXR          imageio.EXROptions  `json:"exr"`             // compression and pixel type of OpenEXR files
+	AOVs         []engine.AOV        `json:"aovs"`            // output variables rendered along (exr or zip formats)
+	Denoise      bool                `json:"denoise"`         // filter the noise (guided by the albedo and normal)
+	DenoiseWith  denoise.Settings    `json:"denoiseSettings"` // iterations and sigmas of the denoiser
+	Adaptive     *engine.Adaptive    `json:"adaptive"`        // sample until each pixel converges (replaces raysperpixel)
+	Sampler      sampler.Kind        `json:"sampler"`         // independent, stratified, halton or sobol
+	Filter       filter.Settings     `json:"filter"`          // reconstruction filter splatting the samples
+	Animation    *engine.Animation   `json:"animation"`       // frames rendered along camera keyframes (gif, avi or y4m formats)
+	Transparent  bool                `json:"transparent"`     // transparent background (alpha is the coverage of the pixels)
+	Alpha        imageio.Alpha       `json:"alpha"`           // straight or premultiplied alpha (exr, the other formats being straight)
+
+	Priority    int `json:"priority"`    // renders of higher priority leave the queue of the server first
+	Parallelism int `json:"parallelism"` // workers of the server rendering the lines (default of the server when 0)
+
+	Threads  int                          `json:"-"` // goroutines rendering the lines (one per CPU by default)
+	Progress func(lines, total int)       `json:"-"` // optionally reports the lines rendered (see engine.WithProgress)
+	Rendered func(fb *engine.Framebuffer) `json:"-"` // optionally receives every frame once rendered (statistics...)
 }
 
-func handleRender(w http.ResponseWriter, req *http.Request) {
-	requestOptions := RenderOptions{
+// DefaultRenderOptions returns the options of a request rendering the world before its fields are decoded
+func DefaultRenderOptions(world engine.World) RenderOptions {
+	return RenderOptions{
 		Width:        800,
 		Height:       400,
 		RaysPerPixel: 10,
 		Seed:         2024,
-		World:        defaultWorld,
+		World:        world,
+		ToneMapping:  tonemap.DefaultSettings(),
+		JPEG:         imageio.DefaultJPEGOptions(),
+		GIF:          imageio.DefaultGIFOptions(),
+		EXR:          imageio.DefaultEXROptions(),
+		DenoiseWith:  denoise.DefaultSettings(),
+		Filter:       filter.DefaultSettings(),
+	}
+}
+
+// animation returns the animation of the options (a single frame by default)
+func (o *RenderOptions) animation() *engine.Animation {
+	if o.Animation != nil {
+		return o.Animation
+	}
+	return engine.NewAnimation()
+}
+
+// fallbackFormat returns the format used when neither the options nor the Accept header define one: EXR for
+// AOVs, GIF for animations, PNG otherwise
+func (o *RenderOptions) fallbackFormat() Format {
+	if len(o.AOVs) > 0 {
+		return EXR
+	} else if o.animation().Frames > 1 {
+		return GIF
+	}
+	return PNG
+}
+
+// checkFormat checks that the format can hold what the options render
+func (o *RenderOptions) checkFormat(format Format) error {
+	if len(o.AOVs) > 0 && format != EXR && format != ZIP {
+		return fmt.Errorf("AOVs require the exr or zip format, not %s", format)
+	}
+	if o.animation().Frames > 1 && format != GIF && !format.video() {
+		return fmt.Errorf("animations require the gif, avi or y4m format, not %s", format)
+	}
+	if o.Alpha == imageio.Premultiplied && format != EXR {
+		// the 8 and 16 bits formats store straight alpha
+		return fmt.Errorf("premultiplied alpha requires the exr format, not %s", format)
+	}
+	return nil
+}
+
+// defaultOptions returns the options of the requests before their fields are decoded
+func (s *Server) defaultOptions() RenderOptions {
+	options := DefaultRenderOptions(s.world)
+	d := s.config.Defaults
+	options.Width, options.Height, options.RaysPerPixel, options.Seed = d.Width, d.Height, d.RaysPerPixel, d.Seed
+	return options
+}
+
+// readBody reads the body of the request, up to the maximum size of the config
+func (s *Server) readBody(w http.ResponseWriter, req *http.Request) io.Reader {
+	if s.config.Limits.MaxBodyBytes <= 0 {
+		return req.Body
 	}
+	return http.MaxBytesReader(w, req.Body, s.config.Limits.MaxBodyBytes)
+}
+
+func (s *Server) handleRender(w http.ResponseWriter, req *http.Request) {
+	requestOptions := s.defaultOptions()
 
-	err := json.NewDecoder(req.Body).Decode(&requestOptions)
+	data, err := io.ReadAll(s.readBody(w, req))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+	err = DecodeRenderOptions(data, &requestOptions)
+	if e, ok := err.(*RequestError); ok && e.Status == http.StatusUnprocessableEntity {
+		// report the invalid values of the other fields along
+		if v, ok := requestOptions.Validate().(*RequestError); ok {
+			e.Errors = append(e.Errors, v.Errors...)
+		}
+	}
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	scene := engine.NewScene(requestOptions.Width, requestOptions.Height, requestOptions.RaysPerPixel, requestOptions.World.Camera, requestOptions.World.Objects)
-	pixels, completed := scene.Render(runtime.NumCPU())
+	s.serveRender(w, req, &requestOptions)
+}
 
-	<-completed
-	fmt.Println("Render complete.")
+// serveRender validates the options, renders them in the negotiated format (once the executor gives it its
+// workers) and writes the response
+func (s *Server) serveRender(w http.ResponseWriter, req *http.Request, options *RenderOptions) {
+	err := options.Validate()
+	if err == nil {
+		err = s.config.Limits.check(options)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	format, err := negotiateFormat(options.Format, req.Header.Get("Accept"), options.fallbackFormat())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := options.checkFormat(format); err != nil {
+		writeError(w, invalid("format", err))
+		return
+	}
+
+	workers := s.config.Parallelism
+	if options.Parallelism > 0 {
+		workers = min(options.Parallelism, s.config.Workers)
+	}
+	release, err := s.executor.acquire(req.Context(), workers, options.Priority)
+	if err != nil {
+		status := statusClientClosedRequest
+		var full *QueueFullError
+		if errors.As(err, &full) {
+			status = http.StatusTooManyRequests
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(full.RetryAfter.Seconds()))))
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusServiceUnavailable
+		}
+		writeError(w, &RequestError{Status: status, Errors: []FieldError{{Message: err.Error()}}})
+		return
+	}
+	defer release()
+
+	options.Threads = workers
+	totals := &renderTotals{}
+	options.Rendered = totals.add
+	w.Header().Set("Content-Type", contentTypes[format])
+	rw := &responseWriter{ResponseWriter: w}
+	start := time.Now()
+	err = Render(rw, options, format)
+	s.metrics.observe(options, format, time.Since(start), totals, err)
+	if err != nil {
+		if !rw.written {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			// videos are streamed: the status is already sent
+			slog.Error("Render aborted", "error", err)
+		}
+	}
+}
+
+// statusClientClosedRequest answers the renders canceled by the client while queued (the status of nginx, never
+// received by the client but recorded by the metrics)
+const statusClientClosedRequest = 499
+
+// responseWriter tracks whether the response was started (the status can no longer be set then) and its status
+type responseWriter struct {
+	http.ResponseWriter
+	written bool
+	status  int
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
 
-	img := engine.CreateImage(pixels, requestOptions.Width, requestOptions.Height)
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.written = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
 
-	w.Header().Set("Content-Type", "image/png")
-	png.Encode(w, img)
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
 }
 
-func loadDefaultWorld() error {
-	loaded, err := engine.LoadWorld("assets/world.json")
+// Render renders the (validated) options in the format and writes the file: the frames of videos are written
+// as soon as they are rendered, other formats once complete (nothing is written when the render fails)
+func Render(w io.Writer, options *RenderOptions, format Format) error {
+	if err := options.checkFormat(format); err != nil {
+		return err
+	}
+
+	// the denoiser is guided by the albedo and normal, rendered even when not requested
+	aovs := options.AOVs
+	if options.Denoise {
+		aovs = append([]engine.AOV{engine.Albedo, engine.Normal}, aovs...)
+	}
+
+	animation := options.animation()
+	render := frameRenderer(options, animation, aovs)
+
+	if format.video() {
+		return streamVideo(w, format, animation, options, render)
+	}
+
+	sequence := animation.Sequence()
+	frames := make([]*engine.Framebuffer, len(sequence))
+	for i, f := range sequence {
+		fb, err := render(f)
+		if err != nil {
+			return err
+		}
+		frames[i] = fb
+	}
+
+	metadata, err := options.metadata(format, frames[0])
 	if err != nil {
 		return err
-	} else {
-		defaultWorld = *loaded
-		return nil
 	}
+	buf, err := encode(frames, format, options, metadata)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
 }
 
-func Start() {
-	err := loadDefaultWorld()
+// frameRenderer returns a function rendering the frames of the animation (each frame is rendered once, even when
+// it appears several times in the sequence)
+func frameRenderer(options *RenderOptions, animation *engine.Animation, aovs []engine.AOV) func(frame int) (*engine.Framebuffer, error) {
+	rendered := map[int]*engine.Framebuffer{}
+	return func(frame int) (*engine.Framebuffer, error) {
+		if fb, ok := rendered[frame]; ok {
+			return fb, nil
+		}
+		fb, err := renderFrame(options, animation.Camera(frame, options.World.Camera), aovs)
+		if err != nil {
+			return nil, err
+		}
+		rendered[frame] = fb
+		if options.Rendered != nil {
+			options.Rendered(fb)
+		}
+		return fb, nil
+	}
+}
+
+// renderFrame renders the world of the request seen from the camera (with the aovs), then denoises it when
+// requested
+func renderFrame(options *RenderOptions, cam camera.Camera, aovs []engine.AOV) (*engine.Framebuffer, error) {
+	scene := engine.NewScene(options.Width, options.Height, options.RaysPerPixel, cam, options.World.Objects,
+		engine.WithFog(options.World.Fog),
+		engine.WithSpectral(options.Spectral),
+		engine.WithPipeline(tonemap.NewPipeline(options.ToneMapping)),
+		engine.WithAOVs(aovs...),
+		engine.WithAdaptive(options.Adaptive),
+		engine.WithSampler(options.Sampler, options.Seed),
+		engine.WithFilter(filter.New(options.Filter)),
+		engine.WithTransparentBackground(options.Transparent),
+		engine.WithMaterialIndices(options.World.MaterialIndices()),
+		engine.WithProgress(options.Progress),
+	)
+	threads := options.Threads
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+	fb, completed, err := scene.Render(threads)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	http.HandleFunc("POST /render", handleRender)
+	<-completed
+	slog.Info("Render complete.", "width", fb.Width, "height", fb.Height, "duration", fb.Statistics.Duration,
+		"raysPerPixel", fb.Statistics.RaysPerPixel(fb.Width*fb.Height))
+
+	if options.Denoise {
+		err := fb.Denoise(options.DenoiseWith)
+		if err != nil {
+			return nil, err
+		}
+		fb.Retain(options.AOVs...)
+	}
+	return fb, nil
+}
+
+// Handler returns the handler of the endpoints of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /render", s.metrics.instrument("render", s.handleRender))
+	mux.HandleFunc("POST /metadata", s.metrics.instrument("metadata", s.handleMetadata))
+	mux.HandleFunc("POST /rerender", s.metrics.instrument("rerender", s.handleRerender))
+	mux.Handle("GET /metrics", s.metrics.registry)
+	return mux
+}
 
-	fmt.Println("Server is starting on port 8090.")
-	err = http.ListenAndServe(":8090", nil)
+// Start serves the requests with the config (until the server fails)
+func Start(config *Config) error {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: config.LogLevel})))
+
+	s, err := New(config)
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	server := &http.Server{Addr: config.Address, Handler: s.Handler()}
+	slog.Info("Server is starting.", "address", config.Address, "tls", config.TLS.CertFile != "",
+		"workers", config.Workers, "parallelism", config.Parallelism, "maxRenders", config.MaxRenders,
+		"maxQueued", config.MaxQueued)
+	if config.TLS.CertFile != "" {
+		err = server.ListenAndServeTLS(config.TLS.CertFile, config.TLS.KeyFile)
+	} else {
+		err = server.ListenAndServe()
 	}
-	fmt.Println("Server closed.")
+	slog.Info("Server closed.")
+	return err
 }
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	cases := []struct {
+		option   Format
+		accept   string
+		fallback Format
+		expected Format
+		status   int // of the error, 0 when the format is negotiated
+	}{
+		// the option overrides the header
+		{PNG, "image/jpeg", EXR, PNG, 0},
+		{"bmp", "", PNG, "", http.StatusUnprocessableEntity},
+		{"", "", PNG, PNG, 0},
+		{"", "image/jpeg", PNG, JPEG, 0},
+		// q weighting, the first one listed on ties
+		{"", "image/jpeg;q=0.5, image/tiff", PNG, TIFF, 0},
+		{"", "image/jpeg, image/tiff", PNG, JPEG, 0},
+		{"", "image/tiff;q=0.5, image/jpeg;q=0.5", PNG, TIFF, 0},
+		{"", "image/jpeg;q=0.2, image/png;q=0.3", EXR, PNG, 0},
+		// the wildcards weigh the fallback, the listed types taking precedence
+		{"", "*/*", EXR, EXR, 0},
+		{"", "image/*", PNG, PNG, 0},
+		{"", "image/*;q=0.9, image/jpeg;q=0.5", PNG, PNG, 0},
+		{"", "image/*;q=0.5, image/jpeg", PNG, JPEG, 0},
+		{"", "application/zip, */*;q=0.5", EXR, ZIP, 0},
+		{"", "image/*, image/png;q=0.1", PNG, PNG, 0},
+		{"", "video/*", PNG, PNG, 0},
+		// q=0 refuses the media type
+		{"", "image/png;q=0", PNG, "", http.StatusNotAcceptable},
+		{"", "*/*;q=0", PNG, "", http.StatusNotAcceptable},
+		{"", "image/*;q=0", PNG, "", http.StatusNotAcceptable},
+		{"", "*/*;q=0.5, image/png;q=0", PNG, "", http.StatusNotAcceptable},
+		{"", "image/jpeg;q=0, text/html", PNG, "", http.StatusNotAcceptable},
+		{"", "image/jpeg;q=0, image/tiff;q=0.1", PNG, TIFF, 0},
+		// the unsupported or invalid types fall back
+		{"", "text/html", PNG, PNG, 0},
+		{"", "text/html, image/bogus", GIF, GIF, 0},
+		{"", "image/jpeg;q=2", PNG, PNG, 0},
+		{"", "image/jpeg;q=x, ;;, image/tiff", PNG, TIFF, 0},
+	}
+
+	for _, tc := range cases {
+		format, err := negotiateFormat(tc.option, tc.accept, tc.fallback)
+		if tc.status != 0 {
+			if e, ok := err.(*RequestError); !ok || e.Status != tc.status {
+				t.Errorf("Expected a %v error for %q, but got %v (%v)", tc.status, tc.accept, format, err)
+			}
+			continue
+		}
+		if err != nil || format != tc.expected {
+			t.Errorf("Expected %v for %q, but got %v (%v)", tc.expected, tc.accept, format, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine"
+)
+
+// FieldError describes why a field of a request is invalid
+type FieldError struct {
+	Field   string `json:"field"` // path of the field (world.objects[2]...), empty for the whole request
+	Message string `json:"message"`
+}
+
+// RequestError is the error of a request: the status of the response and the errors of the fields (sent as JSON)
+//
+//	400: the body is not valid JSON
+//	406: none of the formats accepted by the request is supported
+//	413: the request exceeds the limits of the server (body size, pixels, rays per pixel, objects or cost)
+//	422: fields have invalid values
+//	429: the render queue is full, 499 or 503: the request was canceled or timed out while queued
+type RequestError struct {
+	Status int          `json:"-"`
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *RequestError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, f := range e.Errors {
+		messages[i] = f.Message
+		if f.Field != "" {
+			messages[i] = f.Field + ": " + f.Message
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add adds the error of the field (when not nil)
+func (e *RequestError) add(field string, err error) {
+	if err != nil {
+		e.Errors = append(e.Errors, FieldError{Field: field, Message: err.Error()})
+	}
+}
+
+// result returns the error, or nil when no field has an error
+func (e *RequestError) result() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// invalid returns the error of a field with an invalid value
+func invalid(field string, err error) *RequestError {
+	return &RequestError{Status: http.StatusUnprocessableEntity, Errors: []FieldError{{Field: field, Message: err.Error()}}}
+}
+
+// writeError writes the error of a request: a RequestError as JSON with its status, 413 when the body is too
+// large, 400 otherwise
+func writeError(w http.ResponseWriter, err error) {
+	var requestError *RequestError
+	if !errors.As(err, &requestError) {
+		requestError = &RequestError{Status: http.StatusBadRequest, Errors: []FieldError{{Message: err.Error()}}}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			requestError.Status = http.StatusRequestEntityTooLarge
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(requestError.Status)
+	json.NewEncoder(w).Encode(requestError)
+}
+
+// DecodeRenderOptions decodes the JSON of a request over the options, field by field so that every invalid field
+// is reported (unknown fields are errors)
+func DecodeRenderOptions(data []byte, options *RenderOptions) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return &RequestError{Status: http.StatusBadRequest, Errors: []FieldError{{Message: err.Error()}}}
+	}
+
+	e := &RequestError{Status: http.StatusUnprocessableEntity}
+	v := reflect.ValueOf(options).Elem()
+	t := v.Type()
+	known := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		for key, value := range fields {
+			// as encoding/json, keys match the names of the fields regardless of the case
+			if !strings.EqualFold(key, name) {
+				continue
+			}
+			known[key] = true
+			if err := json.Unmarshal(value, v.Field(i).Addr().Interface()); err != nil {
+				field, message := decodingError(name, err)
+				e.Errors = append(e.Errors, FieldError{Field: field, Message: message})
+			}
+		}
+	}
+	var unknown []string
+	for key := range fields {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		e.Errors = append(e.Errors, FieldError{Field: key, Message: "unknown field"})
+	}
+	return e.result()
+}
+
+// decodingError returns the path and message of the error decoding the field
+func decodingError(field string, err error) (string, string) {
+	var typeError *json.UnmarshalTypeError
+	var objectError *engine.ObjectError
+	switch {
+	case errors.As(err, &objectError):
+		// the path of the error within the object (which wraps it)
+		return decodingError(fmt.Sprintf("%s.objects[%d]", field, objectError.Index), objectError.Err)
+	case errors.As(err, &typeError):
+		if typeError.Field != "" {
+			field += "." + typeError.Field
+		}
+		return field, fmt.Sprintf("expected %v, got %v", typeError.Type, typeError.Value)
+	default:
+		return field, err.Error()
+	}
+}
+
+// Validate checks the options (a RequestError lists all the invalid fields)
+func (o *RenderOptions) Validate() error {
+	e := &RequestError{Status: http.StatusUnprocessableEntity}
+	positive := func(field string, value int) {
+		if value <= 0 {
+			e.add(field, fmt.Errorf("must be positive: %v", value))
+		}
+	}
+	positive("width", o.Width)
+	positive("height", o.Height)
+	positive("raysperpixel", o.RaysPerPixel)
+	if o.Width > 0 && o.Height > 0 && o.Width > math.MaxInt/4/o.Height {
+		// the framebuffer holds 4 values per pixel
+		e.add("width", fmt.Errorf("image of %vx%v pixels is too large", o.Width, o.Height))
+	}
+	if o.Parallelism < 0 {
+		e.add("parallelism", fmt.Errorf("cannot be negative: %v", o.Parallelism))
+	}
+	if o.World.Camera == nil {
+		e.add("world.camera", fmt.Errorf("camera is missing"))
+	}
+	if o.Format != "" {
+		if _, ok := contentTypes[o.Format]; !ok {
+			e.add("format", fmt.Errorf("unknown format: %s", o.Format))
+		}
+	}
+
+	e.add("tonemapping", o.ToneMapping.Validate())
+	e.add("jpeg", o.JPEG.Validate())
+	e.add("gif", o.GIF.Validate())
+	e.add("exr", o.EXR.Validate())
+	e.add("alpha", o.Alpha.Validate())
+	e.add("aovs", engine.ValidateAOVs(o.AOVs))
+	e.add("sampler", o.Sampler.Validate())
+	e.add("filter", o.Filter.Validate())
+	if o.Adaptive != nil {
+		e.add("adaptive", o.Adaptive.Validate())
+	}
+	if o.Denoise {
+		e.add("denoiseSettings", o.DenoiseWith.Validate())
+	}
+	if o.Animation != nil {
+		e.add("animation", o.Animation.Validate())
+	}
+	return e.result()
+}
+
+// raysPerPixel returns the largest number of rays cast through a pixel
+func (o *RenderOptions) raysPerPixel() int {
+	if o.Adaptive != nil {
+		return o.Adaptive.MaxSamples
+	}
+	return o.RaysPerPixel
+}
+
+// EstimateCost returns a rough cost of the render: the intersection tests of the camera rays (every ray being
+// tested against every object of the world) of all the frames
+func (o *RenderOptions) EstimateCost() float64 {
+	return float64(o.Width) * float64(o.Height) * float64(o.raysPerPixel()) * float64(o.animation().Frames) *
+		float64(max(len(o.World.Objects), 1))
+}
+
+// check checks that the (valid) options do not exceed the limits
+func (l *Limits) check(o *RenderOptions) error {
+	e := &RequestError{Status: http.StatusRequestEntityTooLarge}
+	if l.MaxPixels > 0 && o.Width > l.MaxPixels/o.Height {
+		e.add("width", fmt.Errorf("image of %vx%v pixels exceeds the maximum of %v pixels", o.Width, o.Height, l.MaxPixels))
+	}
+	if rays := o.raysPerPixel(); l.MaxRaysPerPixel > 0 && rays > l.MaxRaysPerPixel {
+		field := "raysperpixel"
+		if o.Adaptive != nil {
+			field = "adaptive.maxSamples"
+		}
+		e.add(field, fmt.Errorf("%v rays per pixel exceed the maximum of %v", rays, l.MaxRaysPerPixel))
+	}
+	if l.MaxObjects > 0 && len(o.World.Objects) > l.MaxObjects {
+		e.add("world.objects", fmt.Errorf("%v objects exceed the maximum of %v", len(o.World.Objects), l.MaxObjects))
+	}
+	if cost := o.EstimateCost(); l.MaxCost > 0 && cost > l.MaxCost {
+		e.add("", fmt.Errorf("estimated cost of %.3g intersection tests exceeds the budget of %.3g", cost, l.MaxCost))
+	}
+	return e.result()
+}
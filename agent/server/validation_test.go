@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ath0m/DistributedRaytracer/agent/engine"
+)
+
+// testWorld returns a world of a single sphere
+func testWorld(t *testing.T) engine.World {
+	var world engine.World
+	data := `{
+		"camera": {"lookFrom": {"Z": 3}, "lookAt": {}, "vup": {"Y": 1}, "vfov": 40, "aperture": 0, "focusDist": 3},
+		"objects": [{"center": {}, "radius": 1, "material": {"type": "Lambertian", "albedo": {"R": 0.5, "G": 0.5, "B": 0.5}}}]
+	}`
+	if err := json.Unmarshal([]byte(data), &world); err != nil {
+		t.Fatal(err)
+	}
+	return world
+}
+
+// testServer returns a server rendering the test world with the config (without a world file)
+func testServer(t *testing.T, config Config) *Server {
+	executor := newExecutor(config.Workers, config.MaxRenders, config.MaxQueued)
+	return &Server{config: &config, world: testWorld(t), executor: executor, metrics: newServerMetrics(executor)}
+}
+
+func TestLimitsCheckOverflow(t *testing.T) {
+	// width x height overflows int
+	options := DefaultRenderOptions(testWorld(t))
+	options.Width, options.Height = 1<<32, 1<<32
+
+	err := (&Limits{MaxPixels: 1000}).check(&options)
+	if e, ok := err.(*RequestError); !ok || e.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a 413 error, but got %v", err)
+	}
+	if err := options.Validate(); err == nil {
+		t.Errorf("Expected an error for an image too large to allocate")
+	}
+}
+
+func TestRenderRequestErrors(t *testing.T) {
+	config := DefaultConfig()
+	config.Defaults = Defaults{Width: 4, Height: 4, RaysPerPixel: 1, Seed: 2024}
+	config.Limits = Limits{MaxBodyBytes: 1024, MaxPixels: 64 * 64, MaxRaysPerPixel: 16, MaxObjects: 2, MaxCost: 1e4}
+	s := testServer(t, config)
+
+	camera := `"camera": {"lookFrom": {"Z": 3}, "lookAt": {}, "vfov": 40, "aperture": 0, "focusDist": 3}`
+	sphere := `{"center": {}, "radius": 1, "material": {"type": "Lambertian", "albedo": {"R": 0.5}}}`
+	cases := []struct {
+		body   string
+		status int
+		fields []string // of the errors, in order
+	}{
+		{`{}`, http.StatusOK, nil},
+		{`{"width": 4`, http.StatusBadRequest, []string{""}},
+		{`[4]`, http.StatusBadRequest, []string{""}},
+		{`{"width": 4, "seed": "` + strings.Repeat("1", 1024) + `"}`, http.StatusRequestEntityTooLarge, []string{""}},
+		// the decoding errors, then the unknown fields, then the invalid values of the other fields
+		{`{"widht": 4, "height": "x", "raysperpixel": 0}`, http.StatusUnprocessableEntity, []string{"height", "widht", "raysperpixel"}},
+		{`{"tonemapping": {"ev": "x"}}`, http.StatusUnprocessableEntity, []string{"tonemapping.ev"}},
+		{`{"width": 0, "height": -1, "parallelism": -1, "format": "bmp", "sampler": "random", "aovs": ["depth", "color"]}`,
+			http.StatusUnprocessableEntity, []string{"width", "height", "parallelism", "format", "aovs", "sampler"}},
+		{`{"width": 4294967296, "height": 4294967296}`, http.StatusUnprocessableEntity, []string{"width"}},
+		{`{"world": {` + camera + `, "objects": [` + sphere + `, {"type": "Cube"}]}}`, http.StatusUnprocessableEntity, []string{"world.objects[1]"}},
+		{`{"world": {` + camera + `, "objects": [{"radius": "x"}]}}`, http.StatusUnprocessableEntity, []string{"world.objects[0].radius"}},
+		{`{"format": "png", "aovs": ["depth"]}`, http.StatusUnprocessableEntity, []string{"format"}},
+		// the limits are checked once the options are valid
+		{`{"width": 128, "height": 64, "raysperpixel": 32}`, http.StatusRequestEntityTooLarge, []string{"width", "raysperpixel", ""}},
+		{`{"adaptive": {"threshold": 0.1, "minSamples": 1, "maxSamples": 32}}`, http.StatusRequestEntityTooLarge, []string{"adaptive.maxSamples"}},
+		{`{"world": {` + camera + `, "objects": [` + strings.Repeat(sphere+`, `, 2) + sphere + `]}}`, http.StatusRequestEntityTooLarge, []string{"world.objects"}},
+		{`{"width": 64, "height": 64, "raysperpixel": 4}`, http.StatusRequestEntityTooLarge, []string{""}},
+		{`{"width": 0, "raysperpixel": 32}`, http.StatusUnprocessableEntity, []string{"width"}},
+	}
+
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/render", strings.NewReader(tc.body)))
+		if w.Code != tc.status {
+			t.Errorf("Expected %v, but got %v (%v: %v)", tc.status, w.Code, tc.body, w.Body.String())
+			continue
+		}
+		if tc.status == http.StatusOK {
+			continue
+		}
+
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Expected a JSON error, but got %v", contentType)
+		}
+		var body struct {
+			Errors []struct {
+				Field   string `json:"field"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Errorf("Expected a JSON error, but got %v (%v)", w.Body.String(), err)
+			continue
+		}
+		var fields []string
+		for _, e := range body.Errors {
+			fields = append(fields, e.Field)
+			if e.Message == "" {
+				t.Errorf("Expected a message for the field %q (%v)", e.Field, tc.body)
+			}
+		}
+		if !slices.Equal(fields, tc.fields) {
+			t.Errorf("Expected the fields %q, but got %q (%v)", tc.fields, fields, tc.body)
+		}
+	}
+	// a world without camera (the default world when not loaded)
+	options := DefaultRenderOptions(engine.World{})
+	if e, ok := options.Validate().(*RequestError); !ok || len(e.Errors) != 1 || e.Errors[0].Field != "world.camera" {
+		t.Errorf("Expected an error for the missing camera, but got %v", options.Validate())
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// job is the render of a scene and its outcome
+type job struct {
+	scene   string         // world file
+	options map[string]any // render options (the world is added from the scene)
+	output  string         // image file
+
+	agent    string // agent of the last attempt
+	attempts int
+	duration time.Duration // of the successful attempt
+	size     int64
+	err      error
+}
+
+// client posts the jobs to the agents, retrying failed attempts on the next agent
+type client struct {
+	agents  []string
+	http    *http.Client
+	retries int
+	backoff time.Duration
+	log     io.Writer
+	sleep   func(time.Duration) // time.Sleep unless tested
+}
+
+// run renders the jobs, concurrency of them at the same time (spread over the agents)
+func (c *client) run(jobs []*job, concurrency int) {
+	queue := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			for i := range queue {
+				c.render(jobs[i], i)
+			}
+			wg.Done()
+		}()
+	}
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// render submits the job n until it succeeds or fails retries times, doubling the delay between the attempts
+// (or waiting as long as the agent asks with Retry-After)
+func (c *client) render(j *job, n int) {
+	body, err := payload(j.scene, j.options)
+	if err != nil {
+		j.err = err
+		return
+	}
+
+	delay := c.backoff
+	for j.attempts = 1; ; j.attempts++ {
+		j.agent = strings.TrimSuffix(c.agents[(n+j.attempts-1)%len(c.agents)], "/")
+		start := time.Now()
+		var retryAfter time.Duration
+		retryAfter, j.err = c.post(j, body)
+		if j.err == nil {
+			j.duration = time.Since(start)
+			fmt.Fprintf(c.log, "%s rendered by %s in %v\n", j.scene, j.agent, j.duration.Round(time.Millisecond))
+			return
+		}
+		if retryAfter < 0 || j.attempts > c.retries {
+			fmt.Fprintf(c.log, "%s failed: %v\n", j.scene, j.err)
+			return
+		}
+
+		wait := max(delay, retryAfter)
+		fmt.Fprintf(c.log, "%s attempt %v failed, retrying in %v: %v\n", j.scene, j.attempts, wait, j.err)
+		c.wait(wait)
+		delay *= 2
+	}
+}
+
+func (c *client) wait(d time.Duration) {
+	if c.sleep != nil {
+		c.sleep(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+// post sends the render request to the agent of the job and saves the image
+//
+//	on failure, it returns how long to wait before retrying as requested by the agent (0 when it does not tell),
+//	or -1 when retrying cannot succeed (invalid request)
+func (c *client) post(j *job, body []byte) (time.Duration, error) {
+	resp, err := c.http.Post(j.agent+"/render", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return -1, err
+		}
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return time.Duration(seconds) * time.Second, err
+	}
+
+	f, err := os.Create(j.output)
+	if err != nil {
+		return -1, err
+	}
+	j.size, err = io.Copy(f, resp.Body)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(j.output)
+		return 0, err
+	}
+	return 0, nil
+}
+
+// payload returns the body of the render request of the scene: the options with the world of the scene file
+func payload(scene string, options map[string]any) ([]byte, error) {
+	world, err := os.ReadFile(scene)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(world) {
+		return nil, fmt.Errorf("%s is not valid JSON", scene)
+	}
+
+	request := map[string]any{}
+	for k, v := range options {
+		request[k] = v
+	}
+	request["world"] = json.RawMessage(world)
+	return json.Marshal(request)
+}
+
+// loadOptions reads the render options of a JSON file (none when the file is empty)
+func loadOptions(file string) (map[string]any, error) {
+	options := map[string]any{}
+	if file == "" {
+		return options, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &options); err != nil {
+		return nil, fmt.Errorf("invalid options %s: %w", file, err)
+	}
+	return options, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClientRetries(t *testing.T) {
+	dir := t.TempDir()
+	scene := filepath.Join(dir, "scene.json")
+	if err := os.WriteFile(scene, []byte(`{"objects":[]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		statuses         []int
+		expectedAttempts int
+		expectedError    bool
+	}{
+		{[]int{http.StatusOK}, 1, false},
+		{[]int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, 3, false},
+		{[]int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}, 3, true},
+		{[]int{http.StatusBadRequest, http.StatusOK}, 1, true},
+	}
+
+	for _, test := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var body map[string]json.RawMessage
+			data, _ := io.ReadAll(req.Body)
+			if err := json.Unmarshal(data, &body); err != nil || string(body["world"]) != `{"objects":[]}` ||
+				string(body["width"]) != "40" {
+				t.Errorf("Unexpected request %s", data)
+			}
+			status := test.statuses[requests]
+			requests++
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "5")
+			}
+			w.WriteHeader(status)
+			w.Write([]byte("image"))
+		}))
+
+		var waits []time.Duration
+		c := &client{
+			agents:  []string{server.URL},
+			http:    server.Client(),
+			retries: 2,
+			backoff: time.Second,
+			log:     io.Discard,
+			sleep:   func(d time.Duration) { waits = append(waits, d) },
+		}
+		j := &job{scene: scene, options: map[string]any{"width": 40}, output: filepath.Join(dir, "scene.png")}
+		c.run([]*job{j}, 1)
+		server.Close()
+
+		if j.attempts != test.expectedAttempts {
+			t.Errorf("Expected %v attempts, but got %v", test.expectedAttempts, j.attempts)
+		}
+		if (j.err != nil) != test.expectedError {
+			t.Errorf("Expected error %v, but got %v", test.expectedError, j.err)
+		}
+		if len(test.statuses) == 3 && test.statuses[1] == http.StatusTooManyRequests {
+			if len(waits) != 2 || waits[0] != time.Second || waits[1] != 5*time.Second {
+				t.Errorf("Expected waits of 1s then 5s (Retry-After), but got %v", waits)
+			}
+		}
+		if !test.expectedError {
+			data, err := os.ReadFile(j.output)
+			if err != nil || string(data) != "image" {
+				t.Errorf("Expected the image to be saved, but got %q (%v)", data, err)
+			}
+		}
+		os.Remove(j.output)
+	}
+}
+
+func TestOutputFiles(t *testing.T) {
+	outputs, err := outputFiles([]string{"scenes/world.json", "other/room.scene.json", "cube"}, "out", "exr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{filepath.Join("out", "world.exr"), filepath.Join("out", "room.scene.exr"), filepath.Join("out", "cube.exr")}
+	for i := range expected {
+		if outputs[i] != expected[i] {
+			t.Errorf("Expected %v, but got %v", expected[i], outputs[i])
+		}
+	}
+
+	// the images of scenes of the same name would collide
+	for _, scenes := range [][]string{
+		{"a/world.json", "b/world.json"},
+		{"world.json", "world.json"},
+		{"a/World.json", "b/world.JSON"},
+		{"world.json", "world.yaml"},
+	} {
+		if _, err := outputFiles(scenes, "out", "png"); err == nil {
+			t.Errorf("Expected an error for the scenes %v", scenes)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Command client submits render jobs to agents: each scene (a world file, as assets/world.json of the agent) is
+// posted to the /render endpoint of an agent and the image saved in the output directory
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+func main() {
+	agents := flag.String("agents", "http://localhost:8090", "comma separated URLs of the agents")
+	options := flag.String("options", "", "JSON file of render options (as the body of a request, without the world)")
+	width := flag.Int("width", 0, "width in pixels (default of the agent when 0)")
+	height := flag.Int("height", 0, "height in pixels (default of the agent when 0)")
+	samples := flag.Int("samples", 0, "rays per pixel (default of the agent when 0)")
+	seed := flag.Int64("seed", 0, "seed of the sampler (default of the agent when not set)")
+	format := flag.String("format", "png", "format of the images")
+	output := flag.String("o", ".", "output directory")
+	concurrency := flag.Int("concurrency", 1, "scenes rendered at the same time")
+	retries := flag.Int("retries", 3, "retries of a failed scene")
+	backoff := flag.Duration("backoff", time.Second, "delay before the first retry (doubled on every retry)")
+	timeout := flag.Duration("timeout", 10*time.Minute, "timeout of a render")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: client [flags] SCENE|DIRECTORY...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || *concurrency < 1 || *retries < 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	base, err := loadOptions(*options)
+	if err != nil {
+		fail(err)
+	}
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if *width > 0 {
+		base["width"] = *width
+	}
+	if *height > 0 {
+		base["height"] = *height
+	}
+	if *samples > 0 {
+		base["raysperpixel"] = *samples
+	}
+	if set["seed"] {
+		base["seed"] = *seed
+	}
+	if f, ok := base["format"].(string); ok && !set["format"] {
+		*format = f
+	}
+	base["format"] = *format
+
+	scenes, err := findScenes(flag.Args())
+	if err != nil {
+		fail(err)
+	}
+	outputs, err := outputFiles(scenes, *output, *format)
+	if err != nil {
+		fail(err)
+	}
+	if err := os.MkdirAll(*output, 0o755); err != nil {
+		fail(err)
+	}
+
+	c := &client{
+		agents:  strings.Split(*agents, ","),
+		http:    &http.Client{Timeout: *timeout},
+		retries: *retries,
+		backoff: *backoff,
+		log:     os.Stderr,
+	}
+	jobs := make([]*job, len(scenes))
+	for i, scene := range scenes {
+		jobs[i] = &job{scene: scene, options: base, output: outputs[i]}
+	}
+
+	start := time.Now()
+	c.run(jobs, *concurrency)
+	if !report(os.Stdout, jobs, time.Since(start)) {
+		os.Exit(1)
+	}
+}
+
+// findScenes returns the scene files: the files given and the JSON files of the directories given
+func findScenes(args []string) ([]string, error) {
+	var scenes []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			scenes = append(scenes, arg)
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(arg, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		scenes = append(scenes, files...)
+	}
+	if len(scenes) == 0 {
+		return nil, fmt.Errorf("no scene to render")
+	}
+	return scenes, nil
+}
+
+// outputFiles returns the image file of each scene in the output directory, named after the scene: scenes of the
+// same name would overwrite each other's image (the names are compared regardless of the case, as some file
+// systems do)
+func outputFiles(scenes []string, dir, format string) ([]string, error) {
+	outputs := make([]string, len(scenes))
+	saved := map[string]string{}
+	for i, scene := range scenes {
+		name := strings.TrimSuffix(filepath.Base(scene), filepath.Ext(scene)) + "." + format
+		if other, ok := saved[strings.ToLower(name)]; ok {
+			return nil, fmt.Errorf("scenes %s and %s would both be saved as %s", other, scene, name)
+		}
+		saved[strings.ToLower(name)] = scene
+		outputs[i] = filepath.Join(dir, name)
+	}
+	return outputs, nil
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
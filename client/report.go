@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// report prints the outcome of every job and the totals, and tells whether all of them succeeded
+func report(w io.Writer, jobs []*job, elapsed time.Duration) bool {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCENE\tAGENT\tATTEMPTS\tTIME\tSIZE\tRESULT")
+
+	failed := 0
+	var total, fastest, slowest time.Duration
+	for _, j := range jobs {
+		if j.err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\t%s\t%v\t-\t-\t%v\n", j.scene, j.agent, j.attempts, j.err)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%v\t%v\t%v KB\t%s\n", j.scene, j.agent, j.attempts,
+			j.duration.Round(time.Millisecond), (j.size+1023)/1024, j.output)
+
+		total += j.duration
+		if fastest == 0 || j.duration < fastest {
+			fastest = j.duration
+		}
+		slowest = max(slowest, j.duration)
+	}
+	tw.Flush()
+
+	rendered := len(jobs) - failed
+	fmt.Fprintf(w, "%v scenes: %v rendered, %v failed in %v", len(jobs), rendered, failed, elapsed.Round(time.Millisecond))
+	if rendered > 0 {
+		mean := total / time.Duration(rendered)
+		fmt.Fprintf(w, " (render time min %v, mean %v, max %v)", fastest.Round(time.Millisecond),
+			mean.Round(time.Millisecond), slowest.Round(time.Millisecond))
+	}
+	fmt.Fprintln(w)
+	return failed == 0
+}